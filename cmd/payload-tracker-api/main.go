@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"net/http"
 	"time"
 
@@ -12,6 +13,7 @@ import (
 	"github.com/redhatinsights/payload-tracker-go/internal/db"
 	"github.com/redhatinsights/payload-tracker-go/internal/endpoints"
 	"github.com/redhatinsights/payload-tracker-go/internal/logging"
+	"github.com/redhatinsights/payload-tracker-go/internal/tracing"
 )
 
 func lubdub(w http.ResponseWriter, r *http.Request) {
@@ -26,21 +28,41 @@ func main() {
 
 	cfg := config.Get()
 
+	shutdownTracer, err := tracing.InitTracer(context.Background(), cfg, "payload-tracker-api")
+	if err != nil {
+		logging.Log.Fatal("ERROR! ", err)
+	}
+	defer shutdownTracer(context.Background())
+
 	db.DbConnect(cfg)
+	db.DbConnectReplicas(cfg)
+	go db.MonitorReplicas(context.Background(), cfg)
 
-	healthHandler := endpoints.HealthCheckHandler(
-		db.DB,
-		*cfg,
-	)
+	healthChecks := []endpoints.HealthCheck{endpoints.DatabaseCheck(db.DB)}
+	if cfg.DatabaseConfig.DBDriver != "sqlite" {
+		healthChecks = append(healthChecks, endpoints.PartitionCheck(db.DB))
+	}
+	if cfg.RequestConfig.RequestorImpl == "storage-broker" {
+		healthChecks = append(healthChecks, endpoints.StorageBrokerCheck(*cfg))
+	}
+	health := endpoints.NewHealthChecker(*cfg, healthChecks...)
 
-	payloadArchiveLinkHandler := endpoints.CreatePayloadArchiveLinkHandler(
-		*cfg,
-	)
+	payloadArchiveLinkHandler, err := endpoints.CreatePayloadArchiveLinkHandler(*cfg)
+	if err != nil {
+		logging.Log.Fatal("ERROR! ", err)
+	}
+
+	slos, err := endpoints.ParseSLOs(*cfg)
+	if err != nil {
+		logging.Log.Fatal("ERROR! ", err)
+	}
 
 	r := chi.NewRouter()
 	mr := chi.NewRouter()
 	sub := chi.NewRouter()
 
+	r.Use(tracing.Middleware)
+	r.Use(endpoints.AccessLogMiddleware)
 	r.Use(httprate.LimitByIP(cfg.RequestConfig.MaxRequestsPerMinute, 1*time.Minute))
 
 	// Mount the root of the api router on /api/v1 unless ENVIRONMENT is DEV
@@ -55,7 +77,9 @@ func main() {
 	}
 
 	r.Get("/", lubdub)
-	r.Get("/health", healthHandler)
+	r.Get("/health", health.Ready)
+	r.Get("/live", health.Live)
+	r.Get("/ready", health.Ready)
 
 	// Mount the metrics handler on /metrics
 	mr.Get("/", lubdub)
@@ -64,10 +88,26 @@ func main() {
 	sub.With(endpoints.ResponseMetricsMiddleware).Get("/", lubdub)
 	sub.With(endpoints.ResponseMetricsMiddleware).Get("/payloads", endpoints.Payloads)
 	sub.With(endpoints.ResponseMetricsMiddleware).Get("/payloads/{request_id}", endpoints.RequestIdPayloads)
+	sub.With(endpoints.ResponseMetricsMiddleware).Get("/payloads/{request_id}/timeline", endpoints.PayloadTimeline)
 	sub.With(endpoints.ResponseMetricsMiddleware).Get("/payloads/{request_id}/archiveLink", payloadArchiveLinkHandler)
 	sub.With(endpoints.ResponseMetricsMiddleware).Get("/payloads/{request_id}/kibanaLink", endpoints.PayloadKibanaLink)
+	sub.With(endpoints.ResponseMetricsMiddleware).Get("/payloads/{request_id}/logLinks", endpoints.PayloadLogLinks(*cfg))
 	sub.With(endpoints.ResponseMetricsMiddleware).Get("/roles/archiveLink", endpoints.RolesArchiveLink)
+	sub.With(endpoints.ResponseMetricsMiddleware).Get("/admin/archiveLinkAudit", endpoints.ArchiveLinkAudit)
 	sub.With(endpoints.ResponseMetricsMiddleware).Get("/statuses", endpoints.Statuses)
+	sub.With(endpoints.ResponseMetricsMiddleware).Get("/statuses/catalog", endpoints.StatusesCatalog)
+	sub.With(endpoints.ResponseMetricsMiddleware).Get("/services", endpoints.Services)
+	sub.With(endpoints.ResponseMetricsMiddleware).Get("/sources", endpoints.Sources)
+	sub.With(endpoints.ResponseMetricsMiddleware).Get("/errors", endpoints.Errors)
+	sub.With(endpoints.ResponseMetricsMiddleware).Get("/stats/timeseries", endpoints.StatsTimeseries)
+	sub.With(endpoints.ResponseMetricsMiddleware).Get("/slo", endpoints.ServiceLevelObjectives(slos))
+	sub.With(endpoints.ResponseMetricsMiddleware).Get("/anomalies", endpoints.Anomalies)
+	sub.With(endpoints.ResponseMetricsMiddleware).Get("/webhooks", endpoints.Webhooks)
+	sub.With(endpoints.ResponseMetricsMiddleware).Post("/webhooks", endpoints.CreateWebhook)
+	sub.With(endpoints.ResponseMetricsMiddleware).Get("/webhooks/{id}", endpoints.GetWebhook)
+	sub.With(endpoints.ResponseMetricsMiddleware).Put("/webhooks/{id}", endpoints.UpdateWebhook)
+	sub.With(endpoints.ResponseMetricsMiddleware).Delete("/webhooks/{id}", endpoints.DeleteWebhook)
+	sub.With(endpoints.ResponseMetricsMiddleware).Get("/webhooks/{id}/deliveries", endpoints.WebhookDeliveries)
 
 	srv := http.Server{
 		Addr:    ":" + cfg.PublicPort,
@@ -2,16 +2,21 @@ package main
 
 import (
 	"context"
+	"flag"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
+	"github.com/redhatinsights/payload-tracker-go/internal/anomaly"
 	"github.com/redhatinsights/payload-tracker-go/internal/config"
 	"github.com/redhatinsights/payload-tracker-go/internal/db"
 	"github.com/redhatinsights/payload-tracker-go/internal/endpoints"
 	"github.com/redhatinsights/payload-tracker-go/internal/kafka"
 	"github.com/redhatinsights/payload-tracker-go/internal/logging"
+	"github.com/redhatinsights/payload-tracker-go/internal/slo"
+	"github.com/redhatinsights/payload-tracker-go/internal/tracing"
+	"github.com/redhatinsights/payload-tracker-go/internal/webhooks"
 )
 
 func lubdub(w http.ResponseWriter, r *http.Request) {
@@ -21,18 +26,59 @@ func lubdub(w http.ResponseWriter, r *http.Request) {
 }
 
 func main() {
+	source := flag.String("source", "kafka", "where to read payload status messages from: kafka or file")
+	file := flag.String("file", "payload-statuses.json", "file with one payload status message per line, used with --source=file")
+	flag.Parse()
+
 	logging.InitLogger()
 
 	cfg := config.Get()
 	ctx := context.Background()
 
+	shutdownTracer, err := tracing.InitTracer(ctx, cfg, "payload-tracker-consumer")
+	if err != nil {
+		logging.Log.Fatal("ERROR! ", err)
+	}
+	defer shutdownTracer(ctx)
+
 	logging.Log.Info("Setting up DB")
 	db.DbConnect(cfg)
 
-	healthHandler := endpoints.HealthCheckHandler(
-		db.DB,
-		*cfg,
-	)
+	if *source == "file" {
+		logging.Log.Infof("Reading messages from %s", *file)
+		if err := kafka.NewFileEventLoop(ctx, cfg, *file, db.DB); err != nil {
+			shutdownTracer(ctx)
+			logging.Log.Fatal("ERROR! ", err)
+		}
+		return
+	}
+
+	slos, err := endpoints.ParseSLOs(*cfg)
+	if err != nil {
+		logging.Log.Fatal("ERROR! ", err)
+	}
+
+	kafkaHealth := kafka.NewConsumerHealth(cfg)
+
+	healthChecks := []endpoints.HealthCheck{
+		endpoints.DatabaseCheck(db.DB),
+		{
+			Name:     "kafka",
+			Critical: true,
+			Check:    func(ctx context.Context) error { return kafkaHealth.Check() },
+		},
+		{
+			// a consumer that stopped polling is stuck and needs a restart
+			Name:     "kafka poll",
+			Critical: true,
+			Liveness: true,
+			Check:    func(ctx context.Context) error { return kafkaHealth.CheckPoll() },
+		},
+	}
+	if cfg.DatabaseConfig.DBDriver != "sqlite" {
+		healthChecks = append(healthChecks, endpoints.PartitionCheck(db.DB))
+	}
+	health := endpoints.NewHealthChecker(*cfg, healthChecks...)
 
 	logging.Log.Info("Starting a new kafka consumer...")
 
@@ -41,8 +87,8 @@ func main() {
 
 	// Mount the metrics handler on /metrics
 	r.Get("/", lubdub)
-	r.Get("/live", healthHandler)
-	r.Get("/ready", healthHandler)
+	r.Get("/live", health.Live)
+	r.Get("/ready", health.Ready)
 	r.Handle("/metrics", promhttp.Handler())
 
 	msrv := http.Server{
@@ -50,7 +96,7 @@ func main() {
 		Handler: r,
 	}
 
-	consumer, err := kafka.NewConsumer(ctx, cfg, cfg.KafkaConfig.KafkaTopic)
+	consumer, err := kafka.NewConsumer(ctx, cfg, cfg.KafkaConfig.KafkaTopic, kafkaHealth)
 
 	if err != nil {
 		logging.Log.Fatal("ERROR! ", err)
@@ -63,5 +109,28 @@ func main() {
 		}
 	}()
 
-	kafka.NewConsumerEventLoop(ctx, cfg, consumer, db.DB)
+	if cfg.WebhookConfig.Enabled {
+		go webhooks.NewDispatcher(cfg, db.DB).Run(ctx)
+	}
+
+	if len(slos.Definitions) > 0 {
+		go slo.NewEvaluator(cfg, db.DB, slos).Run(ctx)
+	}
+
+	if cfg.AnomalyConfig.Enabled {
+		go anomaly.NewDetector(cfg, db.DB).Run(ctx)
+	}
+
+	var lifecycle *kafka.LifecycleProducer
+	if cfg.LifecycleConfig.Enabled {
+		lifecycle, err = kafka.NewLifecycleProducer(cfg)
+		if err != nil {
+			logging.Log.Fatal("ERROR! ", err)
+		}
+		defer lifecycle.Close()
+
+		go kafka.NewStuckDetector(cfg, db.DB, lifecycle).Run(ctx)
+	}
+
+	kafka.NewConsumerEventLoop(ctx, cfg, consumer, db.DB, kafkaHealth, lifecycle)
 }
@@ -0,0 +1,15 @@
+package anomaly
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	l "github.com/redhatinsights/payload-tracker-go/internal/logging"
+)
+
+func TestAnomaly(t *testing.T) {
+	RegisterFailHandler(Fail)
+	l.InitLogger()
+	RunSpecs(t, "Anomaly Suite")
+}
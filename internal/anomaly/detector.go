@@ -0,0 +1,214 @@
+package anomaly
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/config"
+	"github.com/redhatinsights/payload-tracker-go/internal/endpoints"
+	l "github.com/redhatinsights/payload-tracker-go/internal/logging"
+	models "github.com/redhatinsights/payload-tracker-go/internal/models/db"
+	"github.com/redhatinsights/payload-tracker-go/internal/queries"
+)
+
+// distinct error message fingerprints read per run
+const messageLimit = 1000
+
+// Detector compares the recent error rate of each service to its baseline from the hourly
+// rollups and learns the error message fingerprints of each service, recording spikes and
+// never seen before fingerprints as anomalies. Replicas can run it concurrently, an anomaly is recorded once.
+type Detector struct {
+	db               *gorm.DB
+	interval         time.Duration
+	window           time.Duration
+	baseline         time.Duration
+	factor           float64
+	minErrors        int64
+	learning         time.Duration
+	maxNewMessages   int
+	messageRetention time.Duration
+	retention        time.Duration
+}
+
+func NewDetector(cfg *config.TrackerConfig, db *gorm.DB) *Detector {
+	c := cfg.AnomalyConfig
+	return &Detector{
+		db:               db,
+		interval:         time.Duration(c.IntervalSeconds) * time.Second,
+		window:           time.Duration(c.WindowMinutes) * time.Minute,
+		baseline:         time.Duration(c.BaselineHours) * time.Hour,
+		factor:           c.SpikeFactor,
+		minErrors:        int64(c.MinErrors),
+		learning:         time.Duration(c.LearningHours) * time.Hour,
+		maxNewMessages:   c.MaxNewMessages,
+		messageRetention: time.Duration(c.MessageRetentionDays) * 24 * time.Hour,
+		retention:        time.Duration(c.RetentionDays) * 24 * time.Hour,
+	}
+}
+
+// Run detects anomalies every interval until the context is done
+func (d *Detector) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		d.Detect(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Detect checks the window ending at now for error rate spikes and new error messages
+func (d *Detector) Detect(ctx context.Context, now time.Time) {
+	db := d.db.WithContext(ctx)
+	now = now.UTC()
+	from := now.Add(-d.window)
+
+	if err := d.detectSpikes(db, from, now); err != nil {
+		l.Log.Errorf("Unable to detect error rate spikes: %v", err)
+	}
+	if err := d.detectNewMessages(db, from, now); err != nil {
+		l.Log.Errorf("Unable to detect new error messages: %v", err)
+	}
+
+	if err := queries.DeleteErrorMessagesBefore(db, now.Add(-d.messageRetention)).Error; err != nil {
+		l.Log.Errorf("Unable to delete old error messages: %v", err)
+	}
+	if err := queries.DeleteAnomaliesBefore(db, now.Add(-d.retention)).Error; err != nil {
+		l.Log.Errorf("Unable to delete old anomalies: %v", err)
+	}
+}
+
+func (d *Detector) detectSpikes(db *gorm.DB, from time.Time, now time.Time) error {
+	recent, err := queries.RecentErrorCounts(db, from, now)
+	if err != nil {
+		return err
+	}
+
+	// the baseline ends with the last full hour before the window
+	baselineEnd := from.Truncate(time.Hour)
+	baseline, err := queries.BaselineErrorCounts(db, baselineEnd.Add(-d.baseline), baselineEnd)
+	if err != nil {
+		return err
+	}
+
+	services := make(map[string]*[2]queries.ServiceErrorCounts)
+	for i, counts := range [][]queries.ServiceErrorCounts{recent, baseline} {
+		for _, c := range counts {
+			if services[c.Service] == nil {
+				services[c.Service] = &[2]queries.ServiceErrorCounts{}
+			}
+			services[c.Service][i] = c
+		}
+	}
+
+	spiking := []string{}
+	for service, counts := range services {
+		rate, baselineRate := errorRate(counts[0]), errorRate(counts[1])
+		spike := d.isSpike(counts[0].Errors, rate, baselineRate)
+		endpoints.SetServiceErrorRate(service, rate, baselineRate, spike)
+		if !spike {
+			continue
+		}
+
+		spiking = append(spiking, service)
+		opened, err := queries.OpenAnomaly(db, models.Anomaly{
+			Kind:         queries.AnomalyErrorRateSpike,
+			Service:      service,
+			Errors:       counts[0].Errors,
+			Total:        counts[0].Total,
+			ErrorRate:    rate,
+			BaselineRate: baselineRate,
+			DetectedAt:   now,
+			LastSeenAt:   now,
+		})
+		if err != nil {
+			return err
+		}
+		if opened {
+			endpoints.IncAnomalies(queries.AnomalyErrorRateSpike, service)
+			l.Log.Warnf("Error rate of %s spiked to %.3f over a baseline of %.3f", service, rate, baselineRate)
+		}
+	}
+
+	return queries.ResolveAnomalies(db, queries.AnomalyErrorRateSpike, spiking, now).Error
+}
+
+// isSpike is true when there are enough errors and the error rate is factor times the baseline,
+// any error rate is a spike over a baseline without errors
+func (d *Detector) isSpike(errors int64, rate float64, baselineRate float64) bool {
+	return errors >= d.minErrors && rate > baselineRate && rate >= baselineRate*d.factor
+}
+
+func errorRate(counts queries.ServiceErrorCounts) float64 {
+	if counts.Total == 0 {
+		return 0
+	}
+	return float64(counts.Errors) / float64(counts.Total)
+}
+
+func (d *Detector) detectNewMessages(db *gorm.DB, from time.Time, now time.Time) error {
+	recent, err := queries.RecentErrorMessages(db, from, now, messageLimit)
+	if err != nil {
+		return err
+	}
+
+	services := make(map[string][]queries.RecentErrorMessage)
+	for _, m := range recent {
+		services[m.Service] = append(services[m.Service], m)
+	}
+
+	for service, messages := range services {
+		// the messages of a service are learnt before new ones are flagged
+		since, found, err := queries.ErrorMessagesLearntSince(db, service)
+		if err != nil {
+			return err
+		}
+		learning := !found || since.After(now.Add(-d.learning))
+
+		flagged := 0
+		fingerprints := make([]string, 0, len(messages))
+		for _, message := range messages {
+			fingerprints = append(fingerprints, message.Fingerprint)
+
+			learnt, err := queries.LearnErrorMessage(db, models.ServiceErrorMessage{Service: service, Fingerprint: message.Fingerprint, FirstSeen: now, LastSeen: now})
+			if err != nil {
+				return err
+			}
+			if !learnt || learning {
+				continue
+			}
+
+			endpoints.IncAnomalies(queries.AnomalyNewErrorMessage, service)
+			if flagged >= d.maxNewMessages {
+				continue
+			}
+			flagged++
+
+			l.Log.Warnf("New error message from %s: %s", service, message.Message)
+			if err := queries.InsertAnomaly(db, &models.Anomaly{
+				Kind:        queries.AnomalyNewErrorMessage,
+				Service:     service,
+				StatusMsg:   message.Message,
+				Fingerprint: message.Fingerprint,
+				DetectedAt:  now,
+				LastSeenAt:  now,
+				ResolvedAt:  &now,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := queries.TouchErrorMessages(db, service, fingerprints, now).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package anomaly
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/config"
+	"github.com/redhatinsights/payload-tracker-go/internal/fingerprint"
+	models "github.com/redhatinsights/payload-tracker-go/internal/models/db"
+	"github.com/redhatinsights/payload-tracker-go/internal/queries"
+	"github.com/redhatinsights/payload-tracker-go/internal/utils/test"
+)
+
+var _ = Describe("Detector", func() {
+	var (
+		detector *Detector
+		service  string
+		now      time.Time
+	)
+
+	db := test.WithDatabase()
+	fixtures := test.WithPayloads(db)
+
+	// report stores count statuses of the service dated date
+	report := func(status string, msg string, date time.Time, count int) {
+		for i := 0; i < count; i++ {
+			fixtures.Create(models.Payloads{CreatedAt: date}, models.PayloadStatuses{
+				ServiceId:   fixtures.ServiceId(service),
+				StatusId:    fixtures.StatusId(status),
+				StatusMsg:   msg,
+				Fingerprint: fingerprint.Fingerprint(msg),
+				Date:        date,
+			})
+		}
+	}
+
+	anomalies := func(kind string) []models.Anomaly {
+		var anomalies []models.Anomaly
+		db().Where("kind = ? AND service = ?", kind, service).Order("id").Find(&anomalies)
+		return anomalies
+	}
+
+	BeforeEach(func() {
+		cfg := *config.Get()
+		cfg.AnomalyConfig.WindowMinutes = 15
+		cfg.AnomalyConfig.BaselineHours = 24
+		cfg.AnomalyConfig.SpikeFactor = 3
+		cfg.AnomalyConfig.MinErrors = 10
+		cfg.AnomalyConfig.LearningHours = 24
+		detector = NewDetector(&cfg, db())
+
+		service = "anomaly-" + uuid.New().String()[:8]
+
+		now = time.Date(2003, 4, 5, 12, 30, 0, 0, time.UTC)
+
+		// a baseline error rate of 1%
+		rollups := []models.PayloadStatusRollup{
+			{Hour: now.Add(-3 * time.Hour).Truncate(time.Hour), Service: service, Status: "success", Count: 990},
+			{Hour: now.Add(-3 * time.Hour).Truncate(time.Hour), Service: service, Status: "error", Count: 10},
+		}
+		Expect(db().Create(&rollups).Error).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		db().Where("service = ?", service).Delete(&models.PayloadStatusRollup{})
+		db().Where("service = ?", service).Delete(&models.ServiceErrorMessage{})
+		db().Where("service = ?", service).Delete(&models.Anomaly{})
+	})
+
+	It("Should open an error rate spike until the error rate drops", func() {
+		report("success", "", now.Add(-5*time.Minute), 10)
+		report("error", "", now.Add(-5*time.Minute), 10)
+
+		detector.Detect(context.Background(), now)
+		detector.Detect(context.Background(), now.Add(time.Minute))
+
+		spikes := anomalies(queries.AnomalyErrorRateSpike)
+		Expect(spikes).To(HaveLen(1))
+		Expect(spikes[0].Errors).To(Equal(int64(10)))
+		Expect(spikes[0].Total).To(Equal(int64(20)))
+		Expect(spikes[0].ErrorRate).To(BeNumerically("~", 0.5))
+		Expect(spikes[0].BaselineRate).To(BeNumerically("~", 0.01))
+		Expect(spikes[0].LastSeenAt.UTC()).To(Equal(now.Add(time.Minute)))
+		Expect(spikes[0].ResolvedAt).To(BeNil())
+
+		detector.Detect(context.Background(), now.Add(time.Hour))
+
+		spikes = anomalies(queries.AnomalyErrorRateSpike)
+		Expect(spikes).To(HaveLen(1))
+		Expect(spikes[0].ResolvedAt).ToNot(BeNil())
+	})
+
+	It("Should not flag a spike with few errors", func() {
+		report("error", "", now.Add(-5*time.Minute), 9)
+
+		detector.Detect(context.Background(), now)
+
+		Expect(anomalies(queries.AnomalyErrorRateSpike)).To(BeEmpty())
+	})
+
+	It("Should flag error messages never seen before once the service was learnt", func() {
+		report("error", "disk /dev/sda1 full", now.Add(-5*time.Minute), 1)
+		detector.Detect(context.Background(), now)
+
+		// the first messages of a service are only learnt
+		Expect(anomalies(queries.AnomalyNewErrorMessage)).To(BeEmpty())
+
+		later := now.Add(25 * time.Hour)
+		// the same message with other ids is known
+		report("error", "disk /dev/sdb2 full", later.Add(-5*time.Minute), 1)
+		report("error", "connection refused", later.Add(-5*time.Minute), 1)
+		detector.Detect(context.Background(), later)
+		detector.Detect(context.Background(), later.Add(time.Minute))
+
+		messages := anomalies(queries.AnomalyNewErrorMessage)
+		Expect(messages).To(HaveLen(1))
+		Expect(messages[0].StatusMsg).To(Equal("connection refused"))
+		Expect(messages[0].Fingerprint).To(Equal(fingerprint.Fingerprint("connection refused")))
+		Expect(messages[0].ResolvedAt).ToNot(BeNil())
+	})
+})
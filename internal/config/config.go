@@ -10,20 +10,35 @@ import (
 )
 
 type TrackerConfig struct {
-	Environment                 string
-	PublicPort                  string
-	MetricsPort                 string
-	LogLevel                    string
-	Hostname                    string
-	StorageBrokerURL            string
-	StorageBrokerURLRole        string
-	StorageBrokerRequestTimeout int
-	KafkaConfig                 KafkaCfg
-	CloudwatchConfig            CloudwatchCfg
-	DatabaseConfig              DatabaseCfg
-	RequestConfig               RequestCfg
-	KibanaConfig                KibanaCfg
-	DebugConfig                 DebugCfg
+	Environment                    string
+	PublicPort                     string
+	MetricsPort                    string
+	LogLevel                       string
+	Hostname                       string
+	StorageBrokerURL               string
+	StorageBrokerURLRole           string
+	StorageBrokerRequestTimeout    int
+	StorageBrokerMaxRetries        int
+	StorageBrokerRetryBackoffMs    int
+	StorageBrokerBreakerThreshold  int
+	StorageBrokerBreakerCooldownMs int
+	StorageBrokerLinkCacheSeconds  int
+	StorageBrokerLinkCacheSize     int
+	AuditRole                      string
+	KafkaConfig                    KafkaCfg
+	CloudwatchConfig               CloudwatchCfg
+	DatabaseConfig                 DatabaseCfg
+	RequestConfig                  RequestCfg
+	KibanaConfig                   KibanaCfg
+	LogLinksConfig                 LogLinksCfg
+	DebugConfig                    DebugCfg
+	TracingConfig                  TracingCfg
+	HealthConfig                   HealthCfg
+	S3Config                       S3Cfg
+	WebhookConfig                  WebhookCfg
+	LifecycleConfig                LifecycleCfg
+	SLOConfig                      SLOCfg
+	AnomalyConfig                  AnomalyCfg
 }
 
 type KafkaCfg struct {
@@ -34,8 +49,11 @@ type KafkaCfg struct {
 	KafkaRequestRequiredAcks   int
 	KafkaMessageSendMaxRetries int
 	KafkaRetryBackoffMs        int
+	KafkaStatsInterval         int
+	KafkaMaxLag                int64
 	KafkaBootstrapServers      string
 	KafkaTopic                 string
+	KafkaLifecycleTopic        string
 	KafkaUsername              string
 	KafkaPassword              string
 	KafkaCA                    string
@@ -44,12 +62,24 @@ type KafkaCfg struct {
 }
 
 type DatabaseCfg struct {
-	DBUser     string
-	DBPassword string
-	DBName     string
-	DBHost     string
-	DBPort     string
-	RDSCa      string
+	DBDriver     string
+	DBUser       string
+	DBPassword   string
+	DBName       string
+	DBHost       string
+	DBPort       string
+	DBSqlitePath string
+	RDSCa        string
+
+	DBMaxOpenConns     int
+	DBMaxIdleConns     int
+	DBConnMaxLifetime  int
+	DBConnMaxIdleTime  int
+	DBStatementTimeout int
+
+	DBReplicaHosts         []string
+	DBReplicaMaxLag        int
+	DBReplicaCheckInterval int
 }
 
 type CloudwatchCfg struct {
@@ -71,10 +101,90 @@ type KibanaCfg struct {
 	ServiceField string
 }
 
+type LogLinksCfg struct {
+	Providers      []string
+	PaddingMinutes int
+
+	LokiURL          string
+	LokiDatasource   string
+	LokiSelector     string
+	LokiServiceLabel string
+
+	SplunkURL          string
+	SplunkIndex        string
+	SplunkServiceField string
+
+	TemplateName string
+	TemplateURL  string
+}
+
 type DebugCfg struct {
 	LogStatusJson bool
 }
 
+type HealthCfg struct {
+	CacheSeconds   int
+	CheckTimeoutMs int
+}
+
+type S3Cfg struct {
+	Bucket         string
+	KeyTemplate    string
+	Region         string
+	Endpoint       string
+	AccessKey      string
+	SecretKey      string
+	ForcePathStyle bool
+	URLExpiry      int
+}
+
+type WebhookCfg struct {
+	Enabled            bool
+	Role               string
+	RefreshSeconds     int
+	DispatchIntervalMs int
+	BatchSize          int
+	TimeoutMs          int
+	MaxAttempts        int
+	BackoffMs          int
+	MaxBackoffSeconds  int
+	RetentionDays      int
+}
+
+type LifecycleCfg struct {
+	Enabled                   bool
+	StuckAfterMinutes         int
+	StuckLookbackHours        int
+	StuckCheckIntervalSeconds int
+	StuckBatchSize            int
+}
+
+type SLOCfg struct {
+	Definitions               string
+	Windows                   []string
+	EvaluationIntervalSeconds int
+	GraceSeconds              int
+}
+
+type AnomalyCfg struct {
+	Enabled              bool
+	IntervalSeconds      int
+	WindowMinutes        int
+	BaselineHours        int
+	SpikeFactor          float64
+	MinErrors            int
+	LearningHours        int
+	MaxNewMessages       int
+	MessageRetentionDays int
+	RetentionDays        int
+}
+
+type TracingCfg struct {
+	Enabled      bool
+	OTLPEndpoint string
+	SampleRatio  float64
+}
+
 // Get sets each config option with its defaults
 func Get() *TrackerConfig {
 	options := viper.New()
@@ -101,6 +211,8 @@ func Get() *TrackerConfig {
 	options.SetDefault("kafka.request.required.acks", -1) // -1 == "all"
 	options.SetDefault("kafka.message.send.max.retries", 15)
 	options.SetDefault("kafka.retry.backoff.ms", 100)
+	options.SetDefault("kafka.statistics.interval.ms", 10000)
+	options.SetDefault("kafka.max.lag", 100000) // readiness fails above this per partition lag, 0 disables the check
 
 	// request config
 	options.SetDefault("validate.request.id.length", 32)
@@ -111,20 +223,111 @@ func Get() *TrackerConfig {
 	options.SetDefault("storageBrokerURL", "http://storage-broker-processor:8000/archive/url")
 	options.SetDefault("storageBrokerURLRole", "platform-archive-download")
 	options.SetDefault("storageBrokerRequestTimeout", 35000)
+	options.SetDefault("storageBrokerMaxRetries", 2)
+	options.SetDefault("storageBrokerRetryBackoffMs", 200)
+	options.SetDefault("storageBrokerBreakerThreshold", 5) // consecutive failed requests, 0 disables the breaker
+	options.SetDefault("storageBrokerBreakerCooldownMs", 30000)
+	options.SetDefault("storageBrokerLinkCacheSeconds", 60) // 0 disables caching
+	options.SetDefault("storageBrokerLinkCacheSize", 1000)  // least recently used links are evicted beyond it
+	options.SetDefault("auditRole", "platform-archive-audit")
+
+	// s3 requestor config
+	options.SetDefault("s3.bucket", "insights-upload-perma")
+	options.SetDefault("s3.key.template", "{request_id}") // {request_id} is replaced with the request id
+	options.SetDefault("s3.region", "us-east-1")
+	options.SetDefault("s3.endpoint", "") // for S3 compatible stores, AWS when empty
+	options.SetDefault("s3.access.key", "")
+	options.SetDefault("s3.secret.key", "") // the default AWS credential chain is used without keys
+	options.SetDefault("s3.force.path.style", false)
+	options.SetDefault("s3.url.expiry.seconds", 3600)
+	// webhook config
+	options.SetDefault("webhooks.enabled", true)
+	options.SetDefault("webhooks.role", "platform-payload-tracker-webhooks") // needed to manage subscriptions
+	options.SetDefault("webhooks.refresh.seconds", 30)                       // how often the consumer reloads subscriptions
+	options.SetDefault("webhooks.dispatch.interval.ms", 1000)
+	options.SetDefault("webhooks.batch.size", 50)
+	options.SetDefault("webhooks.timeout.ms", 5000)
+	options.SetDefault("webhooks.max.attempts", 8)
+	options.SetDefault("webhooks.backoff.ms", 1000) // doubled after every failed attempt
+	options.SetDefault("webhooks.max.backoff.seconds", 3600)
+	options.SetDefault("webhooks.retention.days", 7) // delivered and failed deliveries are deleted after it, 0 keeps them
+	// payload lifecycle events
+	options.SetDefault("lifecycle.enabled", true)
+	options.SetDefault("lifecycle.stuck.after.minutes", 60)  // payloads without a new status for this long are stuck
+	options.SetDefault("lifecycle.stuck.lookback.hours", 24) // older payloads are never declared stuck
+	options.SetDefault("lifecycle.stuck.check.interval.seconds", 60)
+	options.SetDefault("lifecycle.stuck.batch.size", 500)
+	// SLOs, a JSON list of {"name", "service", "status", "threshold_seconds", "objective"}
+	options.SetDefault("slo.definitions", "[]")
+	options.SetDefault("slo.windows", "5m,30m,1h,6h,24h,72h") // burn rates are reported for each window
+	options.SetDefault("slo.evaluation.interval.seconds", 60)
+	options.SetDefault("slo.grace.seconds", 120) // extra time for late statuses before a payload is evaluated
+	// anomaly detection on the error statuses of each service
+	options.SetDefault("anomaly.enabled", true)
+	options.SetDefault("anomaly.interval.seconds", 60)
+	options.SetDefault("anomaly.window.minutes", 15)   // recent error rates are compared to the baseline
+	options.SetDefault("anomaly.baseline.hours", 168)  // rolling baseline before the window, from the hourly rollups
+	options.SetDefault("anomaly.spike.factor", 3.0)    // error rate over the baseline rate that is a spike
+	options.SetDefault("anomaly.min.errors", 10)       // fewer errors in the window are never a spike
+	options.SetDefault("anomaly.learning.hours", 24)   // new messages of a service are only flagged once it was learnt this long
+	options.SetDefault("anomaly.max.new.messages", 20) // flagged per service and run, the rest are only learnt
+	options.SetDefault("anomaly.message.retention.days", 30)
+	options.SetDefault("anomaly.retention.days", 30)
+
 	// kibana config
 	options.SetDefault("kibana.url", "https://kibana.apps.crcs02ue1.urby.p1.openshiftapps.com/app/kibana#/discover")
 	options.SetDefault("kibana.index", "43c5fed0-d5ce-11ea-b58c-a7c95afd7a5d") // the index grabbed from the kibana url
 	options.SetDefault("kibana.service.field", "app")
 
+	// log links config, comma separated list of kibana, loki, splunk and template
+	options.SetDefault("loglinks.providers", "kibana")
+	options.SetDefault("loglinks.padding.minutes", 5) // added around the first and last status dates
+	options.SetDefault("loki.url", "")                // grafana base url
+	options.SetDefault("loki.datasource", "")
+	options.SetDefault("loki.selector", `{app=~".+"}`) // used when no service is given
+	options.SetDefault("loki.service.label", "app")
+	options.SetDefault("splunk.url", "")
+	options.SetDefault("splunk.index", "main")
+	options.SetDefault("splunk.service.field", "app")
+	// {request_id}, {service}, {from}, {to}, {from_ms} and {to_ms} are replaced in the template url
+	options.SetDefault("loglinks.template.name", "logs")
+	options.SetDefault("loglinks.template.url", "")
+
 	// debug config
 	options.SetDefault("debug.log.status.json", false)
 
+	// health config, results are cached so that probes don't hit the dependencies every time
+	options.SetDefault("health.cache.seconds", 5)
+	options.SetDefault("health.check.timeout.ms", 800)
+
+	// tracing config, spans are exported to an OTLP/HTTP collector such as http://localhost:4318
+	options.SetDefault("tracing.enabled", false)
+	options.SetDefault("tracing.otlp.endpoint", "http://localhost:4318")
+	options.SetDefault("tracing.sample.ratio", 1.0)
+
+	// database driver, "postgres" or "sqlite" (for local development only)
+	options.SetDefault("db.driver", "postgres")
+	options.SetDefault("db.sqlite.path", "payload-tracker.db")
+
+	// database connection pool, lifetimes in seconds and statement timeout in milliseconds (0 disables it)
+	options.SetDefault("db.max.open.conns", 20)
+	options.SetDefault("db.max.idle.conns", 10)
+	options.SetDefault("db.conn.max.lifetime", 1800)
+	options.SetDefault("db.conn.max.idle.time", 300)
+	options.SetDefault("db.statement.timeout.ms", 30000)
+
+	// read replicas, comma separated host[:port] list used for API reads
+	options.SetDefault("db.replica.hosts", "")
+	options.SetDefault("db.replica.max.lag.seconds", 30)
+	options.SetDefault("db.replica.check.interval.seconds", 10)
+
 	if clowder.IsClowderEnabled() {
 		cfg := clowder.LoadedConfig
 
 		// kafka
 		options.SetDefault("kafka.bootstrap.servers", strings.Join(clowder.KafkaServers, ","))
 		options.SetDefault("topic.payload.status", clowder.KafkaTopics["platform.payload-status"].Name)
+		options.SetDefault("topic.payload.lifecycle", clowder.KafkaTopics["platform.payload-lifecycle"].Name)
 		// ports
 		options.SetDefault("publicPort", cfg.PublicPort)
 		options.SetDefault("metricsPort", cfg.MetricsPort)
@@ -144,6 +347,7 @@ func Get() *TrackerConfig {
 	} else {
 		options.SetDefault("kafka.bootstrap.servers", "localhost:29092")
 		options.SetDefault("topic.payload.status", "platform.payload-status")
+		options.SetDefault("topic.payload.lifecycle", "platform.payload-lifecycle")
 		// ports
 		options.SetDefault("publicPort", "8080")
 		options.SetDefault("metricsPort", "8081")
@@ -164,14 +368,21 @@ func Get() *TrackerConfig {
 	options.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 
 	trackerCfg := &TrackerConfig{
-		Environment:                 options.GetString("Environment"),
-		Hostname:                    options.GetString("Hostname"),
-		LogLevel:                    options.GetString("logLevel"),
-		PublicPort:                  options.GetString("publicPort"),
-		MetricsPort:                 options.GetString("metricsPort"),
-		StorageBrokerURL:            options.GetString("storageBrokerURL"),
-		StorageBrokerURLRole:        options.GetString("storageBrokerURLRole"),
-		StorageBrokerRequestTimeout: options.GetInt("storageBrokerRequestTimeout"),
+		Environment:                    options.GetString("Environment"),
+		Hostname:                       options.GetString("Hostname"),
+		LogLevel:                       options.GetString("logLevel"),
+		PublicPort:                     options.GetString("publicPort"),
+		MetricsPort:                    options.GetString("metricsPort"),
+		StorageBrokerURL:               options.GetString("storageBrokerURL"),
+		StorageBrokerURLRole:           options.GetString("storageBrokerURLRole"),
+		StorageBrokerRequestTimeout:    options.GetInt("storageBrokerRequestTimeout"),
+		StorageBrokerMaxRetries:        options.GetInt("storageBrokerMaxRetries"),
+		StorageBrokerRetryBackoffMs:    options.GetInt("storageBrokerRetryBackoffMs"),
+		StorageBrokerBreakerThreshold:  options.GetInt("storageBrokerBreakerThreshold"),
+		StorageBrokerBreakerCooldownMs: options.GetInt("storageBrokerBreakerCooldownMs"),
+		StorageBrokerLinkCacheSeconds:  options.GetInt("storageBrokerLinkCacheSeconds"),
+		StorageBrokerLinkCacheSize:     options.GetInt("storageBrokerLinkCacheSize"),
+		AuditRole:                      options.GetString("auditRole"),
 		KafkaConfig: KafkaCfg{
 			KafkaTimeout:               options.GetInt("kafka.timeout"),
 			KafkaGroupID:               options.GetString("kafka.group.id"),
@@ -180,15 +391,30 @@ func Get() *TrackerConfig {
 			KafkaRequestRequiredAcks:   options.GetInt("kafka.request.required.acks"),
 			KafkaMessageSendMaxRetries: options.GetInt("kafka.message.send.max.retries"),
 			KafkaRetryBackoffMs:        options.GetInt("kafka.retry.backoff.ms"),
+			KafkaStatsInterval:         options.GetInt("kafka.statistics.interval.ms"),
+			KafkaMaxLag:                options.GetInt64("kafka.max.lag"),
 			KafkaBootstrapServers:      options.GetString("kafka.bootstrap.servers"),
 			KafkaTopic:                 options.GetString("topic.payload.status"),
+			KafkaLifecycleTopic:        options.GetString("topic.payload.lifecycle"),
 		},
 		DatabaseConfig: DatabaseCfg{
-			DBUser:     options.GetString("db.user"),
-			DBPassword: options.GetString("db.password"),
-			DBName:     options.GetString("db.name"),
-			DBHost:     options.GetString("db.host"),
-			DBPort:     options.GetString("db.port"),
+			DBDriver:     options.GetString("db.driver"),
+			DBUser:       options.GetString("db.user"),
+			DBPassword:   options.GetString("db.password"),
+			DBName:       options.GetString("db.name"),
+			DBHost:       options.GetString("db.host"),
+			DBPort:       options.GetString("db.port"),
+			DBSqlitePath: options.GetString("db.sqlite.path"),
+
+			DBMaxOpenConns:     options.GetInt("db.max.open.conns"),
+			DBMaxIdleConns:     options.GetInt("db.max.idle.conns"),
+			DBConnMaxLifetime:  options.GetInt("db.conn.max.lifetime"),
+			DBConnMaxIdleTime:  options.GetInt("db.conn.max.idle.time"),
+			DBStatementTimeout: options.GetInt("db.statement.timeout.ms"),
+
+			DBReplicaHosts:         splitList(options.GetString("db.replica.hosts")),
+			DBReplicaMaxLag:        options.GetInt("db.replica.max.lag.seconds"),
+			DBReplicaCheckInterval: positiveInt(options, "db.replica.check.interval.seconds", 10),
 		},
 		CloudwatchConfig: CloudwatchCfg{
 			CWLogGroup:  options.GetString("logGroup"),
@@ -206,9 +432,78 @@ func Get() *TrackerConfig {
 			Index:        options.GetString("kibana.index"),
 			ServiceField: options.GetString("kibana.service.field"),
 		},
+		LogLinksConfig: LogLinksCfg{
+			Providers:          splitList(options.GetString("loglinks.providers")),
+			PaddingMinutes:     options.GetInt("loglinks.padding.minutes"),
+			LokiURL:            options.GetString("loki.url"),
+			LokiDatasource:     options.GetString("loki.datasource"),
+			LokiSelector:       options.GetString("loki.selector"),
+			LokiServiceLabel:   options.GetString("loki.service.label"),
+			SplunkURL:          options.GetString("splunk.url"),
+			SplunkIndex:        options.GetString("splunk.index"),
+			SplunkServiceField: options.GetString("splunk.service.field"),
+			TemplateName:       options.GetString("loglinks.template.name"),
+			TemplateURL:        options.GetString("loglinks.template.url"),
+		},
 		DebugConfig: DebugCfg{
 			LogStatusJson: options.GetBool("debug.log.status.json"),
 		},
+		HealthConfig: HealthCfg{
+			CacheSeconds:   options.GetInt("health.cache.seconds"),
+			CheckTimeoutMs: options.GetInt("health.check.timeout.ms"),
+		},
+		S3Config: S3Cfg{
+			Bucket:         options.GetString("s3.bucket"),
+			KeyTemplate:    options.GetString("s3.key.template"),
+			Region:         options.GetString("s3.region"),
+			Endpoint:       options.GetString("s3.endpoint"),
+			AccessKey:      options.GetString("s3.access.key"),
+			SecretKey:      options.GetString("s3.secret.key"),
+			ForcePathStyle: options.GetBool("s3.force.path.style"),
+			URLExpiry:      options.GetInt("s3.url.expiry.seconds"),
+		},
+		WebhookConfig: WebhookCfg{
+			Enabled:            options.GetBool("webhooks.enabled"),
+			Role:               options.GetString("webhooks.role"),
+			RefreshSeconds:     options.GetInt("webhooks.refresh.seconds"),
+			DispatchIntervalMs: positiveInt(options, "webhooks.dispatch.interval.ms", 1000),
+			BatchSize:          options.GetInt("webhooks.batch.size"),
+			TimeoutMs:          options.GetInt("webhooks.timeout.ms"),
+			MaxAttempts:        options.GetInt("webhooks.max.attempts"),
+			BackoffMs:          options.GetInt("webhooks.backoff.ms"),
+			MaxBackoffSeconds:  options.GetInt("webhooks.max.backoff.seconds"),
+			RetentionDays:      options.GetInt("webhooks.retention.days"),
+		},
+		LifecycleConfig: LifecycleCfg{
+			Enabled:                   options.GetBool("lifecycle.enabled"),
+			StuckAfterMinutes:         options.GetInt("lifecycle.stuck.after.minutes"),
+			StuckLookbackHours:        options.GetInt("lifecycle.stuck.lookback.hours"),
+			StuckCheckIntervalSeconds: positiveInt(options, "lifecycle.stuck.check.interval.seconds", 60),
+			StuckBatchSize:            options.GetInt("lifecycle.stuck.batch.size"),
+		},
+		SLOConfig: SLOCfg{
+			Definitions:               options.GetString("slo.definitions"),
+			Windows:                   splitList(options.GetString("slo.windows")),
+			EvaluationIntervalSeconds: positiveInt(options, "slo.evaluation.interval.seconds", 60),
+			GraceSeconds:              options.GetInt("slo.grace.seconds"),
+		},
+		AnomalyConfig: AnomalyCfg{
+			Enabled:              options.GetBool("anomaly.enabled"),
+			IntervalSeconds:      positiveInt(options, "anomaly.interval.seconds", 60),
+			WindowMinutes:        options.GetInt("anomaly.window.minutes"),
+			BaselineHours:        options.GetInt("anomaly.baseline.hours"),
+			SpikeFactor:          options.GetFloat64("anomaly.spike.factor"),
+			MinErrors:            options.GetInt("anomaly.min.errors"),
+			LearningHours:        options.GetInt("anomaly.learning.hours"),
+			MaxNewMessages:       options.GetInt("anomaly.max.new.messages"),
+			MessageRetentionDays: options.GetInt("anomaly.message.retention.days"),
+			RetentionDays:        options.GetInt("anomaly.retention.days"),
+		},
+		TracingConfig: TracingCfg{
+			Enabled:      options.GetBool("tracing.enabled"),
+			OTLPEndpoint: options.GetString("tracing.otlp.endpoint"),
+			SampleRatio:  options.GetFloat64("tracing.sample.ratio"),
+		},
 	}
 
 	if clowder.IsClowderEnabled() {
@@ -246,3 +541,23 @@ func Get() *TrackerConfig {
 
 	return trackerCfg
 }
+
+// splitList splits a comma separated option into its trimmed, non-empty values
+func splitList(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// positiveInt returns an int option, or the fallback when it isn't positive. Intervals
+// are read with it as tickers panic on zero or negative durations.
+func positiveInt(options *viper.Viper, key string, fallback int) int {
+	if value := options.GetInt(key); value > 0 {
+		return value
+	}
+	return fallback
+}
@@ -2,23 +2,54 @@ package db
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/redhatinsights/payload-tracker-go/internal/config"
 	l "github.com/redhatinsights/payload-tracker-go/internal/logging"
+	"github.com/redhatinsights/payload-tracker-go/internal/tracing"
 
 	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
 var DB *gorm.DB
 
 func DbConnect(cfg *config.TrackerConfig) {
+	var dialector gorm.Dialector
+
+	switch cfg.DatabaseConfig.DBDriver {
+	case "sqlite":
+		dialector = sqliteDialector(cfg)
+	case "postgres", "":
+		dialector = postgresDialector(cfg, cfg.DatabaseConfig.DBHost, cfg.DatabaseConfig.DBPort)
+	default:
+		l.Log.Fatalf("Database driver %s not supported", cfg.DatabaseConfig.DBDriver)
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{})
+	if err != nil {
+		l.Log.Fatal(err)
+	}
+
+	if err := configurePool(cfg, db); err != nil {
+		l.Log.Fatal(err)
+	}
+
+	if err := tracing.RegisterCallbacks(db); err != nil {
+		l.Log.Fatal(err)
+	}
+
+	DB = db
+
+	l.Log.Info("DB initialization complete")
+}
+
+func postgresDialector(cfg *config.TrackerConfig, host string, port string) gorm.Dialector {
 	var (
 		user     = cfg.DatabaseConfig.DBUser
 		password = cfg.DatabaseConfig.DBPassword
 		dbname   = cfg.DatabaseConfig.DBName
-		host     = cfg.DatabaseConfig.DBHost
-		port     = cfg.DatabaseConfig.DBPort
 		sslmode  = "disable"
 	)
 
@@ -28,12 +59,33 @@ func DbConnect(cfg *config.TrackerConfig) {
 
 	dsn := fmt.Sprintf("user=%s password=%s dbname=%s host=%s port=%s sslmode=%s", user, password, dbname, host, port, sslmode)
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	// unknown DSN keys are sent to the server as runtime parameters for every connection
+	if cfg.DatabaseConfig.DBStatementTimeout > 0 {
+		dsn += fmt.Sprintf(" statement_timeout=%d", cfg.DatabaseConfig.DBStatementTimeout)
+	}
+
+	return postgres.Open(dsn)
+}
+
+// sqliteDialector opens a single local file, meant for local development and demos only.
+// WAL mode and a busy timeout let pt-api and pt-consumer share the file at the same time.
+func sqliteDialector(cfg *config.TrackerConfig) gorm.Dialector {
+	dsn := fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=5000&_foreign_keys=off", cfg.DatabaseConfig.DBSqlitePath)
+
+	return sqlite.Open(dsn)
+}
+
+// configurePool applies the connection pool settings to the sql.DB underneath gorm
+func configurePool(cfg *config.TrackerConfig, db *gorm.DB) error {
+	sqlDB, err := db.DB()
 	if err != nil {
-		l.Log.Fatal(err)
+		return err
 	}
 
-	DB = db
+	sqlDB.SetMaxOpenConns(cfg.DatabaseConfig.DBMaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.DatabaseConfig.DBMaxIdleConns)
+	sqlDB.SetConnMaxLifetime(time.Duration(cfg.DatabaseConfig.DBConnMaxLifetime) * time.Second)
+	sqlDB.SetConnMaxIdleTime(time.Duration(cfg.DatabaseConfig.DBConnMaxIdleTime) * time.Second)
 
-	l.Log.Info("DB initialization complete")
+	return nil
 }
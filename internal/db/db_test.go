@@ -0,0 +1,64 @@
+package db
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/config"
+)
+
+var _ = Describe("Connection settings", func() {
+	var cfg config.TrackerConfig
+
+	BeforeEach(func() {
+		cfg = *config.Get()
+	})
+
+	dsn := func() string {
+		return postgresDialector(&cfg, "db.example.com", "5433").(*postgres.Dialector).Config.DSN
+	}
+
+	It("Leaves the statement timeout to the server when it isn't set", func() {
+		cfg.DatabaseConfig.DBStatementTimeout = 0
+		Expect(dsn()).To(ContainSubstring("host=db.example.com port=5433"))
+		Expect(dsn()).ToNot(ContainSubstring("statement_timeout"))
+	})
+
+	It("Sets the statement timeout of every connection", func() {
+		cfg.DatabaseConfig.DBStatementTimeout = 30000
+		Expect(dsn()).To(HaveSuffix(" statement_timeout=30000"))
+	})
+
+	It("Applies the pool settings", func() {
+		cfg.DatabaseConfig.DBMaxOpenConns = 7
+		cfg.DatabaseConfig.DBMaxIdleConns = 0
+		cfg.DatabaseConfig.DBConnMaxLifetime = 1
+		cfg.DatabaseConfig.DBConnMaxIdleTime = 60
+
+		db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(configurePool(&cfg, db)).To(Succeed())
+
+		sqlDB, err := db.DB()
+		Expect(err).ToNot(HaveOccurred())
+		defer sqlDB.Close()
+		Expect(sqlDB.Stats().MaxOpenConnections).To(Equal(7))
+
+		// without idle connections every released connection is closed
+		Expect(sqlDB.Ping()).To(Succeed())
+		Expect(sqlDB.Stats().Idle).To(Equal(0))
+		Expect(sqlDB.Stats().MaxIdleClosed).To(BeNumerically(">", 0))
+
+		cfg.DatabaseConfig.DBMaxIdleConns = 2
+		Expect(configurePool(&cfg, db)).To(Succeed())
+		Expect(sqlDB.Ping()).To(Succeed())
+		time.Sleep(1100 * time.Millisecond)
+		Expect(sqlDB.Ping()).To(Succeed())
+		Expect(sqlDB.Stats().MaxLifetimeClosed).To(BeNumerically(">", 0))
+	})
+})
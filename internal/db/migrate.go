@@ -0,0 +1,76 @@
+package db
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	l "github.com/redhatinsights/payload-tracker-go/internal/logging"
+	models "github.com/redhatinsights/payload-tracker-go/internal/models/db"
+	"github.com/redhatinsights/payload-tracker-go/internal/queries"
+)
+
+// sqlite only auto increments a single INTEGER PRIMARY KEY column, so payload_statuses
+// can't keep the (id, date) primary key it has in postgres. AutoMigrate adds the rest;
+// its DDL parser expects the quoting and layout gorm itself generates.
+const sqlitePayloadStatusesTable = "CREATE TABLE IF NOT EXISTS `payload_statuses` (" +
+	"`id` integer PRIMARY KEY AUTOINCREMENT,`payload_id` integer NOT NULL,`service_id` integer NOT NULL,`source_id` integer," +
+	"`status_id` integer NOT NULL,`status_msg` varchar,`date` datetime NOT NULL,`created_at` datetime NOT NULL)"
+
+// Migrate creates or updates the tables and backfills the data derived from the statuses.
+// Failing to update the schema is returned, the backfills only log their errors as they are
+// re-run by the next migration.
+func Migrate(db *gorm.DB) error {
+	if db.Dialector.Name() == "sqlite" {
+		if err := db.Exec(sqlitePayloadStatusesTable).Error; err != nil {
+			return fmt.Errorf("unable to create the payload_statuses table: %w", err)
+		}
+	}
+
+	if err := db.AutoMigrate(
+		&models.Services{},
+		&models.Sources{},
+		&models.Statuses{},
+		&models.PayloadStatuses{},
+		&models.Payloads{},
+		&models.PayloadLatestStatus{},
+		&models.PayloadStatusRollup{},
+		&models.ArchiveLinkAudit{},
+		&models.WebhookSubscription{},
+		&models.WebhookDelivery{},
+		&models.SLOEvaluation{},
+		&models.Anomaly{},
+		&models.ServiceErrorMessage{},
+	); err != nil {
+		return err
+	}
+
+	if updated, err := queries.BackfillCatalog(db); err != nil {
+		l.Log.Error("Backfilling the catalog first and last seen times failed: ", err)
+	} else {
+		l.Log.Infof("Backfilled the first and last seen times of %d catalog entries", updated)
+	}
+
+	if db.Dialector.Name() == "postgres" {
+		if err := db.Exec("ALTER SEQUENCE payloads_id_seq AS bigint").Error; err != nil {
+			return fmt.Errorf("unable to widen payloads_id_seq: %w", err)
+		}
+
+		if filled, err := queries.BackfillStatusMsgSearch(db, 10000); err != nil {
+			l.Log.Error("Backfilling the status message search vectors failed: ", err)
+		} else {
+			l.Log.Infof("Backfilled the search vectors of %d statuses", filled)
+		}
+		if err := queries.CreateStatusMsgSearchIndex(db); err != nil {
+			l.Log.Error("Creating the status message search index failed: ", err)
+		}
+
+		if result := queries.BackfillLatestStatus(db); result.Error != nil {
+			l.Log.Error("Backfilling the latest statuses failed: ", result.Error)
+		} else {
+			l.Log.Infof("Backfilled the latest status of %d payloads", result.RowsAffected)
+		}
+	}
+
+	return nil
+}
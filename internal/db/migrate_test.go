@@ -0,0 +1,58 @@
+package db
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gorm.io/gorm"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/config"
+	models "github.com/redhatinsights/payload-tracker-go/internal/models/db"
+)
+
+var _ = Describe("Migrate", func() {
+	var (
+		dir string
+		db  *gorm.DB
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "migrate")
+		Expect(err).ToNot(HaveOccurred())
+
+		cfg := *config.Get()
+		cfg.DatabaseConfig.DBSqlitePath = filepath.Join(dir, "tracker.db")
+
+		db, err = gorm.Open(sqliteDialector(&cfg), &gorm.Config{})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+		os.RemoveAll(dir)
+	})
+
+	It("Migrates a sqlite database again without errors", func() {
+		Expect(Migrate(db)).To(Succeed())
+		// the second run parses the hand-written payload_statuses table
+		Expect(Migrate(db)).To(Succeed())
+
+		for i := 0; i < 2; i++ {
+			status := models.PayloadStatuses{PayloadId: 1, ServiceId: 1, StatusId: 1, Date: time.Now()}
+			Expect(db.Omit("source_id").Create(&status).Error).ToNot(HaveOccurred())
+			Expect(status.ID).To(BeNumerically("==", i+1))
+		}
+
+		var columns []string
+		Expect(db.Raw("SELECT name FROM pragma_table_info('payload_statuses') WHERE pk > 0").Scan(&columns).Error).ToNot(HaveOccurred())
+		Expect(columns).To(Equal([]string{"id"}))
+		Expect(db.Migrator().HasColumn(&models.PayloadStatuses{}, "status_msg_search")).To(BeTrue())
+	})
+})
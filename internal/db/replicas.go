@@ -0,0 +1,179 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/config"
+	l "github.com/redhatinsights/payload-tracker-go/internal/logging"
+	"github.com/redhatinsights/payload-tracker-go/internal/tracing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+)
+
+// replicaLagQuery returns the replay lag of a postgres standby in seconds. A standby that
+// has replayed everything it received reports 0 even when the primary is idle.
+const replicaLagQuery = `SELECT CASE
+	WHEN NOT pg_is_in_recovery() THEN 0
+	WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+	ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+END`
+
+type replica struct {
+	host    string
+	db      *gorm.DB
+	healthy bool
+}
+
+var (
+	replicas     []*replica
+	replicasLock sync.RWMutex
+	replicaNext  uint32
+)
+
+// DbConnectReplicas opens a connection to each configured read replica. Replicas start out
+// unhealthy until MonitorReplicas has checked them, so reads go to the primary until then.
+func DbConnectReplicas(cfg *config.TrackerConfig) {
+	if cfg.DatabaseConfig.DBDriver == "sqlite" && len(cfg.DatabaseConfig.DBReplicaHosts) > 0 {
+		l.Log.Warn("DB replicas are not supported with sqlite, reading from the primary")
+		return
+	}
+
+	for _, hostport := range cfg.DatabaseConfig.DBReplicaHosts {
+		host, port, err := net.SplitHostPort(hostport)
+		if err != nil {
+			host, port = hostport, cfg.DatabaseConfig.DBPort
+		}
+
+		db, err := gorm.Open(postgresDialector(cfg, host, port), &gorm.Config{})
+		if err == nil {
+			err = configurePool(cfg, db)
+		}
+		if err == nil {
+			err = tracing.RegisterCallbacks(db)
+		}
+		r := &replica{host: hostport, db: db}
+		if err == nil {
+			err = registerFailover(r)
+		}
+		if err != nil {
+			l.Log.Errorf("Unable to connect to DB replica %s: %v", hostport, err)
+			continue
+		}
+
+		replicas = append(replicas, r)
+	}
+
+	if len(replicas) > 0 {
+		l.Log.Infof("DB replica initialization complete, %d replica(s) configured", len(replicas))
+	}
+}
+
+// ReadDB returns a healthy read replica, round-robin, or the primary when no replica is usable.
+// Only the API should read through it; the consumer and migrations always use DB.
+func ReadDB() *gorm.DB {
+	replicasLock.RLock()
+	defer replicasLock.RUnlock()
+
+	count := len(replicas)
+	if count == 0 {
+		return DB
+	}
+
+	start := int(atomic.AddUint32(&replicaNext, 1))
+	for i := 0; i < count; i++ {
+		r := replicas[(start+i)%count]
+		if r.healthy {
+			return r.db
+		}
+	}
+
+	return DB
+}
+
+// MonitorReplicas periodically pings every replica and takes it out of rotation when it
+// is unreachable or lagging more than the configured maximum behind the primary
+func MonitorReplicas(ctx context.Context, cfg *config.TrackerConfig) {
+	if len(replicas) == 0 {
+		return
+	}
+
+	maxLag := float64(cfg.DatabaseConfig.DBReplicaMaxLag)
+	ticker := time.NewTicker(time.Duration(cfg.DatabaseConfig.DBReplicaCheckInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		for _, r := range replicas {
+			healthy := checkReplica(ctx, r, maxLag)
+
+			replicasLock.Lock()
+			if healthy != r.healthy {
+				if healthy {
+					l.Log.Infof("DB replica %s is back in rotation", r.host)
+				} else {
+					l.Log.Warnf("DB replica %s removed from rotation", r.host)
+				}
+			}
+			r.healthy = healthy
+			replicasLock.Unlock()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func checkReplica(ctx context.Context, r *replica, maxLag float64) bool {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var lag float64
+	if err := r.db.WithContext(ctx).Raw(replicaLagQuery).Scan(&lag).Error; err != nil {
+		l.Log.Errorf("DB replica %s health check failed: %v", r.host, err)
+		return false
+	}
+
+	if lag > maxLag {
+		l.Log.Warnf("DB replica %s is lagging %.1fs behind the primary", r.host, lag)
+		return false
+	}
+
+	return true
+}
+
+// registerFailover retries the reads that fail on a replica on the primary. Reads canceled
+// by the caller aren't retried, MonitorReplicas takes a failing replica out of rotation.
+func registerFailover(r *replica) error {
+	failover := func(query func(*gorm.DB), rows bool) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			err := tx.Error
+			if err == nil || DB == nil || errors.Is(err, gorm.ErrRecordNotFound) ||
+				errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+				return
+			}
+
+			l.Log.Warnf("Read from DB replica %s failed, retrying on the primary: %v", r.host, err)
+			tx.Error = nil
+			tx.Statement.ConnPool = DB.ConnPool
+			if rows {
+				// only queries for rows report their error here, the first run cleared the flag
+				tx.Statement.Settings.Store("rows", true)
+			}
+			query(tx)
+		}
+	}
+
+	err := r.db.Callback().Query().After("gorm:query").Register("replicas:failover", failover(callbacks.Query, false))
+	if err != nil {
+		return err
+	}
+	return r.db.Callback().Row().After("gorm:row").Register("replicas:failover", failover(callbacks.RowQuery, true))
+}
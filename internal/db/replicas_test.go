@@ -0,0 +1,106 @@
+package db
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+var _ = Describe("Replica failover", func() {
+	var (
+		dir     string
+		primary *gorm.DB
+		r       *replica
+	)
+
+	type item struct {
+		Name string
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "replicas")
+		Expect(err).ToNot(HaveOccurred())
+
+		primary, err = gorm.Open(sqlite.Open(filepath.Join(dir, "primary.db")), &gorm.Config{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(primary.Exec("CREATE TABLE items (name text)").Error).ToNot(HaveOccurred())
+		Expect(primary.Exec("INSERT INTO items (name) VALUES ('from primary')").Error).ToNot(HaveOccurred())
+
+		// the replica misses the table, so every read of it fails
+		replicaDb, err := gorm.Open(sqlite.Open(filepath.Join(dir, "replica.db")), &gorm.Config{})
+		Expect(err).ToNot(HaveOccurred())
+		r = &replica{host: "replica", db: replicaDb}
+		Expect(registerFailover(r)).To(Succeed())
+
+		DB = primary
+	})
+
+	AfterEach(func() {
+		DB = nil
+		os.RemoveAll(dir)
+	})
+
+	It("Retries failed queries on the primary", func() {
+		var items []item
+		Expect(r.db.Table("items").Find(&items).Error).ToNot(HaveOccurred())
+		Expect(items).To(Equal([]item{{Name: "from primary"}}))
+	})
+
+	It("Retries failed raw queries on the primary", func() {
+		var names []string
+		Expect(r.db.Raw("SELECT name FROM items").Scan(&names).Error).ToNot(HaveOccurred())
+		Expect(names).To(Equal([]string{"from primary"}))
+	})
+
+	It("Retries failed counts on the primary", func() {
+		var count int64
+		Expect(r.db.Table("items").Count(&count).Error).ToNot(HaveOccurred())
+		Expect(count).To(Equal(int64(1)))
+	})
+
+	It("Retries failed single row queries on the primary", func() {
+		var found item
+		Expect(r.db.Table("items").Where("name = ?", "from primary").First(&found).Error).ToNot(HaveOccurred())
+		Expect(found.Name).To(Equal("from primary"))
+
+		Expect(r.db.Table("items").Where("name = ?", "missing").First(&found).Error).To(MatchError(gorm.ErrRecordNotFound))
+	})
+
+	It("Retries failed row scans on the primary", func() {
+		rows, err := r.db.Table("items").Select("name").Rows()
+		Expect(err).ToNot(HaveOccurred())
+		defer rows.Close()
+
+		var names []string
+		for rows.Next() {
+			var name string
+			Expect(rows.Scan(&name)).To(Succeed())
+			names = append(names, name)
+		}
+		Expect(rows.Err()).ToNot(HaveOccurred())
+		Expect(names).To(Equal([]string{"from primary"}))
+	})
+
+	It("Retries reads of a healthy replica returned by ReadDB on the primary", func() {
+		replicas = []*replica{r}
+		defer func() { replicas = nil }()
+		r.healthy = true
+
+		Expect(ReadDB()).To(BeIdenticalTo(r.db))
+
+		var items []item
+		Expect(ReadDB().Table("items").Find(&items).Error).ToNot(HaveOccurred())
+		Expect(items).To(Equal([]item{{Name: "from primary"}}))
+	})
+
+	It("Keeps the error when the primary fails too", func() {
+		var items []item
+		Expect(r.db.Table("missing").Find(&items).Error).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,66 @@
+package endpoints
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/queries"
+	"github.com/redhatinsights/payload-tracker-go/internal/structs"
+)
+
+var (
+	RetrieveAnomalies = queries.RetrieveAnomalies
+
+	validAnomalyKinds = []string{queries.AnomalyErrorRateSpike, queries.AnomalyNewErrorMessage}
+)
+
+// Anomalies returns the error rate spikes and new error messages detected by the consumer
+func Anomalies(w http.ResponseWriter, r *http.Request) {
+	q := structs.AnomalyQuery{
+		Kind:     r.URL.Query().Get("kind"),
+		Service:  strings.ToLower(r.URL.Query().Get("service")),
+		PageSize: 10,
+	}
+
+	if q.Kind != "" && !stringInSlice(q.Kind, validAnomalyKinds) {
+		message := "kind must be one of " + strings.Join(validAnomalyKinds, ", ")
+		writeResponse(w, http.StatusBadRequest, getErrorBody(message, http.StatusBadRequest))
+		return
+	}
+
+	if active := r.URL.Query().Get("active"); active != "" {
+		value, err := strconv.ParseBool(active)
+		if err != nil {
+			writeResponse(w, http.StatusBadRequest, getErrorBody("active must be true or false", http.StatusBadRequest))
+			return
+		}
+		q.Active = &value
+	}
+
+	now := time.Now()
+	for param, value := range map[string]*time.Time{"date_gte": &q.Start, "date_lt": &q.End} {
+		var err error
+		if *value, err = timeParam(r, param, now, time.Time{}); err != nil {
+			writeResponse(w, http.StatusBadRequest, getErrorBody(err.Error(), http.StatusBadRequest))
+			return
+		}
+	}
+
+	for param, value := range map[string]*int{"page": &q.Page, "page_size": &q.PageSize} {
+		if r.URL.Query().Get(param) == "" {
+			continue
+		}
+		var err error
+		*value, err = strconv.Atoi(r.URL.Query().Get(param))
+		if err != nil || *value < 0 {
+			message := param + " must be a non-negative integer"
+			writeResponse(w, http.StatusBadRequest, getErrorBody(message, http.StatusBadRequest))
+			return
+		}
+	}
+
+	count, anomalies := RetrieveAnomalies(requestDb(r), q)
+	writeJSON(w, http.StatusOK, structs.AnomaliesData{Count: count, Data: anomalies})
+}
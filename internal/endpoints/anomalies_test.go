@@ -0,0 +1,79 @@
+package endpoints_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gorm.io/gorm"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/endpoints"
+	models "github.com/redhatinsights/payload-tracker-go/internal/models/db"
+	"github.com/redhatinsights/payload-tracker-go/internal/structs"
+	"github.com/redhatinsights/payload-tracker-go/internal/utils/test"
+)
+
+var _ = Describe("Anomalies", func() {
+	var (
+		handler      http.Handler
+		rr           *httptest.ResponseRecorder
+		query        map[string]interface{}
+		anomalyQuery structs.AnomalyQuery
+	)
+
+	BeforeEach(func() {
+		rr = httptest.NewRecorder()
+		handler = http.HandlerFunc(endpoints.Anomalies)
+		query = make(map[string]interface{})
+
+		endpoints.Db = func() *gorm.DB { return nil }
+		endpoints.RetrieveAnomalies = func(_ *gorm.DB, q structs.AnomalyQuery) (int64, []models.Anomaly) {
+			anomalyQuery = q
+			return 1, []models.Anomaly{{Id: 1, Kind: "error_rate_spike", Service: "puptoo", Errors: 10, Total: 20, ErrorRate: 0.5}}
+		}
+	})
+
+	It("Should pass the filters to the query", func() {
+		query["kind"] = "new_error_message"
+		query["service"] = "Puptoo"
+		query["active"] = "true"
+		query["date_gte"] = "2022-06-07T12:00:00Z"
+		query["page_size"] = 5
+
+		req, err := test.MakeTestRequest("/api/v1/anomalies", query)
+		Expect(err).To(BeNil())
+		handler.ServeHTTP(rr, req)
+		Expect(rr.Code).To(Equal(http.StatusOK))
+
+		start, _ := time.Parse(time.RFC3339, "2022-06-07T12:00:00Z")
+		Expect(anomalyQuery.Kind).To(Equal("new_error_message"))
+		Expect(anomalyQuery.Service).To(Equal("puptoo"))
+		Expect(*anomalyQuery.Active).To(BeTrue())
+		Expect(anomalyQuery.Start).To(Equal(start))
+		Expect(anomalyQuery.End.IsZero()).To(BeTrue())
+		Expect(anomalyQuery.PageSize).To(Equal(5))
+
+		var respData structs.AnomaliesData
+		Expect(json.Unmarshal(rr.Body.Bytes(), &respData)).To(Succeed())
+		Expect(respData.Count).To(Equal(int64(1)))
+		Expect(respData.Data[0].Service).To(Equal("puptoo"))
+	})
+
+	It("Should reject invalid params", func() {
+		for param, value := range map[string]interface{}{
+			"kind":      "spike",
+			"active":    "maybe",
+			"date_lt":   "yesterday",
+			"page_size": -1,
+		} {
+			rr = httptest.NewRecorder()
+			req, err := test.MakeTestRequest("/api/v1/anomalies", map[string]interface{}{param: value})
+			Expect(err).To(BeNil())
+			handler.ServeHTTP(rr, req)
+			Expect(rr.Code).To(Equal(http.StatusBadRequest), param)
+		}
+	})
+})
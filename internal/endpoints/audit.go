@@ -0,0 +1,116 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/config"
+	"github.com/redhatinsights/payload-tracker-go/internal/db"
+	l "github.com/redhatinsights/payload-tracker-go/internal/logging"
+	models "github.com/redhatinsights/payload-tracker-go/internal/models/db"
+	"github.com/redhatinsights/payload-tracker-go/internal/queries"
+	"github.com/redhatinsights/payload-tracker-go/internal/structs"
+)
+
+var (
+	InsertArchiveLinkAudit    = queries.InsertArchiveLinkAudit
+	RetrieveArchiveLinkAudits = queries.RetrieveArchiveLinkAudits
+	PrimaryDb                 = getPrimaryDb
+)
+
+// getPrimaryDb returns the database writes go to, audit records can't go to a replica
+func getPrimaryDb() *gorm.DB {
+	return db.DB
+}
+
+// primaryRequestDb binds the request context to the primary database session
+func primaryRequestDb(r *http.Request) *gorm.DB {
+	db := PrimaryDb()
+	if db == nil {
+		return nil
+	}
+	return db.WithContext(r.Context())
+}
+
+func outcomeForStatus(status int) string {
+	if status == http.StatusForbidden {
+		return "forbidden"
+	}
+	return "unauthorized"
+}
+
+// auditArchiveLink records who asked for the archive link of a payload and the outcome.
+// Failures are logged and returned, only handing out a link depends on them.
+func auditArchiveLink(r *http.Request, reqID string, outcome string, status int, cause error) error {
+	audit := models.ArchiveLinkAudit{
+		RequestId:    reqID,
+		Outcome:      outcome,
+		StatusCode:   status,
+		ApiRequestId: GetRequestID(r.Context()),
+	}
+
+	if ident, err := getIdentity(r); err == nil {
+		audit.OrgId = ident.OrgID
+		audit.Username = ident.User
+		audit.IdentityType = ident.Type
+	}
+	if cause != nil {
+		audit.Error = cause.Error()
+	}
+
+	if err := InsertArchiveLinkAudit(primaryRequestDb(r), &audit).Error; err != nil {
+		l.Log.WithFields(identityFields(r)).Errorf("Unable to audit archive link request for %s: %v", reqID, err)
+		return err
+	}
+	return nil
+}
+
+// ArchiveLinkAudit returns a response for /admin/archiveLinkAudit
+func ArchiveLinkAudit(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	statusCode, err := checkForRole(r, config.Get().AuditRole)
+	if err != nil {
+		writeResponse(w, statusCode, getErrorBody(fmt.Sprintf("%v", err), statusCode))
+		return
+	}
+
+	q := structs.ArchiveLinkAuditQuery{
+		User:      r.URL.Query().Get("user"),
+		OrgID:     r.URL.Query().Get("org_id"),
+		RequestID: r.URL.Query().Get("request_id"),
+		Page:      0,
+		PageSize:  10,
+	}
+
+	for param, value := range map[string]*int{"page": &q.Page, "page_size": &q.PageSize} {
+		if r.URL.Query().Get(param) == "" {
+			continue
+		}
+		*value, err = strconv.Atoi(r.URL.Query().Get(param))
+		if err != nil || *value < 0 {
+			message := param + " must be a non-negative integer"
+			writeResponse(w, http.StatusBadRequest, getErrorBody(message, http.StatusBadRequest))
+			return
+		}
+	}
+
+	count, audits := RetrieveArchiveLinkAudits(primaryRequestDb(r), q)
+	observeDBTime(time.Since(start))
+
+	auditData := structs.ArchiveLinkAuditData{Count: count, Elapsed: time.Since(start).Seconds(), Data: audits}
+
+	dataJson, err := json.Marshal(auditData)
+	if err != nil {
+		l.Log.Error(err)
+		writeResponse(w, http.StatusInternalServerError, getErrorBody("Internal Server Issue", http.StatusInternalServerError))
+		return
+	}
+
+	writeResponse(w, http.StatusOK, string(dataJson))
+}
@@ -0,0 +1,76 @@
+package endpoints_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gorm.io/gorm"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/endpoints"
+	models "github.com/redhatinsights/payload-tracker-go/internal/models/db"
+	"github.com/redhatinsights/payload-tracker-go/internal/structs"
+	"github.com/redhatinsights/payload-tracker-go/internal/utils/test"
+)
+
+// an associate with the platform-archive-audit role
+const auditIdentityHeader = "eyJpZGVudGl0eSI6IHsiYXNzb2NpYXRlIjogeyJSb2xlIjogWyJwbGF0Zm9ybS1hcmNoaXZlLWF1ZGl0Il0sICJyaGF0VVVJRCI6ICJhMWIyIn0sICJ0eXBlIjogIkFzc29jaWF0ZSIsICJvcmdfaWQiOiAiMDAwMDAxIiwgImludGVybmFsIjogeyJvcmdfaWQiOiAiMDAwMDAxIn19fQ=="
+
+var _ = Describe("ArchiveLinkAudit", func() {
+	var (
+		handler http.Handler
+		rr      *httptest.ResponseRecorder
+		query   map[string]interface{}
+		q       structs.ArchiveLinkAuditQuery
+	)
+
+	BeforeEach(func() {
+		rr = httptest.NewRecorder()
+		handler = http.HandlerFunc(endpoints.ArchiveLinkAudit)
+		query = make(map[string]interface{})
+
+		endpoints.PrimaryDb = func() *gorm.DB { return nil }
+		endpoints.RetrieveArchiveLinkAudits = func(_ *gorm.DB, query structs.ArchiveLinkAuditQuery) (int64, []models.ArchiveLinkAudit) {
+			q = query
+			return 1, []models.ArchiveLinkAudit{{RequestId: getUUID(), Username: "a1b2", Outcome: "success", StatusCode: 200}}
+		}
+	})
+
+	It("Should return 403 without the audit role", func() {
+		req, err := test.MakeTestRequest("/api/v1/admin/archiveLinkAudit", query)
+		Expect(err).To(BeNil())
+		req.Header.Set("x-rh-identity", validIdentityHeader)
+		handler.ServeHTTP(rr, req)
+		Expect(rr.Code).To(Equal(http.StatusForbidden))
+	})
+
+	It("Should return 400 for an invalid page", func() {
+		query["page"] = "first"
+		req, err := test.MakeTestRequest("/api/v1/admin/archiveLinkAudit", query)
+		Expect(err).To(BeNil())
+		req.Header.Set("x-rh-identity", auditIdentityHeader)
+		handler.ServeHTTP(rr, req)
+		Expect(rr.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("Should pass the filters on and return the audit records", func() {
+		query["user"] = "a1b2"
+		query["org_id"] = "000001"
+		query["page"] = 2
+		query["page_size"] = 5
+		req, err := test.MakeTestRequest("/api/v1/admin/archiveLinkAudit", query)
+		Expect(err).To(BeNil())
+		req.Header.Set("x-rh-identity", auditIdentityHeader)
+		handler.ServeHTTP(rr, req)
+		Expect(rr.Code).To(Equal(http.StatusOK))
+
+		Expect(q).To(Equal(structs.ArchiveLinkAuditQuery{User: "a1b2", OrgID: "000001", Page: 2, PageSize: 5}))
+
+		var respData structs.ArchiveLinkAuditData
+		Expect(json.Unmarshal(rr.Body.Bytes(), &respData)).To(Succeed())
+		Expect(respData.Count).To(Equal(int64(1)))
+		Expect(respData.Data[0].Outcome).To(Equal("success"))
+	})
+})
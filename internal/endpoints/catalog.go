@@ -0,0 +1,64 @@
+package endpoints
+
+import (
+	"net/http"
+	"time"
+
+	l "github.com/redhatinsights/payload-tracker-go/internal/logging"
+	"github.com/redhatinsights/payload-tracker-go/internal/queries"
+	"github.com/redhatinsights/payload-tracker-go/internal/structs"
+)
+
+var (
+	RetrieveCatalog = queries.RetrieveCatalog
+
+	defaultCatalogWindow = 24 * time.Hour
+	maxCatalogWindow     = 31 * 24 * time.Hour
+)
+
+// Services returns the services that reported statuses for /services
+func Services(w http.ResponseWriter, r *http.Request) {
+	catalog(w, r, queries.CatalogServices)
+}
+
+// Sources returns the sources of the reported statuses for /sources
+func Sources(w http.ResponseWriter, r *http.Request) {
+	catalog(w, r, queries.CatalogSources)
+}
+
+// StatusesCatalog returns the reported statuses for /statuses/catalog
+func StatusesCatalog(w http.ResponseWriter, r *http.Request) {
+	catalog(w, r, queries.CatalogStatuses)
+}
+
+func catalog(w http.ResponseWriter, r *http.Request, table string) {
+	start := time.Now()
+
+	window := defaultCatalogWindow
+	if value := r.URL.Query().Get("window"); value != "" {
+		var err error
+		if window, err = parseDuration(value); err != nil || window > maxCatalogWindow {
+			message := "window must be a duration such as 30m, 2h or 7d, at most 31d"
+			writeResponse(w, http.StatusBadRequest, getErrorBody(message, http.StatusBadRequest))
+			return
+		}
+	}
+
+	q := structs.CatalogQuery{RecentSince: start.Add(-window)}
+
+	var err error
+	if q.SeenSince, err = timeParam(r, "seen_since", start, time.Time{}); err != nil {
+		writeResponse(w, http.StatusBadRequest, getErrorBody(err.Error(), http.StatusBadRequest))
+		return
+	}
+
+	entries, err := RetrieveCatalog(requestDb(r), table, q)
+	if err != nil {
+		l.Log.Errorf("Unable to retrieve the %s catalog: %v", table, err)
+		writeResponse(w, http.StatusInternalServerError, getErrorBody("Internal Server Issue", http.StatusInternalServerError))
+		return
+	}
+	observeDBTime(time.Since(start))
+
+	writeJSON(w, http.StatusOK, structs.CatalogData{Count: int64(len(entries)), Elapsed: time.Since(start).Seconds(), Data: entries})
+}
@@ -0,0 +1,76 @@
+package endpoints_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gorm.io/gorm"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/endpoints"
+	"github.com/redhatinsights/payload-tracker-go/internal/structs"
+	"github.com/redhatinsights/payload-tracker-go/internal/utils/test"
+)
+
+var _ = Describe("Catalog", func() {
+	var (
+		rr           *httptest.ResponseRecorder
+		catalogTable string
+		catalogQuery structs.CatalogQuery
+	)
+
+	BeforeEach(func() {
+		rr = httptest.NewRecorder()
+
+		endpoints.Db = func() *gorm.DB { return nil }
+		endpoints.RetrieveCatalog = func(_ *gorm.DB, table string, q structs.CatalogQuery) ([]structs.CatalogEntry, error) {
+			catalogTable = table
+			catalogQuery = q
+			return []structs.CatalogEntry{}, nil
+		}
+	})
+
+	It("Should list each catalog with the volume of the last day", func() {
+		for table, handler := range map[string]http.HandlerFunc{
+			"services": endpoints.Services,
+			"sources":  endpoints.Sources,
+			"statuses": endpoints.StatusesCatalog,
+		} {
+			rr = httptest.NewRecorder()
+			req, err := test.MakeTestRequest("/api/v1/catalog", map[string]interface{}{})
+			Expect(err).To(BeNil())
+			handler.ServeHTTP(rr, req)
+			Expect(rr.Code).To(Equal(http.StatusOK))
+
+			Expect(catalogTable).To(Equal(table))
+			Expect(catalogQuery.RecentSince).To(BeTemporally("~", time.Now().Add(-24*time.Hour), time.Second))
+			Expect(catalogQuery.SeenSince.IsZero()).To(BeTrue())
+		}
+	})
+
+	It("Should pass the window and seen_since params", func() {
+		req, err := test.MakeTestRequest("/api/v1/services", map[string]interface{}{"window": "1h", "seen_since": "now-7d"})
+		Expect(err).To(BeNil())
+		http.HandlerFunc(endpoints.Services).ServeHTTP(rr, req)
+		Expect(rr.Code).To(Equal(http.StatusOK))
+
+		Expect(catalogQuery.RecentSince).To(BeTemporally("~", time.Now().Add(-time.Hour), time.Second))
+		Expect(catalogQuery.SeenSince).To(BeTemporally("~", time.Now().AddDate(0, 0, -7), time.Second))
+	})
+
+	It("Should reject invalid params", func() {
+		for _, query := range []map[string]interface{}{
+			{"window": "1 day"},
+			{"window": "60d"},
+			{"seen_since": "last week"},
+		} {
+			rr = httptest.NewRecorder()
+			req, err := test.MakeTestRequest("/api/v1/services", query)
+			Expect(err).To(BeNil())
+			http.HandlerFunc(endpoints.Services).ServeHTTP(rr, req)
+			Expect(rr.Code).To(Equal(http.StatusBadRequest), "%v", query)
+		}
+	})
+})
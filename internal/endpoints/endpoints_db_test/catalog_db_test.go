@@ -0,0 +1,85 @@
+package endpoints_db_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/endpoints"
+	dbmodels "github.com/redhatinsights/payload-tracker-go/internal/models/db"
+	"github.com/redhatinsights/payload-tracker-go/internal/structs"
+	"github.com/redhatinsights/payload-tracker-go/internal/utils/test"
+)
+
+var _ = Describe("Catalog with DB", func() {
+	var (
+		service string
+		stale   string
+		now     time.Time
+	)
+
+	db := test.WithDatabase()
+
+	entries := func(query map[string]interface{}) map[string]structs.CatalogEntry {
+		rr := httptest.NewRecorder()
+		req, err := test.MakeTestRequest("/api/v1/services", query)
+		Expect(err).To(BeNil())
+		http.HandlerFunc(endpoints.Services).ServeHTTP(rr, req)
+		Expect(rr.Code).To(Equal(http.StatusOK))
+
+		var respData structs.CatalogData
+		Expect(json.Unmarshal(rr.Body.Bytes(), &respData)).To(Succeed())
+		Expect(respData.Count).To(Equal(int64(len(respData.Data))))
+
+		byName := make(map[string]structs.CatalogEntry)
+		for _, entry := range respData.Data {
+			byName[entry.Name] = entry
+		}
+		return byName
+	}
+
+	BeforeEach(func() {
+		endpoints.Db = db
+		service = "catalog-" + uuid.New().String()[:8]
+		stale = "catalog-" + uuid.New().String()[:8]
+		now = time.Now().UTC().Truncate(time.Second)
+
+		firstSeen, lastSeen := now.AddDate(0, 0, -30), now.Add(-time.Minute)
+		staleSeen := now.AddDate(0, 0, -10)
+		// concurrent consumers can create a name twice
+		Expect(db().Create(&[]dbmodels.Services{
+			{Name: service, FirstSeen: &firstSeen, LastSeen: &staleSeen},
+			{Name: service, FirstSeen: &staleSeen, LastSeen: &lastSeen},
+			{Name: stale, FirstSeen: &staleSeen, LastSeen: &staleSeen},
+		}).Error).ToNot(HaveOccurred())
+
+		hour := now.Truncate(time.Hour)
+		Expect(db().Create(&[]dbmodels.PayloadStatusRollup{
+			{Hour: hour, Service: service, Source: "", Status: "success", OrgId: "1", Count: 3},
+			{Hour: hour, Service: service, Source: "", Status: "error", OrgId: "2", Count: 2},
+			{Hour: hour.AddDate(0, 0, -2), Service: service, Source: "", Status: "success", OrgId: "1", Count: 7},
+		}).Error).ToNot(HaveOccurred())
+	})
+
+	It("Merges the names with their first and last seen times and recent volume", func() {
+		catalog := entries(map[string]interface{}{})
+		Expect(catalog).To(HaveKey(service))
+		Expect(catalog[service].FirstSeen.Equal(now.AddDate(0, 0, -30))).To(BeTrue())
+		Expect(catalog[service].LastSeen.Equal(now.Add(-time.Minute))).To(BeTrue())
+		Expect(catalog[service].RecentCount).To(Equal(int64(5)))
+
+		Expect(entries(map[string]interface{}{"window": "7d"})[service].RecentCount).To(Equal(int64(12)))
+		Expect(catalog[stale].RecentCount).To(BeZero())
+	})
+
+	It("Leaves out the names not seen since seen_since", func() {
+		catalog := entries(map[string]interface{}{"seen_since": "now-1d"})
+		Expect(catalog).To(HaveKey(service))
+		Expect(catalog).ToNot(HaveKey(stale))
+	})
+})
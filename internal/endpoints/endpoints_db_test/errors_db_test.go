@@ -0,0 +1,78 @@
+package endpoints_db_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/endpoints"
+	"github.com/redhatinsights/payload-tracker-go/internal/fingerprint"
+	dbmodels "github.com/redhatinsights/payload-tracker-go/internal/models/db"
+	"github.com/redhatinsights/payload-tracker-go/internal/structs"
+	"github.com/redhatinsights/payload-tracker-go/internal/utils/test"
+)
+
+var _ = Describe("Errors with DB", func() {
+	var (
+		service string
+		date    time.Time
+	)
+
+	db := test.WithDatabase()
+	fixtures := test.WithPayloads(db)
+
+	report := func(status string, msg string) string {
+		date = date.Add(time.Minute)
+		payload := fixtures.Create(dbmodels.Payloads{}, dbmodels.PayloadStatuses{
+			ServiceId:   fixtures.ServiceId(service),
+			StatusId:    fixtures.StatusId(status),
+			StatusMsg:   msg,
+			Fingerprint: fingerprint.Fingerprint(msg),
+			Date:        date,
+		})
+
+		return payload.RequestId
+	}
+
+	BeforeEach(func() {
+		endpoints.Db = db
+		service = "errors-" + uuid.New().String()[:8]
+		date, _ = time.Parse(time.RFC3339, "2022-06-03T14:00:00Z")
+	})
+
+	It("Counts the error statuses of each fingerprint", func() {
+		first := report("error", "timeout after 30s")
+		second := report("error", "timeout after 31s")
+		report("error", "disk full")
+		report("success", "done in 3s")
+
+		rr := httptest.NewRecorder()
+		req, err := test.MakeTestRequest("/api/v1/errors", map[string]interface{}{
+			"service":  service,
+			"date_gte": "2022-06-03T14:00:00Z",
+			"date_lt":  "2022-06-03T15:00:00Z",
+		})
+		Expect(err).To(BeNil())
+		http.HandlerFunc(endpoints.Errors).ServeHTTP(rr, req)
+		Expect(rr.Code).To(Equal(http.StatusOK))
+
+		var respData structs.ErrorsData
+		Expect(json.Unmarshal(rr.Body.Bytes(), &respData)).To(Succeed())
+		Expect(respData.Count).To(Equal(int64(2)))
+		Expect(respData.Data).To(HaveLen(2))
+
+		Expect(respData.Data[0].Service).To(Equal(service))
+		Expect(respData.Data[0].Fingerprint).To(Equal(fingerprint.Fingerprint("timeout after 30s")))
+		Expect(respData.Data[0].Pattern).To(Equal("timeout after <num>s"))
+		Expect(respData.Data[0].Count).To(Equal(int64(2)))
+		Expect(respData.Data[0].SampleRequestIDs).To(Equal([]string{second, first}))
+
+		Expect(respData.Data[1].Pattern).To(Equal("disk full"))
+		Expect(respData.Data[1].Count).To(Equal(int64(1)))
+	})
+})
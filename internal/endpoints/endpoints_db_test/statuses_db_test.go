@@ -0,0 +1,196 @@
+package endpoints_db_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/endpoints"
+	"github.com/redhatinsights/payload-tracker-go/internal/models"
+	dbmodels "github.com/redhatinsights/payload-tracker-go/internal/models/db"
+	"github.com/redhatinsights/payload-tracker-go/internal/queries"
+	"github.com/redhatinsights/payload-tracker-go/internal/structs"
+	"github.com/redhatinsights/payload-tracker-go/internal/utils/test"
+)
+
+var _ = Describe("Statuses filters with DB", func() {
+	var (
+		source  string
+		account string
+	)
+
+	db := test.WithDatabase()
+
+	report := func(service string, status string, msg string) models.Payloads {
+		s := queries.GetStatusByName(db(), status)
+		if s.Id == 0 {
+			_, s = queries.CreateStatusTableEntry(db(), status)
+		}
+		sv := queries.GetServiceByName(db(), service)
+		if sv.Id == 0 {
+			_, sv = queries.CreateServiceTableEntry(db(), service)
+		}
+		src := queries.GetSourceByName(db(), source)
+		if src.Id == 0 {
+			_, src = queries.CreateSourceTableEntry(db(), source)
+		}
+
+		payload := models.Payloads{RequestId: uuid.New().String(), Account: account}
+		Expect(db().Create(&payload).Error).ToNot(HaveOccurred())
+
+		statusData := models.PayloadStatuses{
+			PayloadId: payload.Id,
+			ServiceId: sv.Id,
+			SourceId:  src.Id,
+			StatusId:  s.Id,
+			StatusMsg: msg,
+			Date:      time.Now(),
+		}
+		Expect(db().Create(&statusData).Error).ToNot(HaveOccurred())
+
+		return payload
+	}
+
+	get := func(handler http.HandlerFunc, path string, params url.Values) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", path+"?"+params.Encode(), nil)
+		Expect(err).To(BeNil())
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	statusMsgs := func(params url.Values) []string {
+		params.Set("source", source)
+
+		rr := get(endpoints.Statuses, "/api/v1/statuses", params)
+		Expect(rr.Code).To(Equal(http.StatusOK))
+
+		var respData structs.StatusesData
+		Expect(json.Unmarshal(rr.Body.Bytes(), &respData)).To(Succeed())
+
+		msgs := []string{}
+		for _, status := range respData.Data {
+			msgs = append(msgs, status.StatusMsg)
+		}
+		return msgs
+	}
+
+	BeforeEach(func() {
+		endpoints.Db = db
+		source = "filters-" + uuid.New().String()[:8]
+		account = source
+
+		report("filters-ingress", "success", "used 50% of quota")
+		report("filters-ingress", "error", "Timeout talking to storage")
+		report("filters-puptoo", "error", "disk full")
+		report("filters-advisor", "success", "done")
+		report("filters-advisor", "error", "!important: disk full")
+	})
+
+	It("Matches any of the values", func() {
+		Expect(statusMsgs(url.Values{"service": {"filters-ingress,filters-puptoo"}})).To(ConsistOf(
+			"Timeout talking to storage", "disk full", "used 50% of quota",
+		))
+		Expect(statusMsgs(url.Values{"service": {"filters-puptoo", "filters-advisor"}})).To(ConsistOf("disk full", "done", "!important: disk full"))
+	})
+
+	It("Matches none of the negated values", func() {
+		Expect(statusMsgs(url.Values{"status": {"!success"}})).To(ConsistOf("Timeout talking to storage", "disk full", "!important: disk full"))
+		Expect(statusMsgs(url.Values{"service": {"!filters-ingress,!filters-puptoo"}})).To(ConsistOf("done", "!important: disk full"))
+	})
+
+	It("Matches status messages starting with ! as they are", func() {
+		Expect(statusMsgs(url.Values{"status_msg": {"!important: disk full"}})).To(ConsistOf("!important: disk full"))
+		Expect(statusMsgs(url.Values{"status_msg_contains": {"!important"}})).To(ConsistOf("!important: disk full"))
+	})
+
+	It("Matches status messages containing the values", func() {
+		Expect(statusMsgs(url.Values{"status_msg_contains": {"TIMEOUT"}})).To(ConsistOf("Timeout talking to storage"))
+		Expect(statusMsgs(url.Values{"status_msg_contains": {"50%"}})).To(ConsistOf("used 50% of quota"))
+		Expect(statusMsgs(url.Values{"status_msg_contains": {"5_"}})).To(BeEmpty())
+		Expect(statusMsgs(url.Values{"status_msg_contains": {"timeout", "FULL"}})).To(ConsistOf("Timeout talking to storage", "disk full", "!important: disk full"))
+	})
+
+	It("Searches the status messages", func() {
+		sv := queries.GetServiceByName(db(), "filters-ingress")
+		st := queries.GetStatusByName(db(), "error")
+		src := queries.GetSourceByName(db(), source)
+		for _, msg := range []string{"TLS certificate expired for host", "certificate renewed", "certificate for host expired after 90 days"} {
+			payload := models.Payloads{RequestId: uuid.New().String()}
+			Expect(db().Create(&payload).Error).ToNot(HaveOccurred())
+
+			status := dbmodels.PayloadStatuses{
+				PayloadId:       payload.Id,
+				Service:         sv,
+				Source:          src,
+				Status:          st,
+				StatusMsg:       msg,
+				StatusMsgSearch: dbmodels.SearchVector(msg),
+				Date:            time.Now(),
+			}
+			Expect(queries.InsertPayloadStatus(db(), &status).Error).ToNot(HaveOccurred())
+		}
+
+		rr := get(endpoints.Statuses, "/api/v1/statuses", url.Values{"source": {source}, "q": {"certificate expired"}})
+		Expect(rr.Code).To(Equal(http.StatusOK))
+
+		var respData structs.StatusesData
+		Expect(json.Unmarshal(rr.Body.Bytes(), &respData)).To(Succeed())
+		Expect(respData.Count).To(Equal(int64(2)))
+
+		msgs := []string{}
+		for _, status := range respData.Data {
+			msgs = append(msgs, status.StatusMsg)
+		}
+		Expect(msgs).To(ConsistOf("TLS certificate expired for host", "certificate for host expired after 90 days"))
+
+		// only postgres ranks and highlights the matches
+		if db().Dialector.Name() == "postgres" {
+			Expect(respData.Data[0].StatusMsg).To(Equal("TLS certificate expired for host"))
+			Expect(respData.Data[0].Rank).To(BeNumerically(">", respData.Data[1].Rank))
+			Expect(respData.Data[0].Snippet).To(ContainSubstring("<mark>certificate</mark> <mark>expired</mark>"))
+		}
+	})
+
+	It("Escapes the markup of the status messages in snippets", func() {
+		msg := `<img src=x onerror="alert('x')"> keytab rotated`
+		payload := models.Payloads{RequestId: uuid.New().String()}
+		Expect(db().Create(&payload).Error).ToNot(HaveOccurred())
+
+		status := dbmodels.PayloadStatuses{
+			PayloadId:       payload.Id,
+			Service:         queries.GetServiceByName(db(), "filters-ingress"),
+			Source:          queries.GetSourceByName(db(), source),
+			Status:          queries.GetStatusByName(db(), "error"),
+			StatusMsg:       msg,
+			StatusMsgSearch: dbmodels.SearchVector(msg),
+			Date:            time.Now(),
+		}
+		Expect(queries.InsertPayloadStatus(db(), &status).Error).ToNot(HaveOccurred())
+
+		rr := get(endpoints.Statuses, "/api/v1/statuses", url.Values{"source": {source}, "q": {"keytab"}})
+		Expect(rr.Code).To(Equal(http.StatusOK))
+
+		var respData structs.StatusesData
+		Expect(json.Unmarshal(rr.Body.Bytes(), &respData)).To(Succeed())
+		Expect(respData.Data).To(HaveLen(1))
+		Expect(respData.Data[0].StatusMsg).To(Equal(msg))
+		Expect(respData.Data[0].Snippet).To(ContainSubstring(`&lt;img src=x onerror=&#34;alert(&#39;x&#39;)&#34;&gt;`))
+		Expect(respData.Data[0].Snippet).ToNot(ContainSubstring("<img"))
+	})
+
+	It("Matches payloads with a status message containing the values", func() {
+		rr := get(endpoints.Payloads, "/api/v1/payloads", url.Values{"account": {account, "other"}, "status_msg_contains": {"timeout", "full"}})
+		Expect(rr.Code).To(Equal(http.StatusOK))
+
+		var respData structs.PayloadsData
+		Expect(json.Unmarshal(rr.Body.Bytes(), &respData)).To(Succeed())
+		Expect(respData.Count).To(Equal(int64(3)))
+	})
+})
@@ -0,0 +1,78 @@
+package endpoints
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	l "github.com/redhatinsights/payload-tracker-go/internal/logging"
+	"github.com/redhatinsights/payload-tracker-go/internal/queries"
+	"github.com/redhatinsights/payload-tracker-go/internal/structs"
+)
+
+var (
+	RetrieveErrorFingerprints = queries.RetrieveErrorFingerprints
+
+	defaultErrorsRange = 24 * time.Hour
+	maxErrorsRange     = 31 * 24 * time.Hour
+	maxErrorSamples    = 10
+)
+
+// Errors returns the most frequent status message fingerprints of each service for /errors
+func Errors(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	q := structs.ErrorsQuery{
+		Service:  strings.ToLower(r.URL.Query().Get("service")),
+		Status:   "error",
+		End:      start.UTC(),
+		PageSize: 10,
+		Samples:  3,
+	}
+
+	if status := r.URL.Query().Get("status"); status != "" {
+		q.Status = strings.ToLower(status)
+	}
+
+	var err error
+	if q.End, err = timeParam(r, "date_lt", start, q.End); err != nil {
+		writeResponse(w, http.StatusBadRequest, getErrorBody(err.Error(), http.StatusBadRequest))
+		return
+	}
+	if q.Start, err = timeParam(r, "date_gte", start, q.End.Add(-defaultErrorsRange)); err != nil {
+		writeResponse(w, http.StatusBadRequest, getErrorBody(err.Error(), http.StatusBadRequest))
+		return
+	}
+
+	if !q.Start.Before(q.End) || q.End.Sub(q.Start) > maxErrorsRange {
+		message := "date_gte must be before date_lt and the range can span at most 31 days"
+		writeResponse(w, http.StatusBadRequest, getErrorBody(message, http.StatusBadRequest))
+		return
+	}
+
+	for param, value := range map[string]*int{"page": &q.Page, "page_size": &q.PageSize, "samples": &q.Samples} {
+		if r.URL.Query().Get(param) == "" {
+			continue
+		}
+		*value, err = strconv.Atoi(r.URL.Query().Get(param))
+		if err != nil || *value < 0 {
+			message := param + " must be a non-negative integer"
+			writeResponse(w, http.StatusBadRequest, getErrorBody(message, http.StatusBadRequest))
+			return
+		}
+	}
+	if q.Samples > maxErrorSamples {
+		q.Samples = maxErrorSamples
+	}
+
+	count, fingerprints, err := RetrieveErrorFingerprints(requestDb(r), q)
+	if err != nil {
+		l.Log.Errorf("Unable to retrieve error fingerprints: %v", err)
+		writeResponse(w, http.StatusInternalServerError, getErrorBody("Internal Server Issue", http.StatusInternalServerError))
+		return
+	}
+	observeDBTime(time.Since(start))
+
+	writeJSON(w, http.StatusOK, structs.ErrorsData{Count: count, Elapsed: time.Since(start).Seconds(), Data: fingerprints})
+}
@@ -0,0 +1,71 @@
+package endpoints_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gorm.io/gorm"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/endpoints"
+	"github.com/redhatinsights/payload-tracker-go/internal/structs"
+	"github.com/redhatinsights/payload-tracker-go/internal/utils/test"
+)
+
+var _ = Describe("Errors", func() {
+	var (
+		handler     http.Handler
+		rr          *httptest.ResponseRecorder
+		errorsQuery structs.ErrorsQuery
+	)
+
+	BeforeEach(func() {
+		rr = httptest.NewRecorder()
+		handler = http.HandlerFunc(endpoints.Errors)
+
+		endpoints.Db = func() *gorm.DB { return nil }
+		endpoints.RetrieveErrorFingerprints = func(_ *gorm.DB, q structs.ErrorsQuery) (int64, []structs.ErrorFingerprint, error) {
+			errorsQuery = q
+			return 0, []structs.ErrorFingerprint{}, nil
+		}
+	})
+
+	It("Should default to the error statuses of the last day", func() {
+		req, err := test.MakeTestRequest("/api/v1/errors", map[string]interface{}{"samples": 50})
+		Expect(err).To(BeNil())
+		handler.ServeHTTP(rr, req)
+		Expect(rr.Code).To(Equal(http.StatusOK))
+
+		Expect(errorsQuery.Status).To(Equal("error"))
+		Expect(errorsQuery.End.Sub(errorsQuery.Start)).To(Equal(24 * time.Hour))
+		Expect(errorsQuery.PageSize).To(Equal(10))
+		Expect(errorsQuery.Samples).To(Equal(10))
+	})
+
+	It("Should accept relative timestamps", func() {
+		req, err := test.MakeTestRequest("/api/v1/errors", map[string]interface{}{"date_gte": "now-2h", "date_lt": "now"})
+		Expect(err).To(BeNil())
+		handler.ServeHTTP(rr, req)
+		Expect(rr.Code).To(Equal(http.StatusOK))
+
+		Expect(errorsQuery.End).To(BeTemporally("~", time.Now(), time.Second))
+		Expect(errorsQuery.End.Sub(errorsQuery.Start)).To(Equal(2 * time.Hour))
+	})
+
+	It("Should reject invalid params", func() {
+		for _, query := range []map[string]interface{}{
+			{"date_gte": "yesterday"},
+			{"date_gte": "2022-06-07T12:00:00Z", "date_lt": "2022-06-07T11:00:00Z"},
+			{"date_gte": "2022-01-01T00:00:00Z", "date_lt": "2022-06-07T00:00:00Z"},
+			{"page_size": "ten"},
+		} {
+			rr = httptest.NewRecorder()
+			req, err := test.MakeTestRequest("/api/v1/errors", query)
+			Expect(err).To(BeNil())
+			handler.ServeHTTP(rr, req)
+			Expect(rr.Code).To(Equal(http.StatusBadRequest), "%v", query)
+		}
+	})
+})
@@ -1,25 +1,219 @@
 package endpoints
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"sync"
+	"time"
 
-	"github.com/redhatinsights/payload-tracker-go/internal/config"
 	"gorm.io/gorm"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/config"
+	l "github.com/redhatinsights/payload-tracker-go/internal/logging"
+	"github.com/redhatinsights/payload-tracker-go/internal/queries"
+	"github.com/redhatinsights/payload-tracker-go/internal/structs"
+)
+
+var RetrievePartitionBounds = queries.RetrievePartitionBounds
+
+const (
+	healthPass = "pass"
+	healthWarn = "warn"
+	healthFail = "fail"
 )
 
-// HealthCheckHandler checks for active DB connection and operational API
-func HealthCheckHandler(db *gorm.DB, cfg config.TrackerConfig) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		d, err := db.DB()
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+// HealthCheck checks a single dependency. Only critical checks fail the report, the others
+// are reported as warnings. Liveness checks run for /live, every check runs for /ready.
+type HealthCheck struct {
+	Name     string
+	Critical bool
+	Liveness bool
+	Check    func(ctx context.Context) error
+}
+
+// HealthChecker runs the health checks and caches their results
+type HealthChecker struct {
+	checks  []HealthCheck
+	timeout time.Duration
+	ttl     time.Duration
+
+	// held while checks run so concurrent probes wait for and reuse the same results
+	lock  sync.Mutex
+	cache map[string]structs.HealthCheckResult
+}
+
+func NewHealthChecker(cfg config.TrackerConfig, checks ...HealthCheck) *HealthChecker {
+	return &HealthChecker{
+		checks:  checks,
+		timeout: time.Duration(cfg.HealthConfig.CheckTimeoutMs) * time.Millisecond,
+		ttl:     time.Duration(cfg.HealthConfig.CacheSeconds) * time.Second,
+		cache:   make(map[string]structs.HealthCheckResult),
+	}
+}
+
+// Live returns a response for /live
+func (h *HealthChecker) Live(w http.ResponseWriter, r *http.Request) {
+	h.writeReport(w, h.Report(true))
+}
+
+// Ready returns a response for /ready and /health
+func (h *HealthChecker) Ready(w http.ResponseWriter, r *http.Request) {
+	h.writeReport(w, h.Report(false))
+}
+
+// Report runs the liveness checks, or all checks, reusing results younger than the cache ttl
+func (h *HealthChecker) Report(liveness bool) structs.HealthReport {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	var checks []HealthCheck
+	for _, check := range h.checks {
+		if !liveness || check.Liveness {
+			checks = append(checks, check)
+		}
+	}
+
+	var wg sync.WaitGroup
+	results := make([]structs.HealthCheckResult, len(checks))
+	refreshed := make([]bool, len(checks))
+
+	for i, check := range checks {
+		cached, ok := h.cache[check.Name]
+		if ok && time.Since(cached.CheckedAt) < h.ttl {
+			results[i] = cached
+			continue
+		}
+
+		refreshed[i] = true
+		wg.Add(1)
+		go func(i int, check HealthCheck) {
+			defer wg.Done()
+			results[i] = h.run(check)
+		}(i, check)
+	}
+
+	wg.Wait()
+
+	for i, result := range results {
+		if refreshed[i] {
+			h.cache[result.Name] = result
+		}
+	}
+
+	report := structs.HealthReport{Status: healthPass, Checks: results}
+	for _, result := range results {
+		switch {
+		case result.Status == healthFail:
+			report.Status = healthFail
+		case result.Status == healthWarn && report.Status == healthPass:
+			report.Status = healthWarn
 		}
-		if err = d.Ping(); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+	}
+
+	return report
+}
+
+func (h *HealthChecker) run(check HealthCheck) structs.HealthCheckResult {
+	// not bound to the request so a client hanging up doesn't end up in the cache
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.Check(ctx)
+
+	result := structs.HealthCheckResult{
+		Name:      check.Name,
+		Status:    healthPass,
+		Critical:  check.Critical,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		CheckedAt: start,
+	}
+
+	if err != nil {
+		l.Log.Warnf("Health check %s failed: %v", check.Name, err)
+		result.Error = err.Error()
+		result.Status = healthWarn
+		if check.Critical {
+			result.Status = healthFail
 		}
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
+	}
+
+	return result
+}
+
+func (h *HealthChecker) writeReport(w http.ResponseWriter, report structs.HealthReport) {
+	status := http.StatusOK
+	if report.Status == healthFail {
+		status = http.StatusServiceUnavailable
+	}
+
+	dataJson, err := json.Marshal(report)
+	if err != nil {
+		l.Log.Error(err)
+		writeResponse(w, http.StatusInternalServerError, getErrorBody("Internal Server Issue", http.StatusInternalServerError))
+		return
+	}
+
+	writeResponse(w, status, string(dataJson))
+}
+
+// DatabaseCheck pings the database
+func DatabaseCheck(db *gorm.DB) HealthCheck {
+	return HealthCheck{
+		Name:     "database",
+		Critical: true,
+		Check: func(ctx context.Context) error {
+			d, err := db.DB()
+			if err != nil {
+				return err
+			}
+			return d.PingContext(ctx)
+		},
+	}
+}
+
+// StorageBrokerCheck checks that storage-broker answers at all, only a 5xx or no response fails
+func StorageBrokerCheck(cfg config.TrackerConfig) HealthCheck {
+	return HealthCheck{
+		Name: "storage-broker",
+		Check: func(ctx context.Context) error {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.StorageBrokerURL, nil)
+			if err != nil {
+				return err
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode >= http.StatusInternalServerError {
+				return fmt.Errorf("storage-broker responded with %d", resp.StatusCode)
+			}
+			return nil
+		},
+	}
+}
+
+// PartitionCheck checks that the payload_statuses partition for tomorrow was created by the
+// create_partition job. Unpartitioned tables pass.
+func PartitionCheck(db *gorm.DB) HealthCheck {
+	return HealthCheck{
+		Name: "partition",
+		Check: func(ctx context.Context) error {
+			partitioned, bounds, err := RetrievePartitionBounds(db.WithContext(ctx), "payload_statuses")
+			if err != nil || !partitioned {
+				return err
+			}
+
+			tomorrow := time.Now().UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+			if !queries.PartitionCovers(bounds, tomorrow) {
+				return fmt.Errorf("no partition for %s", tomorrow.Format("2006-01-02"))
+			}
+			return nil
+		},
 	}
 }
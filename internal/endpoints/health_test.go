@@ -0,0 +1,132 @@
+package endpoints_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/config"
+	"github.com/redhatinsights/payload-tracker-go/internal/endpoints"
+	"github.com/redhatinsights/payload-tracker-go/internal/structs"
+)
+
+var _ = Describe("Health", func() {
+	var (
+		cfg   config.TrackerConfig
+		calls int
+	)
+
+	countingCheck := func(name string, critical bool, liveness bool, err error) endpoints.HealthCheck {
+		return endpoints.HealthCheck{
+			Name:     name,
+			Critical: critical,
+			Liveness: liveness,
+			Check: func(ctx context.Context) error {
+				calls++
+				return err
+			},
+		}
+	}
+
+	getReport := func(health *endpoints.HealthChecker, ready bool) (int, structs.HealthReport) {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/ready", nil)
+		if ready {
+			health.Ready(rr, req)
+		} else {
+			health.Live(rr, req)
+		}
+
+		var report structs.HealthReport
+		Expect(json.Unmarshal(rr.Body.Bytes(), &report)).To(Succeed())
+		return rr.Code, report
+	}
+
+	retrievePartitionBounds := endpoints.RetrievePartitionBounds
+
+	BeforeEach(func() {
+		cfg = *config.Get()
+		calls = 0
+	})
+
+	AfterEach(func() {
+		endpoints.RetrievePartitionBounds = retrievePartitionBounds
+	})
+
+	It("reports every check with its status", func() {
+		health := endpoints.NewHealthChecker(cfg,
+			countingCheck("database", true, false, nil),
+			countingCheck("storage-broker", false, false, errors.New("connection refused")),
+		)
+
+		code, report := getReport(health, true)
+		Expect(code).To(Equal(200))
+		Expect(report.Status).To(Equal("warn"))
+		Expect(report.Checks).To(HaveLen(2))
+		Expect(report.Checks[0].Status).To(Equal("pass"))
+		Expect(report.Checks[1].Status).To(Equal("warn"))
+		Expect(report.Checks[1].Error).To(Equal("connection refused"))
+	})
+
+	It("fails readiness when a critical check fails", func() {
+		health := endpoints.NewHealthChecker(cfg, countingCheck("database", true, false, errors.New("timeout")))
+
+		code, report := getReport(health, true)
+		Expect(code).To(Equal(503))
+		Expect(report.Status).To(Equal("fail"))
+	})
+
+	It("only runs liveness checks for liveness", func() {
+		health := endpoints.NewHealthChecker(cfg,
+			countingCheck("database", true, false, errors.New("timeout")),
+			countingCheck("kafka poll", true, true, nil),
+		)
+
+		code, report := getReport(health, false)
+		Expect(code).To(Equal(200))
+		Expect(report.Checks).To(HaveLen(1))
+		Expect(report.Checks[0].Name).To(Equal("kafka poll"))
+	})
+
+	It("caches the results", func() {
+		cfg.HealthConfig.CacheSeconds = 60
+		health := endpoints.NewHealthChecker(cfg, countingCheck("database", true, false, nil))
+
+		getReport(health, true)
+		getReport(health, true)
+		Expect(calls).To(Equal(1))
+
+		cfg.HealthConfig.CacheSeconds = 0
+		health = endpoints.NewHealthChecker(cfg, countingCheck("database", true, false, nil))
+
+		getReport(health, true)
+		getReport(health, true)
+		Expect(calls).To(Equal(3))
+	})
+
+	It("checks the partition for tomorrow exists", func() {
+		tomorrow := time.Now().UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+		bounds := []string{}
+		endpoints.RetrievePartitionBounds = func(_ *gorm.DB, _ string) (bool, []string, error) {
+			return true, bounds, nil
+		}
+
+		db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+		Expect(err).ToNot(HaveOccurred())
+
+		check := endpoints.PartitionCheck(db)
+		Expect(check.Check(context.Background())).To(MatchError(ContainSubstring("no partition for")))
+
+		bounds = []string{fmt.Sprintf("FOR VALUES FROM ('%s') TO ('%s')",
+			tomorrow.Format("2006-01-02 15:04:05-07"), tomorrow.Add(24*time.Hour).Format("2006-01-02 15:04:05-07"))}
+		Expect(check.Check(context.Background())).To(Succeed())
+	})
+})
@@ -0,0 +1,123 @@
+package endpoints
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redhatinsights/platform-go-middlewares/v2/identity"
+	"github.com/sirupsen/logrus"
+
+	l "github.com/redhatinsights/payload-tracker-go/internal/logging"
+)
+
+const requestIDHeader = "x-rh-insights-request-id"
+
+// requestIDPattern is what a passed on request id has to look like, it ends up in logs,
+// responses and audit records so anything longer or with other characters is replaced
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9-]{1,64}$`)
+
+type requestIDKey struct{}
+
+// probes hit these every few seconds, they are only logged at debug level
+var quietRoutes = []string{"/", "/live", "/ready", "/health"}
+
+// GetRequestID returns the x-rh-insights-request-id of the request the context belongs to
+func GetRequestID(ctx context.Context) string {
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+		return id
+	}
+	return ""
+}
+
+// AccessLogMiddleware logs one line per request. The x-rh-insights-request-id header is
+// passed on, or generated when missing or invalid, and echoed in the response.
+func AccessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		requestID := r.Header.Get(requestIDHeader)
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = strings.ReplaceAll(uuid.New().String(), "-", "")
+		}
+		w.Header().Set(requestIDHeader, requestID)
+
+		ww := &metricTrackingResponseWriter{Wrapped: w}
+		next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, requestID)))
+
+		if ww.code == 0 {
+			ww.code = http.StatusOK
+		}
+		route := routePattern(r)
+
+		entry := l.Log.WithFields(identityFields(r)).WithFields(logrus.Fields{
+			"insights_request_id": requestID,
+			"method":              r.Method,
+			"route":               route,
+			"path":                r.URL.Path,
+			"status":              ww.code,
+			"bytes":               ww.size,
+			"duration_ms":         float64(time.Since(start).Microseconds()) / 1000,
+		})
+
+		if stringInSlice(route, quietRoutes) {
+			entry.Debug("request")
+		} else {
+			entry.Info("request")
+		}
+	})
+}
+
+// requestIdentity is the part of the x-rh-identity header that is safe to log and store
+type requestIdentity struct {
+	OrgID string
+	User  string
+	Type  string
+}
+
+// getIdentity decodes the org, user and type from the x-rh-identity header. For associates
+// the user is their uuid and for systems the certificate cn, never an email or name.
+func getIdentity(r *http.Request) (requestIdentity, error) {
+	xrhid, err := identity.DecodeIdentity(r.Header.Get("x-rh-identity"))
+	if err != nil {
+		return requestIdentity{}, err
+	}
+
+	id := xrhid.Identity
+	ident := requestIdentity{OrgID: id.OrgID, Type: id.Type}
+
+	switch {
+	case id.User != nil && id.User.Username != "":
+		ident.User = id.User.Username
+	case id.ServiceAccount != nil:
+		ident.User = id.ServiceAccount.Username
+	case id.Associate != nil:
+		ident.User = id.Associate.RHatUUID
+	case id.System != nil:
+		ident.User = id.System.CommonName
+	}
+
+	return ident, nil
+}
+
+// identityFields returns the org and user of the x-rh-identity header for logging. The
+// header itself and the rest of the identity are never logged.
+func identityFields(r *http.Request) logrus.Fields {
+	if r.Header.Get("x-rh-identity") == "" {
+		return logrus.Fields{}
+	}
+
+	ident, err := getIdentity(r)
+	if err != nil {
+		return logrus.Fields{"identity_error": "undecodable identity header"}
+	}
+
+	return logrus.Fields{
+		"org_id":        ident.OrgID,
+		"user":          ident.User,
+		"identity_type": ident.Type,
+	}
+}
@@ -0,0 +1,92 @@
+package endpoints_test
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/endpoints"
+	l "github.com/redhatinsights/payload-tracker-go/internal/logging"
+)
+
+var _ = Describe("Access log", func() {
+	var (
+		router    *chi.Mux
+		hook      *logtest.Hook
+		level     logrus.Level
+		requestID string
+	)
+
+	BeforeEach(func() {
+		level = l.Log.GetLevel()
+		l.Log.SetLevel(logrus.InfoLevel)
+		hook = logtest.NewLocal(l.Log)
+
+		router = chi.NewRouter()
+		router.Use(endpoints.AccessLogMiddleware)
+		router.Get("/api/v1/payloads/{request_id}", func(w http.ResponseWriter, r *http.Request) {
+			requestID = endpoints.GetRequestID(r.Context())
+			w.Write([]byte("{}"))
+		})
+	})
+
+	AfterEach(func() {
+		l.Log.SetLevel(level)
+		l.Log.ReplaceHooks(make(logrus.LevelHooks))
+	})
+
+	It("generates a request id and logs the request", func() {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/payloads/abc", nil))
+
+		Expect(requestID).To(HaveLen(32))
+		Expect(rr.Header().Get("x-rh-insights-request-id")).To(Equal(requestID))
+
+		Expect(hook.Entries).To(HaveLen(1))
+		Expect(hook.LastEntry().Data["route"]).To(Equal("/api/v1/payloads/{request_id}"))
+		Expect(hook.LastEntry().Data["status"]).To(Equal(200))
+		Expect(hook.LastEntry().Data["insights_request_id"]).To(Equal(requestID))
+	})
+
+	It("passes on the request id and logs the identity without the header", func() {
+		header := base64.StdEncoding.EncodeToString([]byte(`{"identity": {"org_id": "12345", "type": "User",
+			"user": {"username": "jdoe", "email": "jdoe@example.com"}}}`))
+
+		req := httptest.NewRequest("GET", "/api/v1/payloads/abc", nil)
+		req.Header.Set("x-rh-insights-request-id", "d5c2b1a0")
+		req.Header.Set("x-rh-identity", header)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		Expect(rr.Header().Get("x-rh-insights-request-id")).To(Equal("d5c2b1a0"))
+
+		entry := hook.LastEntry()
+		Expect(entry.Data["insights_request_id"]).To(Equal("d5c2b1a0"))
+		Expect(entry.Data["org_id"]).To(Equal("12345"))
+		Expect(entry.Data["user"]).To(Equal("jdoe"))
+		for _, value := range entry.Data {
+			Expect(value).ToNot(Equal(header))
+			Expect(value).ToNot(Equal("jdoe@example.com"))
+		}
+	})
+	It("replaces request ids that are too long or have other characters", func() {
+		for _, invalid := range []string{strings.Repeat("a", 65), "d5c2b1a0\nlevel=error", "d5c2 b1a0", "<script>"} {
+			req := httptest.NewRequest("GET", "/api/v1/payloads/abc", nil)
+			req.Header.Set("x-rh-insights-request-id", invalid)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			Expect(requestID).To(HaveLen(32))
+			Expect(requestID).ToNot(Equal(invalid))
+			Expect(rr.Header().Get("x-rh-insights-request-id")).To(Equal(requestID))
+			Expect(hook.LastEntry().Data["insights_request_id"]).To(Equal(requestID))
+		}
+	})
+})
@@ -0,0 +1,248 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/config"
+	l "github.com/redhatinsights/payload-tracker-go/internal/logging"
+	"github.com/redhatinsights/payload-tracker-go/internal/structs"
+)
+
+// LogLinkQuery is what a log search link is built for
+type LogLinkQuery struct {
+	RequestID string
+	Service   string
+	From      time.Time
+	To        time.Time
+}
+
+// LogLinkGenerator builds links to the logs of a payload in one log search tool
+type LogLinkGenerator struct {
+	Name string
+	Link func(q LogLinkQuery) string
+}
+
+// NewLogLinkGenerators returns the generators of the configured providers, providers
+// without a url configured are skipped
+func NewLogLinkGenerators(cfg config.TrackerConfig) []LogLinkGenerator {
+	var generators []LogLinkGenerator
+
+	for _, provider := range cfg.LogLinksConfig.Providers {
+		switch provider {
+		case "kibana":
+			generators = append(generators, KibanaLinks(cfg.KibanaConfig))
+		case "loki":
+			if cfg.LogLinksConfig.LokiURL == "" {
+				l.Log.Warn("loki log links need loki.url, skipping them")
+				continue
+			}
+			generators = append(generators, LokiLinks(cfg.LogLinksConfig))
+		case "splunk":
+			if cfg.LogLinksConfig.SplunkURL == "" {
+				l.Log.Warn("splunk log links need splunk.url, skipping them")
+				continue
+			}
+			generators = append(generators, SplunkLinks(cfg.LogLinksConfig))
+		case "template":
+			if cfg.LogLinksConfig.TemplateURL == "" {
+				l.Log.Warn("template log links need loglinks.template.url, skipping them")
+				continue
+			}
+			generators = append(generators, TemplateLinks(cfg.LogLinksConfig.TemplateName, cfg.LogLinksConfig.TemplateURL))
+		default:
+			l.Log.Errorf("Log link provider %s not supported", provider)
+		}
+	}
+
+	return generators
+}
+
+// KibanaLinks links to a Kibana discover search
+func KibanaLinks(cfg config.KibanaCfg) LogLinkGenerator {
+	return LogLinkGenerator{
+		Name: "kibana",
+		Link: func(q LogLinkQuery) string {
+			query := kibanaQuery(q.RequestID, cfg.ServiceField, q.Service)
+
+			return fmt.Sprintf("%s?_g=(filters:!(),refreshInterval:(pause:!t,value:0),time:(from:'%s',to:'%s'))&_a=(columns:!(_source),filters:!(),index:'%s',interval:auto,query:(language:lucene,query:'%s'),sort:!('@timestamp',desc))",
+				cfg.DashboardURL, q.From.Format(time.RFC3339), q.To.Format(time.RFC3339), cfg.Index, risonEscape(query))
+		},
+	}
+}
+
+// kibanaQuery is the lucene query for the logs of a payload, the service is quoted as a phrase
+func kibanaQuery(requestID string, serviceField string, service string) string {
+	query := "request_id:" + requestID
+	if service != "" {
+		query += fmt.Sprintf(` AND %s:"%s"`, serviceField, strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(service))
+	}
+	return query
+}
+
+// risonEscape escapes a value for a rison string and then for the url it is passed in,
+// so that quotes, parentheses or & in it can't end the string or the url parameter
+func risonEscape(value string) string {
+	value = strings.NewReplacer("!", "!!", "'", "!'").Replace(value)
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
+
+// lokiExplore is the Grafana Explore pane state passed in the left url parameter
+type lokiExplore struct {
+	Datasource string      `json:"datasource,omitempty"`
+	Queries    []lokiQuery `json:"queries"`
+	Range      lokiRange   `json:"range"`
+}
+
+type lokiQuery struct {
+	RefID string `json:"refId"`
+	Expr  string `json:"expr"`
+}
+
+type lokiRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// LokiLinks links to a Grafana Explore search of Loki
+func LokiLinks(cfg config.LogLinksCfg) LogLinkGenerator {
+	return LogLinkGenerator{
+		Name: "loki",
+		Link: func(q LogLinkQuery) string {
+			selector := cfg.LokiSelector
+			if q.Service != "" {
+				selector = fmt.Sprintf("{%s=%q}", cfg.LokiServiceLabel, q.Service)
+			}
+
+			state, _ := json.Marshal(lokiExplore{
+				Datasource: cfg.LokiDatasource,
+				Queries:    []lokiQuery{{RefID: "A", Expr: fmt.Sprintf("%s |= %q", selector, q.RequestID)}},
+				Range:      lokiRange{From: unixMillis(q.From), To: unixMillis(q.To)},
+			})
+
+			return strings.TrimSuffix(cfg.LokiURL, "/") + "/explore?orgId=1&left=" + url.QueryEscape(string(state))
+		},
+	}
+}
+
+// SplunkLinks links to a Splunk search
+func SplunkLinks(cfg config.LogLinksCfg) LogLinkGenerator {
+	return LogLinkGenerator{
+		Name: "splunk",
+		Link: func(q LogLinkQuery) string {
+			search := fmt.Sprintf("search index=%s %s", cfg.SplunkIndex, splunkQuote(q.RequestID))
+			if q.Service != "" {
+				search += fmt.Sprintf(" %s=%s", cfg.SplunkServiceField, splunkQuote(q.Service))
+			}
+
+			params := url.Values{}
+			params.Set("q", search)
+			params.Set("earliest", strconv.FormatInt(q.From.Unix(), 10))
+			params.Set("latest", strconv.FormatInt(q.To.Unix(), 10))
+
+			return strings.TrimSuffix(cfg.SplunkURL, "/") + "/en-US/app/search/search?" + params.Encode()
+		},
+	}
+}
+
+// splunkQuote quotes a search value, Splunk only knows the \" and \\ escapes in strings
+func splunkQuote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// TemplateLinks fills in {request_id}, {service}, {from}, {to}, {from_ms} and {to_ms} of the url template
+func TemplateLinks(name string, template string) LogLinkGenerator {
+	return LogLinkGenerator{
+		Name: name,
+		Link: func(q LogLinkQuery) string {
+			return strings.NewReplacer(
+				"{request_id}", url.QueryEscape(q.RequestID),
+				"{service}", url.QueryEscape(q.Service),
+				"{from}", url.QueryEscape(q.From.Format(time.RFC3339)),
+				"{to}", url.QueryEscape(q.To.Format(time.RFC3339)),
+				"{from_ms}", unixMillis(q.From),
+				"{to_ms}", unixMillis(q.To),
+			).Replace(template)
+		},
+	}
+}
+
+func unixMillis(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+}
+
+// PayloadLogLinks returns a response for /payloads/{request_id}/logLinks
+func PayloadLogLinks(cfg config.TrackerConfig) http.HandlerFunc {
+	generators := NewLogLinkGenerators(cfg)
+	padding := time.Duration(cfg.LogLinksConfig.PaddingMinutes) * time.Minute
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		reqID := chi.URLParam(r, "request_id")
+
+		if !isValidUUID(reqID) {
+			IncInvalidAPIRequestIDs()
+			writeResponse(w, http.StatusBadRequest, getErrorBody(fmt.Sprintf("%s is not a valid UUID", reqID), http.StatusBadRequest))
+			return
+		}
+
+		dbStart := time.Now()
+		statuses := RetrieveRequestIdPayloads(requestDb(r), reqID, "date", "asc", "2")
+		observeDBTime(time.Since(dbStart))
+
+		if len(statuses) == 0 {
+			writeResponse(w, http.StatusNotFound, getErrorBody("payload with id: "+reqID+" not found", http.StatusNotFound))
+			return
+		}
+
+		service := r.URL.Query().Get("service")
+
+		// narrow the window to the statuses of the service when it reported any
+		var serviceStatuses []structs.SinglePayloadData
+		for _, status := range statuses {
+			if status.Service == service {
+				serviceStatuses = append(serviceStatuses, status)
+			}
+		}
+		if len(serviceStatuses) > 0 {
+			statuses = serviceStatuses
+		}
+
+		q := LogLinkQuery{
+			RequestID: reqID,
+			Service:   service,
+			From:      statuses[0].Date,
+			To:        statuses[0].Date,
+		}
+		for _, status := range statuses {
+			if status.Date.Before(q.From) {
+				q.From = status.Date
+			}
+			if status.Date.After(q.To) {
+				q.To = status.Date
+			}
+		}
+		q.From = q.From.Add(-padding).UTC()
+		q.To = q.To.Add(padding).UTC()
+
+		logLinks := structs.PayloadLogLinks{From: q.From, To: q.To, Links: []structs.LogLink{}}
+		for _, generator := range generators {
+			logLinks.Links = append(logLinks.Links, structs.LogLink{Name: generator.Name, Url: generator.Link(q)})
+		}
+
+		dataJson, err := json.Marshal(logLinks)
+		if err != nil {
+			l.Log.Error(err)
+			writeResponse(w, http.StatusInternalServerError, getErrorBody("Internal Server Issue", http.StatusInternalServerError))
+			return
+		}
+
+		writeResponse(w, http.StatusOK, string(dataJson))
+	}
+}
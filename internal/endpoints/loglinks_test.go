@@ -0,0 +1,168 @@
+package endpoints_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gorm.io/gorm"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/config"
+	"github.com/redhatinsights/payload-tracker-go/internal/endpoints"
+	"github.com/redhatinsights/payload-tracker-go/internal/structs"
+	"github.com/redhatinsights/payload-tracker-go/internal/utils/test"
+)
+
+var _ = Describe("PayloadLogLinks", func() {
+	var (
+		handler   http.Handler
+		rr        *httptest.ResponseRecorder
+		cfg       config.TrackerConfig
+		requestId string
+		query     map[string]interface{}
+		statuses  []structs.SinglePayloadData
+		received  time.Time
+	)
+
+	request := func() structs.PayloadLogLinks {
+		req, err := test.MakeTestRequest(fmt.Sprintf("/api/v1/payloads/%s/logLinks", requestId), query)
+		Expect(err).To(BeNil())
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("request_id", requestId)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+		handler = endpoints.PayloadLogLinks(cfg)
+		handler.ServeHTTP(rr, req)
+
+		var respData structs.PayloadLogLinks
+		json.Unmarshal(rr.Body.Bytes(), &respData)
+		return respData
+	}
+
+	BeforeEach(func() {
+		rr = httptest.NewRecorder()
+		requestId = getUUID()
+		query = make(map[string]interface{})
+
+		received, _ = time.Parse(time.RFC3339, "2022-06-07T11:00:00Z")
+		statuses = []structs.SinglePayloadData{
+			{Service: "ingress", Status: "received", Date: received},
+			{Service: "puptoo", Status: "processing", Date: received.Add(time.Minute)},
+			{Service: "puptoo", Status: "success", Date: received.Add(2 * time.Minute)},
+			{Service: "inventory", Status: "success", Date: received.Add(time.Hour)},
+		}
+		endpoints.RetrieveRequestIdPayloads = func(_ *gorm.DB, _ string, _ string, _ string, _ string) []structs.SinglePayloadData {
+			return statuses
+		}
+
+		cfg = *config.Get()
+		cfg.LogLinksConfig.Providers = []string{"kibana", "loki", "splunk", "template"}
+		cfg.LogLinksConfig.PaddingMinutes = 5
+		cfg.LogLinksConfig.LokiURL = "https://grafana.example.com/"
+		cfg.LogLinksConfig.SplunkURL = "https://splunk.example.com"
+		cfg.LogLinksConfig.TemplateName = "logs"
+		cfg.LogLinksConfig.TemplateURL = "https://logs.example.com/search?id={request_id}&from={from_ms}&to={to_ms}"
+	})
+
+	It("Should return 400 for an invalid request id", func() {
+		requestId = "1234"
+		request()
+		Expect(rr.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("Should return 404 when the payload has no statuses", func() {
+		statuses = nil
+		request()
+		Expect(rr.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("Should search from the first to the last status date", func() {
+		links := request()
+		Expect(rr.Code).To(Equal(http.StatusOK))
+
+		from := received.Add(-5 * time.Minute)
+		to := received.Add(time.Hour + 5*time.Minute)
+		Expect(links.From.Equal(from)).To(BeTrue())
+		Expect(links.To.Equal(to)).To(BeTrue())
+
+		Expect(links.Links).To(HaveLen(4))
+		Expect(links.Links[0].Name).To(Equal("kibana"))
+		Expect(links.Links[0].Url).To(ContainSubstring("time:(from:'2022-06-07T10:55:00Z',to:'2022-06-07T12:05:00Z')"))
+		Expect(links.Links[0].Url).To(ContainSubstring("query:'request_id%3A" + requestId + "'"))
+
+		Expect(links.Links[1].Name).To(Equal("loki"))
+		loki, err := url.Parse(links.Links[1].Url)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(loki.Path).To(Equal("/explore"))
+		Expect(loki.Query().Get("left")).To(ContainSubstring(`"from":"1654599300000"`))
+		Expect(loki.Query().Get("left")).To(ContainSubstring(requestId))
+
+		Expect(links.Links[2].Name).To(Equal("splunk"))
+		splunk, err := url.Parse(links.Links[2].Url)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(splunk.Query().Get("q")).To(Equal(fmt.Sprintf("search index=main %q", requestId)))
+		Expect(splunk.Query().Get("earliest")).To(Equal(fmt.Sprint(from.Unix())))
+		Expect(splunk.Query().Get("latest")).To(Equal(fmt.Sprint(to.Unix())))
+
+		Expect(links.Links[3]).To(Equal(structs.LogLink{
+			Name: "logs",
+			Url:  fmt.Sprintf("https://logs.example.com/search?id=%s&from=1654599300000&to=1654603500000", requestId),
+		}))
+	})
+
+	It("Should narrow the window to the statuses of the service", func() {
+		query["service"] = "puptoo"
+		links := request()
+		Expect(rr.Code).To(Equal(http.StatusOK))
+
+		Expect(links.From.Equal(received.Add(-4 * time.Minute))).To(BeTrue())
+		Expect(links.To.Equal(received.Add(7 * time.Minute))).To(BeTrue())
+		kibana, err := url.QueryUnescape(links.Links[0].Url)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(kibana).To(ContainSubstring(`AND app:"puptoo"`))
+		Expect(links.Links[2].Url).To(ContainSubstring(url.QueryEscape(`app="puptoo"`)))
+	})
+
+	It("Should escape the service in the kibana query", func() {
+		service := `x' OR "y")&z=!`
+		query["service"] = url.QueryEscape(service)
+		statuses = append(statuses, structs.SinglePayloadData{Service: service, Status: "error", Date: received})
+		links := request()
+		Expect(rr.Code).To(Equal(http.StatusOK))
+
+		Expect(links.Links[0].Url).ToNot(ContainSubstring("&z="))
+		kibana, err := url.QueryUnescape(links.Links[0].Url)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(kibana).To(ContainSubstring(`query:'request_id:` + requestId + ` AND app:"x!' OR \"y\")&z=!!"'`))
+	})
+
+	It("Should quote the service in the splunk search", func() {
+		service := "café \"x\" \\ \x00"
+		query["service"] = url.QueryEscape(service)
+		statuses = append(statuses, structs.SinglePayloadData{Service: service, Status: "error", Date: received})
+		links := request()
+		Expect(rr.Code).To(Equal(http.StatusOK))
+
+		splunk, err := url.Parse(links.Links[2].Url)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(splunk.Query().Get("q")).To(HaveSuffix(` app="café \"x\" \\ ` + "\x00" + `"`))
+	})
+
+	It("Should skip providers without a url", func() {
+		cfg.LogLinksConfig.LokiURL = ""
+		links := request()
+		Expect(rr.Code).To(Equal(http.StatusOK))
+		Expect(links.Links).To(HaveLen(3))
+		for _, link := range links.Links {
+			Expect(link.Name).ToNot(Equal("loki"))
+		}
+	})
+})
@@ -3,10 +3,14 @@ package endpoints
 import (
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	p "github.com/prometheus/client_golang/prometheus"
 	pa "github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/structs"
 )
 
 var (
@@ -32,8 +36,8 @@ var (
 
 	messagesProcessed = pa.NewCounterVec(p.CounterOpts{
 		Name: "payload_tracker_messages_processed",
-		Help: "Count of total messages processed",
-	}, []string{})
+		Help: "Count of total messages processed by service and status",
+	}, []string{"service", "status"})
 
 	messageProcessElapsed = pa.NewHistogramVec(p.HistogramOpts{
 		Name: "payload_tracker_message_process_seconds",
@@ -42,14 +46,41 @@ var (
 
 	messageProcessError = pa.NewCounterVec(p.CounterOpts{
 		Name: "payload_tracker_message_process_errors",
-		Help: "Count of message process errors",
-	}, []string{})
+		Help: "Count of message process errors by reason",
+	}, []string{"reason"})
+
+	messageAge = pa.NewHistogramVec(p.HistogramOpts{
+		Name:    "payload_tracker_message_age_seconds",
+		Help:    "Seconds between the status date, the kafka message timestamp and consumption",
+		Buckets: p.ExponentialBuckets(0.1, 3, 10),
+	}, []string{"interval"})
 
 	responseCodes = pa.NewCounterVec(p.CounterOpts{
 		Name: "payload_tracker_responses",
 		Help: "Count of response codes by code",
 	}, []string{"code"})
 
+	httpRequests = pa.NewCounterVec(p.CounterOpts{
+		Name: "payload_tracker_http_requests_total",
+		Help: "Count of http requests by route, method and code",
+	}, []string{"route", "method", "code"})
+
+	httpRequestElapsed = pa.NewHistogramVec(p.HistogramOpts{
+		Name: "payload_tracker_http_request_duration_seconds",
+		Help: "Number of seconds spent serving http requests by route, method and code",
+	}, []string{"route", "method", "code"})
+
+	httpRequestsInFlight = pa.NewGaugeVec(p.GaugeOpts{
+		Name: "payload_tracker_http_requests_in_flight",
+		Help: "Number of http requests currently being served by route",
+	}, []string{"route"})
+
+	httpResponseSize = pa.NewHistogramVec(p.HistogramOpts{
+		Name:    "payload_tracker_http_response_size_bytes",
+		Help:    "Size of http response bodies in bytes by route and method",
+		Buckets: p.ExponentialBuckets(100, 4, 8),
+	}, []string{"route", "method"})
+
 	consumedMessages = pa.NewCounterVec(p.CounterOpts{
 		Name: "payload_tracker_consumed_messages",
 		Help: "Number of messages consumed by payload tracker",
@@ -59,11 +90,143 @@ var (
 		Name: "payload_tracker_consume_errors",
 		Help: "Number of consumer errors encountered",
 	}, []string{})
+
+	consumerLag = pa.NewGaugeVec(p.GaugeOpts{
+		Name: "payload_tracker_consumer_lag",
+		Help: "Number of messages the consumer is behind by topic and assigned partition",
+	}, []string{"topic", "partition"})
+
+	consumerAssignedPartitions = pa.NewGauge(p.GaugeOpts{
+		Name: "payload_tracker_consumer_assigned_partitions",
+		Help: "Number of partitions assigned to the consumer",
+	})
+
+	consumerLastPoll = pa.NewGauge(p.GaugeOpts{
+		Name: "payload_tracker_consumer_last_poll_timestamp_seconds",
+		Help: "Unix time of the last successful kafka poll",
+	})
+
+	kafkaBrokersUp = pa.NewGauge(p.GaugeOpts{
+		Name: "payload_tracker_kafka_brokers_up",
+		Help: "Number of kafka brokers the consumer is connected to",
+	})
+
+	storageBrokerRequests = pa.NewCounterVec(p.CounterOpts{
+		Name: "payload_tracker_storage_broker_requests_total",
+		Help: "Count of storage-broker archive link requests by outcome",
+	}, []string{"outcome"})
+
+	storageBrokerRequestElapsed = pa.NewHistogramVec(p.HistogramOpts{
+		Name: "payload_tracker_storage_broker_request_duration_seconds",
+		Help: "Number of seconds spent waiting on storage-broker by outcome",
+	}, []string{"outcome"})
+
+	storageBrokerCircuitOpen = pa.NewGauge(p.GaugeOpts{
+		Name: "payload_tracker_storage_broker_circuit_open",
+		Help: "1 while the storage-broker circuit breaker rejects requests",
+	})
+
+	storageBrokerLinkCache = pa.NewCounterVec(p.CounterOpts{
+		Name: "payload_tracker_storage_broker_link_cache_total",
+		Help: "Count of archive link cache lookups by result",
+	}, []string{"result"})
+
+	webhookDeliveriesEnqueued = pa.NewCounter(p.CounterOpts{
+		Name: "payload_tracker_webhook_deliveries_enqueued_total",
+		Help: "Number of status events queued for webhook subscriptions",
+	})
+
+	webhookDeliveryAttempts = pa.NewCounterVec(p.CounterOpts{
+		Name: "payload_tracker_webhook_delivery_attempts_total",
+		Help: "Count of webhook delivery attempts by outcome",
+	}, []string{"outcome"})
+
+	webhookDeliveryElapsed = pa.NewHistogram(p.HistogramOpts{
+		Name: "payload_tracker_webhook_delivery_duration_seconds",
+		Help: "Number of seconds spent waiting on webhook receivers",
+	})
+
+	lifecycleEvents = pa.NewCounterVec(p.CounterOpts{
+		Name: "payload_tracker_lifecycle_events_total",
+		Help: "Count of payload lifecycle events published by event and outcome",
+	}, []string{"event", "outcome"})
+
+	sloBurnRate = pa.NewGaugeVec(p.GaugeOpts{
+		Name: "payload_tracker_slo_burn_rate",
+		Help: "Rate the error budget of an SLO is spent at over a window, 1 spends it exactly",
+	}, []string{"slo", "window"})
+
+	sloSLI = pa.NewGaugeVec(p.GaugeOpts{
+		Name: "payload_tracker_slo_sli",
+		Help: "Share of the payloads that met an SLO over a window",
+	}, []string{"slo", "window"})
+
+	sloObjective = pa.NewGaugeVec(p.GaugeOpts{
+		Name: "payload_tracker_slo_objective",
+		Help: "Objective of an SLO",
+	}, []string{"slo"})
+
+	anomaliesDetected = pa.NewCounterVec(p.CounterOpts{
+		Name: "payload_tracker_anomalies_total",
+		Help: "Count of error rate spikes and new error messages detected by kind and service",
+	}, []string{"kind", "service"})
+
+	serviceErrorRate = pa.NewGaugeVec(p.GaugeOpts{
+		Name: "payload_tracker_service_error_rate",
+		Help: "Share of the statuses of a service that are errors, over the recent window and the baseline",
+	}, []string{"service", "window"})
+
+	serviceErrorRateSpike = pa.NewGaugeVec(p.GaugeOpts{
+		Name: "payload_tracker_service_error_rate_spike",
+		Help: "1 while the error rate of a service spikes over its baseline",
+	}, []string{"service"})
 )
 
+// Label values beyond these limits are reported as "other" so that a misbehaving
+// producer can't blow up the number of series
+const (
+	maxServiceLabels = 100
+	maxStatusLabels  = 30
+)
+
+var (
+	serviceLabels = newLabelGuard(maxServiceLabels)
+	statusLabels  = newLabelGuard(maxStatusLabels)
+)
+
+// labelGuard caps the number of distinct values used for a metric label
+type labelGuard struct {
+	sync.Mutex
+	max  int
+	seen map[string]bool
+}
+
+func newLabelGuard(max int) *labelGuard {
+	return &labelGuard{max: max, seen: make(map[string]bool)}
+}
+
+func (g *labelGuard) value(v string) string {
+	g.Lock()
+	defer g.Unlock()
+
+	if v == "" {
+		return "other"
+	}
+	if g.seen[v] {
+		return v
+	}
+	if len(g.seen) >= g.max {
+		return "other"
+	}
+	g.seen[v] = true
+	return v
+}
+
 type metricTrackingResponseWriter struct {
 	Wrapped   http.ResponseWriter
 	UserAgent string
+	code      int
+	size      int
 }
 
 func incRequests() {
@@ -80,14 +243,59 @@ func IncConsumeErrors() {
 	consumeError.With(p.Labels{}).Inc()
 }
 
-// IncMessageProcessed  increments the messages processed count by 1
-func IncMessagesProcessed() {
-	messagesProcessed.With(p.Labels{}).Inc()
+// SetConsumerLag sets the lag of an assigned partition
+func SetConsumerLag(topic string, partition int32, lag int64) {
+	consumerLag.With(p.Labels{"topic": topic, "partition": strconv.Itoa(int(partition))}).Set(float64(lag))
+}
+
+// DeleteConsumerLag drops the lag of a partition that is no longer assigned
+func DeleteConsumerLag(topic string, partition int32) {
+	consumerLag.Delete(p.Labels{"topic": topic, "partition": strconv.Itoa(int(partition))})
+}
+
+func SetConsumerAssignedPartitions(count int) {
+	consumerAssignedPartitions.Set(float64(count))
+}
+
+func SetConsumerLastPoll(t time.Time) {
+	consumerLastPoll.Set(float64(t.Unix()))
+}
+
+func SetKafkaBrokersUp(count int) {
+	kafkaBrokersUp.Set(float64(count))
+}
+
+// IncMessageProcessed  increments the messages processed count for the service and status by 1
+func IncMessagesProcessed(service string, status string) {
+	messagesProcessed.With(p.Labels{"service": serviceLabels.value(service), "status": statusLabels.value(status)}).Inc()
 }
 
-// IncMessageProcessErrors increments the error count by 1
-func IncMessageProcessErrors() {
-	messageProcessError.With(p.Labels{}).Inc()
+// IncMessageProcessErrors increments the error count for the reason by 1
+func IncMessageProcessErrors(reason string) {
+	messageProcessError.With(p.Labels{"reason": reason}).Inc()
+}
+
+// ObserveMessageAge records how long a status took from its date to kafka and to the consumer.
+// Zero timestamps, e.g. messages read from a file have no kafka timestamp, are skipped.
+func ObserveMessageAge(date time.Time, kafkaTimestamp time.Time, now time.Time) {
+	observeAge := func(interval string, from time.Time, to time.Time) {
+		age := to.Sub(from).Seconds()
+		if age < 0 {
+			// producer clocks can be skewed
+			age = 0
+		}
+		messageAge.With(p.Labels{"interval": interval}).Observe(age)
+	}
+
+	if !kafkaTimestamp.IsZero() {
+		observeAge("kafka_to_consumer", kafkaTimestamp, now)
+		if !date.IsZero() {
+			observeAge("date_to_kafka", date, kafkaTimestamp)
+		}
+	}
+	if !date.IsZero() {
+		observeAge("date_to_consumer", date, now)
+	}
 }
 
 func IncInvalidConsumerRequestIDs() {
@@ -98,6 +306,65 @@ func IncInvalidAPIRequestIDs() {
 	apiInvalidRequestIDs.With(p.Labels{}).Inc()
 }
 
+func observeStorageBrokerRequest(outcome string, elapsed time.Duration) {
+	storageBrokerRequests.With(p.Labels{"outcome": outcome}).Inc()
+	if outcome != "circuit_open" {
+		storageBrokerRequestElapsed.With(p.Labels{"outcome": outcome}).Observe(elapsed.Seconds())
+	}
+}
+
+func setStorageBrokerCircuitOpen(open bool) {
+	if open {
+		storageBrokerCircuitOpen.Set(1)
+	} else {
+		storageBrokerCircuitOpen.Set(0)
+	}
+}
+
+func incStorageBrokerLinkCache(result string) {
+	storageBrokerLinkCache.With(p.Labels{"result": result}).Inc()
+}
+
+func IncWebhookDeliveriesEnqueued(count int) {
+	webhookDeliveriesEnqueued.Add(float64(count))
+}
+
+func ObserveWebhookDeliveryAttempt(outcome string, elapsed time.Duration) {
+	webhookDeliveryAttempts.With(p.Labels{"outcome": outcome}).Inc()
+	webhookDeliveryElapsed.Observe(elapsed.Seconds())
+}
+
+// IncLifecycleEvents counts a lifecycle event once kafka acknowledged or rejected it
+func IncLifecycleEvents(event string, outcome string) {
+	lifecycleEvents.With(p.Labels{"event": event, "outcome": outcome}).Inc()
+}
+
+// SetSLOGauges exports the objective, SLI and burn rates of an SLO
+func SetSLOGauges(status structs.SLOStatus) {
+	sloObjective.With(p.Labels{"slo": status.Name}).Set(status.Objective)
+	for _, w := range status.Windows {
+		labels := p.Labels{"slo": status.Name, "window": w.Window}
+		sloSLI.With(labels).Set(w.SLI)
+		sloBurnRate.With(labels).Set(w.BurnRate)
+	}
+}
+
+func IncAnomalies(kind string, service string) {
+	anomaliesDetected.With(p.Labels{"kind": kind, "service": serviceLabels.value(service)}).Inc()
+}
+
+// SetServiceErrorRate exports the recent and baseline error rates of a service and whether it spikes
+func SetServiceErrorRate(service string, recent float64, baseline float64, spike bool) {
+	service = serviceLabels.value(service)
+	serviceErrorRate.With(p.Labels{"service": service, "window": "recent"}).Set(recent)
+	serviceErrorRate.With(p.Labels{"service": service, "window": "baseline"}).Set(baseline)
+	if spike {
+		serviceErrorRateSpike.With(p.Labels{"service": service}).Set(1)
+	} else {
+		serviceErrorRateSpike.With(p.Labels{"service": service}).Set(0)
+	}
+}
+
 func observeDBTime(elapsed time.Duration) {
 	dbElapsed.With(p.Labels{}).Observe(elapsed.Seconds())
 }
@@ -111,21 +378,58 @@ func (m *metricTrackingResponseWriter) Header() http.Header {
 }
 
 func (m *metricTrackingResponseWriter) WriteHeader(statusCode int) {
-	responseCodes.With(p.Labels{"code": strconv.Itoa(statusCode)}).Inc()
+	if m.code == 0 {
+		m.code = statusCode
+	}
 	m.Wrapped.WriteHeader(statusCode)
 }
 
 func (m *metricTrackingResponseWriter) Write(b []byte) (int, error) {
-	return m.Wrapped.Write(b)
+	// net/http sends a 200 for handlers that write without calling WriteHeader
+	if m.code == 0 {
+		m.code = http.StatusOK
+	}
+	n, err := m.Wrapped.Write(b)
+	m.size += n
+	return n, err
+}
+
+// routePattern returns the chi route pattern, e.g. /api/v1/payloads/{request_id}, so that
+// request ids don't end up in the labels
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		if pattern := rctx.RoutePattern(); pattern != "" {
+			return pattern
+		}
+	}
+	return "unknown"
 }
 
 // ResponseMetricsMiddleware wraps the ResponseWriter such that metrics for each
 // response type get tracked
 func ResponseMetricsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
 		ww := &metricTrackingResponseWriter{
 			Wrapped: w,
 		}
+
+		inFlight := httpRequestsInFlight.With(p.Labels{"route": routePattern(r)})
+		inFlight.Inc()
+		defer inFlight.Dec()
+
 		next.ServeHTTP(ww, r)
+
+		// a handler that writes nothing at all still results in a 200
+		if ww.code == 0 {
+			ww.code = http.StatusOK
+		}
+		code := strconv.Itoa(ww.code)
+		route := routePattern(r)
+
+		responseCodes.With(p.Labels{"code": code}).Inc()
+		httpRequests.With(p.Labels{"route": route, "method": r.Method, "code": code}).Inc()
+		httpRequestElapsed.With(p.Labels{"route": route, "method": r.Method, "code": code}).Observe(time.Since(start).Seconds())
+		httpResponseSize.With(p.Labels{"route": route, "method": r.Method}).Observe(float64(ww.size))
 	})
 }
@@ -0,0 +1,70 @@
+package endpoints
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/go-chi/chi/v5"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	p "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var _ = Describe("Metric label guard", func() {
+	It("reports values beyond the limit as other", func() {
+		guard := newLabelGuard(3)
+
+		for i := 0; i < 3; i++ {
+			Expect(guard.value(fmt.Sprintf("service-%d", i))).To(Equal(fmt.Sprintf("service-%d", i)))
+		}
+
+		Expect(guard.value("service-3")).To(Equal("other"))
+		Expect(guard.value("service-0")).To(Equal("service-0"))
+	})
+
+	It("reports empty values as other", func() {
+		guard := newLabelGuard(3)
+
+		Expect(guard.value("")).To(Equal("other"))
+	})
+})
+
+var _ = Describe("Response metrics middleware", func() {
+	var router *chi.Mux
+
+	BeforeEach(func() {
+		router = chi.NewRouter()
+		router.With(ResponseMetricsMiddleware).Get("/metrics-test/{request_id}", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("hello"))
+		})
+		router.With(ResponseMetricsMiddleware).Get("/metrics-test/{request_id}/missing", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		})
+	})
+
+	serve := func(path string) {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	It("counts handlers that never call WriteHeader as 200 by route pattern", func() {
+		labels := p.Labels{"route": "/metrics-test/{request_id}", "method": "GET", "code": "200"}
+		before := testutil.ToFloat64(httpRequests.With(labels))
+
+		serve("/metrics-test/abc")
+		serve("/metrics-test/def")
+
+		Expect(testutil.ToFloat64(httpRequests.With(labels))).To(Equal(before + 2))
+		Expect(testutil.ToFloat64(httpRequestsInFlight.With(p.Labels{"route": "/metrics-test/{request_id}"}))).To(Equal(0.0))
+	})
+
+	It("labels the code set with WriteHeader", func() {
+		labels := p.Labels{"route": "/metrics-test/{request_id}/missing", "method": "GET", "code": "404"}
+		before := testutil.ToFloat64(httpRequests.With(labels))
+
+		serve("/metrics-test/abc/missing")
+
+		Expect(testutil.ToFloat64(httpRequests.With(labels))).To(Equal(before + 1))
+	})
+})
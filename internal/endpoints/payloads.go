@@ -3,6 +3,7 @@ package endpoints
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -14,6 +15,7 @@ import (
 	l "github.com/redhatinsights/payload-tracker-go/internal/logging"
 	"github.com/redhatinsights/payload-tracker-go/internal/queries"
 	"github.com/redhatinsights/payload-tracker-go/internal/structs"
+	"github.com/sirupsen/logrus"
 )
 
 var (
@@ -22,15 +24,21 @@ var (
 	Db                        = getDb
 )
 
-func CreatePayloadArchiveLinkHandler(cfg config.TrackerConfig) http.HandlerFunc {
+// CreatePayloadArchiveLinkHandler returns the archive link handler of the configured requestor
+func CreatePayloadArchiveLinkHandler(cfg config.TrackerConfig) (http.HandlerFunc, error) {
 	switch cfg.RequestConfig.RequestorImpl {
 	case "storage-broker":
-		return PayloadArchiveLink(RequestArchiveLink(cfg.StorageBrokerURL, cfg.StorageBrokerRequestTimeout))
+		return PayloadArchiveLink(NewStorageBrokerClient(cfg).ArchiveLink), nil
+	case "s3":
+		requestor, err := NewS3Requestor(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create the s3 requestor: %w", err)
+		}
+		return PayloadArchiveLink(requestor.ArchiveLink), nil
 	case "mock":
-		return MockArchiveLink
+		return MockArchiveLink, nil
 	default:
-		l.Log.Errorf("Requestor implementation %s not supported", cfg.RequestConfig.RequestorImpl)
-		return nil
+		return nil, fmt.Errorf("requestor implementation %s not supported", cfg.RequestConfig.RequestorImpl)
 	}
 }
 
@@ -66,17 +74,17 @@ func Payloads(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !validTimestamps(q, false) {
-		message := "invalid timestamp format provided"
+	if q.Terminal != "" && !stringInSlice(q.Terminal, validTerminal) {
+		message := "terminal must be one of " + strings.Join(validTerminal, ", ")
 		writeResponse(w, http.StatusBadRequest, getErrorBody(message, http.StatusBadRequest))
 		return
 	}
 
-	count, payloads := RetrievePayloads(Db(), q.Page, q.PageSize, q)
+	count, payloads := RetrievePayloads(requestDb(r), q.Page, q.PageSize, q)
 	duration := time.Since(start).Seconds()
 	observeDBTime(time.Since(start))
 
-	payloadsData := structs.PayloadsData{count, duration, payloads}
+	payloadsData := structs.PayloadsData{Count: count, Elapsed: duration, Data: payloads}
 
 	dataJson, err := json.Marshal(payloadsData)
 	if err != nil {
@@ -112,7 +120,9 @@ func RequestIdPayloads(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	payloads := RetrieveRequestIdPayloads(Db(), reqID, q.SortBy, q.SortDir, verbosity)
+	dbStart := time.Now()
+	payloads := RetrieveRequestIdPayloads(requestDb(r), reqID, q.SortBy, q.SortDir, verbosity)
+	observeDBTime(time.Since(dbStart))
 
 	if payloads == nil || len(payloads) == 0 {
 		writeResponse(w, http.StatusNotFound, getErrorBody("payload with id: "+reqID+" not found", http.StatusNotFound))
@@ -133,6 +143,30 @@ func RequestIdPayloads(w http.ResponseWriter, r *http.Request) {
 	writeResponse(w, http.StatusOK, string(dataJson))
 }
 
+// PayloadTimeline returns a response for /payloads/{request_id}/timeline
+func PayloadTimeline(w http.ResponseWriter, r *http.Request) {
+
+	reqID := chi.URLParam(r, "request_id")
+
+	dbStart := time.Now()
+	statuses := RetrieveRequestIdPayloads(requestDb(r), reqID, "date", "asc", "0")
+	observeDBTime(time.Since(dbStart))
+
+	if len(statuses) == 0 {
+		writeResponse(w, http.StatusNotFound, getErrorBody("payload with id: "+reqID+" not found", http.StatusNotFound))
+		return
+	}
+
+	dataJson, err := json.Marshal(queries.BuildTimeline(reqID, statuses))
+	if err != nil {
+		l.Log.Error(err)
+		writeResponse(w, http.StatusInternalServerError, getErrorBody("Internal Server Issue", http.StatusInternalServerError))
+		return
+	}
+
+	writeResponse(w, http.StatusOK, string(dataJson))
+}
+
 // PayloadArchiveLink returns a response for /payloads/{request_id}/archiveLink
 func PayloadArchiveLink(requestArchiveLink func(context.Context, string) (*structs.PayloadArchiveLink, error)) http.HandlerFunc {
 
@@ -142,36 +176,53 @@ func PayloadArchiveLink(requestArchiveLink func(context.Context, string) (*struc
 
 		statusCode, err := checkForRole(r, config.Get().StorageBrokerURLRole)
 		if err != nil {
+			auditArchiveLink(r, reqID, outcomeForStatus(statusCode), statusCode, err)
 			writeResponse(w, statusCode, getErrorBody(fmt.Sprintf("%v", err), statusCode))
 			return
 		}
 
 		if !isValidUUID(reqID) {
 			IncInvalidAPIRequestIDs()
+			auditArchiveLink(r, reqID, "invalid_request_id", http.StatusBadRequest, nil)
 			writeResponse(w, http.StatusBadRequest, getErrorBody(fmt.Sprintf("%s is not a valid UUID", reqID), http.StatusBadRequest))
 			return
 		}
 
 		payloadArchiveLink, err := requestArchiveLink(r.Context(), reqID)
-		if err != nil {
+		switch {
+		case errors.Is(err, ErrArchiveNotFound):
+			auditArchiveLink(r, reqID, "not_found", http.StatusNotFound, nil)
+			writeResponse(w, http.StatusNotFound, getErrorBody("Payload not found", http.StatusNotFound))
+			return
+		case errors.Is(err, ErrStorageBrokerUnavailable):
+			auditArchiveLink(r, reqID, "unavailable", http.StatusServiceUnavailable, err)
+			writeResponse(w, http.StatusServiceUnavailable, getErrorBody(fmt.Sprintf("%v", err), http.StatusServiceUnavailable))
+			return
+		case err != nil:
 			l.Log.Errorf("Error getting archive link from storage-broker for request id: %s, error: %v", reqID, err)
+			auditArchiveLink(r, reqID, "error", http.StatusInternalServerError, err)
 			writeResponse(w, http.StatusInternalServerError, getErrorBody(fmt.Sprintf("%v", err), http.StatusInternalServerError))
 			return
 		}
 
-		if payloadArchiveLink.Url == "" {
-			writeResponse(w, http.StatusNotFound, getErrorBody("Payload not found", http.StatusNotFound))
-			return
-		}
-
 		dataJson, err := json.Marshal(payloadArchiveLink)
 		if err != nil {
 			l.Log.Error(err)
+			auditArchiveLink(r, reqID, "error", http.StatusInternalServerError, err)
 			writeResponse(w, http.StatusInternalServerError, getErrorBody("Error converting parsed response to json", http.StatusInternalServerError))
 			return
 		}
 
-		l.Log.Infof("Link generated for payload %s from identity %s: %s", reqID, r.Header.Get("x-rh-identity"), string(dataJson))
+		// a link is only handed out once it's on record
+		if err := auditArchiveLink(r, reqID, "success", http.StatusOK, nil); err != nil {
+			writeResponse(w, http.StatusInternalServerError, getErrorBody("Unable to record the archive link request", http.StatusInternalServerError))
+			return
+		}
+
+		l.Log.WithFields(identityFields(r)).WithFields(logrus.Fields{
+			"insights_request_id": GetRequestID(r.Context()),
+			"request_id":          reqID,
+		}).Infof("Link generated for payload %s", reqID)
 		writeResponse(w, http.StatusOK, string(dataJson))
 	}
 }
@@ -205,12 +256,9 @@ func PayloadKibanaLink(w http.ResponseWriter, r *http.Request) {
 	kibanaUrl := cfg.KibanaConfig.DashboardURL
 	kibanaIndex := cfg.KibanaConfig.Index
 
-	kibanaQuery := "request_id:" + reqID
-	if service != "" {
-		kibanaQuery += fmt.Sprintf(" AND %s:%s", serviceField, service)
-	}
+	query := risonEscape(kibanaQuery(reqID, serviceField, service))
 
-	kibanaLink := fmt.Sprintf("%s?_g=(filters:!(),refreshInterval:(pause:!t,value:0),time:(from:now-24h,to:now))&_a=(columns:!(_source),filters:!(),index:'%s',interval:auto,query:(language:lucene,query:'%s'),sort:!('@timestamp',desc))", kibanaUrl, kibanaIndex, kibanaQuery)
+	kibanaLink := fmt.Sprintf("%s?_g=(filters:!(),refreshInterval:(pause:!t,value:0),time:(from:now-24h,to:now))&_a=(columns:!(_source),filters:!(),index:'%s',interval:auto,query:(language:lucene,query:'%s'),sort:!('@timestamp',desc))", kibanaUrl, kibanaIndex, query)
 	logging.Log.Debugf("Generated kibana link: %s", kibanaLink)
 
 	payloadKibanaLink := structs.PayloadKibanaLink{
@@ -3,6 +3,7 @@ package endpoints_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -13,11 +14,13 @@ import (
 	"github.com/google/uuid"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 
 	"github.com/redhatinsights/payload-tracker-go/internal/config"
 	"github.com/redhatinsights/payload-tracker-go/internal/endpoints"
 	"github.com/redhatinsights/payload-tracker-go/internal/models"
+	dbmodels "github.com/redhatinsights/payload-tracker-go/internal/models/db"
 	"github.com/redhatinsights/payload-tracker-go/internal/structs"
 	"github.com/redhatinsights/payload-tracker-go/internal/utils/test"
 )
@@ -187,6 +190,69 @@ var _ = Describe("Payloads", func() {
 			})
 		})
 
+		Context("With invalid terminal parameter", func() {
+			It("should return HTTP 400", func() {
+				query["terminal"] = "maybe"
+				req, err := test.MakeTestRequest("/api/v1/payloads", query)
+				Expect(err).To(BeNil())
+				handler.ServeHTTP(rr, req)
+				Expect(rr.Code).To(Equal(400))
+				Expect(rr.Body).ToNot(BeNil())
+			})
+		})
+
+		Context("With latest status data from DB", func() {
+			It("should return the latest status", func() {
+				query["status"] = "error"
+				query["terminal"] = "true"
+				req, err := test.MakeTestRequest("/api/v1/payloads", query)
+				Expect(err).To(BeNil())
+
+				terminal := true
+				payloadData := models.Payloads{
+					Id:         1,
+					RequestId:  getUUID(),
+					LastStatus: "error",
+					Terminal:   &terminal,
+					ErrorMsg:   "timeout",
+				}
+
+				payloadReturnCount = 1
+				payloadReturnData = []models.Payloads{payloadData}
+
+				handler.ServeHTTP(rr, req)
+				Expect(rr.Code).To(Equal(200))
+
+				var respData structs.PayloadsData
+
+				readBody, _ := ioutil.ReadAll(rr.Body)
+				json.Unmarshal(readBody, &respData)
+
+				Expect(respData.Data[0].LastStatus).To(Equal("error"))
+				Expect(*respData.Data[0].Terminal).To(BeTrue())
+				Expect(respData.Data[0].ErrorMsg).To(Equal("timeout"))
+			})
+		})
+
+		Context("With filter parameters", func() {
+			It("should pass the negated values of split filters only", func() {
+				var apiQuery structs.Query
+				endpoints.RetrievePayloads = func(_ *gorm.DB, _ int, _ int, q structs.Query) (int64, []models.Payloads) {
+					apiQuery = q
+					return 0, nil
+				}
+
+				req, err := http.NewRequest("GET", "/api/v1/payloads?org_id=!1,!2&status_msg_contains=!timeout&status_msg=!important", nil)
+				Expect(err).To(BeNil())
+				handler.ServeHTTP(rr, req)
+				Expect(rr.Code).To(Equal(200))
+
+				Expect(apiQuery.OrgID).To(Equal(structs.Filter{Values: []string{"1", "2"}, Negate: true}))
+				Expect(apiQuery.StatusMsgContains).To(Equal(structs.Filter{Values: []string{"!timeout"}}))
+				Expect(apiQuery.StatusMsg).To(Equal(structs.Filter{Values: []string{"!important"}}))
+			})
+		})
+
 		validTimestamps := map[string]string{
 			"created_at_lt":  "2021-08-04T17:53:29.724476-04:00",
 			"created_at_lte": "2021-08-04T17:53:29.724476-04:00",
@@ -207,6 +273,30 @@ var _ = Describe("Payloads", func() {
 			})
 		})
 
+		Context("With a canceled request", func() {
+			It("should cancel the query", func() {
+				sqliteDb, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+				Expect(err).ToNot(HaveOccurred())
+				getDb := endpoints.Db
+				endpoints.Db = func() *gorm.DB { return sqliteDb }
+				defer func() { endpoints.Db = getDb }()
+
+				var queryErr error
+				endpoints.RetrievePayloads = func(db *gorm.DB, _ int, _ int, _ structs.Query) (int64, []models.Payloads) {
+					var one int
+					queryErr = db.Raw("SELECT 1").Scan(&one).Error
+					return 0, nil
+				}
+
+				req, err := test.MakeTestRequest("/api/v1/payloads", query)
+				Expect(err).To(BeNil())
+				ctx, cancel := context.WithCancel(req.Context())
+				cancel()
+				handler.ServeHTTP(rr, req.WithContext(ctx))
+				Expect(queryErr).To(MatchError(context.Canceled))
+			})
+		})
+
 		invalidTimestamps := map[string]string{
 			"created_at_lt":  "invalid",
 			"created_at_lte": "nope",
@@ -433,6 +523,107 @@ var _ = Describe("RequestIdPayloads", func() {
 	})
 })
 
+var _ = Describe("PayloadTimeline", func() {
+	var (
+		handler http.Handler
+		rr      *httptest.ResponseRecorder
+
+		requestId string
+		req       *http.Request
+	)
+
+	BeforeEach(func() {
+		rr = httptest.NewRecorder()
+		handler = http.HandlerFunc(endpoints.PayloadTimeline)
+
+		endpoints.RetrieveRequestIdPayloads = mockedRequestIdPayloads
+		requestId = getUUID()
+
+		var err error
+		req, err = test.MakeTestRequest(fmt.Sprintf("/api/v1/payloads/%s/timeline", requestId), map[string]interface{}{})
+		Expect(err).To(BeNil())
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("request_id", requestId)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	})
+
+	Context("When the payload has no statuses", func() {
+		It("should return HTTP 404", func() {
+			reqIdPayloadData = nil
+			handler.ServeHTTP(rr, req)
+			Expect(rr.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Context("With statuses from DB", func() {
+		It("should return a span per service:source and the gaps between them", func() {
+			reqIdPayloadData = getFourReqIdStatuses(requestId, "0")
+			handler.ServeHTTP(rr, req)
+			Expect(rr.Code).To(Equal(http.StatusOK))
+
+			var respData structs.PayloadTimeline
+
+			readBody, _ := ioutil.ReadAll(rr.Body)
+			Expect(json.Unmarshal(readBody, &respData)).To(Succeed())
+
+			Expect(respData.RequestID).To(Equal(requestId))
+			Expect(respData.DurationMs).To(Equal(int64(13604)))
+			Expect(respData.Outcome).To(Equal("success"))
+
+			Expect(respData.Spans).To(HaveLen(3))
+			Expect(respData.Spans[0].Name).To(Equal("puptoo:undefined"))
+			Expect(respData.Spans[0].OffsetMs).To(Equal(int64(0)))
+			Expect(respData.Spans[0].DurationMs).To(Equal(int64(9970)))
+			Expect(respData.Spans[0].Outcome).To(BeEmpty())
+			Expect(respData.Spans[0].Statuses).To(HaveLen(2))
+
+			Expect(respData.Spans[1].Name).To(Equal("puptoo:inventory"))
+			Expect(respData.Spans[1].OffsetMs).To(Equal(int64(6979)))
+			Expect(respData.Spans[1].DurationMs).To(Equal(int64(5625)))
+			Expect(respData.Spans[1].Outcome).To(Equal("success"))
+			Expect(respData.Spans[1].Statuses[1].OffsetMs).To(Equal(int64(12604)))
+
+			Expect(respData.Spans[2].Name).To(Equal("puptoo:engine"))
+			Expect(respData.Spans[2].DurationMs).To(Equal(int64(601)))
+
+			// the inventory span overlaps the first one, only the engine span starts after a gap
+			Expect(respData.Gaps).To(HaveLen(1))
+			Expect(respData.Gaps[0].From).To(Equal("puptoo:inventory"))
+			Expect(respData.Gaps[0].To).To(Equal("puptoo:engine"))
+			Expect(respData.Gaps[0].OffsetMs).To(Equal(int64(12604)))
+			Expect(respData.Gaps[0].DurationMs).To(Equal(int64(399)))
+		})
+
+		It("should not report an outcome for a payload still in progress", func() {
+			statuses := getFourReqIdStatuses(requestId, "0")
+			reqIdPayloadData = statuses[:3]
+			handler.ServeHTTP(rr, req)
+			Expect(rr.Code).To(Equal(http.StatusOK))
+
+			var respData structs.PayloadTimeline
+
+			readBody, _ := ioutil.ReadAll(rr.Body)
+			Expect(json.Unmarshal(readBody, &respData)).To(Succeed())
+
+			Expect(respData.Outcome).To(BeEmpty())
+			Expect(respData.Spans).To(HaveLen(2))
+			Expect(respData.Gaps).To(BeEmpty())
+		})
+	})
+})
+
+var _ = Describe("CreatePayloadArchiveLinkHandler", func() {
+	It("Returns an error for an unsupported requestor", func() {
+		cfg := *config.Get()
+		cfg.RequestConfig.RequestorImpl = "ftp"
+
+		handler, err := endpoints.CreatePayloadArchiveLinkHandler(cfg)
+		Expect(err).To(MatchError(ContainSubstring("ftp not supported")))
+		Expect(handler).To(BeNil())
+	})
+})
+
 var _ = Describe("PayloadArchiveLink", func() {
 	var (
 		handler http.Handler
@@ -440,11 +631,21 @@ var _ = Describe("PayloadArchiveLink", func() {
 
 		requestId string
 		query     map[string]interface{}
+		audits    []dbmodels.ArchiveLinkAudit
+		auditErr  error
 	)
 
 	BeforeEach(func() {
 		rr = httptest.NewRecorder()
 
+		audits = nil
+		auditErr = nil
+		endpoints.PrimaryDb = func() *gorm.DB { return nil }
+		endpoints.InsertArchiveLinkAudit = func(_ *gorm.DB, audit *dbmodels.ArchiveLinkAudit) *gorm.DB {
+			audits = append(audits, *audit)
+			return &gorm.DB{Error: auditErr}
+		}
+
 		// Mock out the storage broker server.  This allows us to test the response handling code.
 		mockStorageBrokerServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Content-Type", "application/json")
@@ -452,7 +653,9 @@ var _ = Describe("PayloadArchiveLink", func() {
 			w.Write([]byte("{\"url\": \"www.example.com\"}"))
 		}))
 
-		handler = http.HandlerFunc(endpoints.PayloadArchiveLink(endpoints.RequestArchiveLink(mockStorageBrokerServer.URL, 10)))
+		cfg := *config.Get()
+		cfg.StorageBrokerURL = mockStorageBrokerServer.URL
+		handler = http.HandlerFunc(endpoints.PayloadArchiveLink(endpoints.NewStorageBrokerClient(cfg).ArchiveLink))
 
 		requestId = getUUID()
 		query = make(map[string]interface{})
@@ -465,6 +668,8 @@ var _ = Describe("PayloadArchiveLink", func() {
 			req.Header.Set("x-rh-identity", validIdentityHeader)
 			handler.ServeHTTP(rr, req)
 			Expect(rr.Code).To(Equal(http.StatusBadRequest))
+			Expect(audits).To(HaveLen(1))
+			Expect(audits[0].Outcome).To(Equal("invalid_request_id"))
 		})
 	})
 
@@ -474,6 +679,8 @@ var _ = Describe("PayloadArchiveLink", func() {
 			Expect(err).To(BeNil())
 			handler.ServeHTTP(rr, req)
 			Expect(rr.Code).To(Equal(http.StatusUnauthorized))
+			Expect(audits).To(HaveLen(1))
+			Expect(audits[0].Outcome).To(Equal("unauthorized"))
 		})
 	})
 
@@ -484,6 +691,9 @@ var _ = Describe("PayloadArchiveLink", func() {
 			req.Header.Set("x-rh-identity", invalidIdentityHeader)
 			handler.ServeHTTP(rr, req)
 			Expect(rr.Code).To(Equal(http.StatusForbidden))
+			Expect(audits).To(HaveLen(1))
+			Expect(audits[0].Outcome).To(Equal("forbidden"))
+			Expect(audits[0].StatusCode).To(Equal(http.StatusForbidden))
 		})
 	})
 
@@ -507,6 +717,29 @@ var _ = Describe("PayloadArchiveLink", func() {
 			json.Unmarshal(readBody, &respData)
 
 			Expect(respData.Url).To(Equal("www.example.com"))
+
+			Expect(audits).To(HaveLen(1))
+			Expect(audits[0].RequestId).To(Equal(requestId))
+			Expect(audits[0].Outcome).To(Equal("success"))
+			Expect(audits[0].StatusCode).To(Equal(http.StatusOK))
+		})
+	})
+
+	Context("When the audit record can't be written", func() {
+		It("Should return 500 without the URL", func() {
+			auditErr = errors.New("connection refused")
+
+			req, err := test.MakeTestRequest(fmt.Sprintf("/api/v1/payloads/%s/archiveLink", requestId), query)
+			Expect(err).To(BeNil())
+			req.Header.Set("x-rh-identity", validIdentityHeader)
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("request_id", requestId)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			handler.ServeHTTP(rr, req)
+			Expect(rr.Code).To(Equal(http.StatusInternalServerError))
+			Expect(rr.Body.String()).ToNot(ContainSubstring("www.example.com"))
 		})
 	})
 
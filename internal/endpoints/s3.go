@@ -0,0 +1,78 @@
+package endpoints
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/config"
+	"github.com/redhatinsights/payload-tracker-go/internal/structs"
+)
+
+// S3Requestor generates presigned archive links straight from the bucket the archives are
+// kept in, without going through storage-broker
+type S3Requestor struct {
+	client      *s3.S3
+	bucket      string
+	keyTemplate string
+	expiry      time.Duration
+}
+
+func NewS3Requestor(cfg config.TrackerConfig) (*S3Requestor, error) {
+	awsCfg := aws.NewConfig().
+		WithRegion(cfg.S3Config.Region).
+		WithS3ForcePathStyle(cfg.S3Config.ForcePathStyle)
+
+	if cfg.S3Config.Endpoint != "" {
+		awsCfg = awsCfg.WithEndpoint(cfg.S3Config.Endpoint)
+	}
+	if cfg.S3Config.AccessKey != "" {
+		awsCfg = awsCfg.WithCredentials(credentials.NewStaticCredentials(cfg.S3Config.AccessKey, cfg.S3Config.SecretKey, ""))
+	}
+
+	sess, err := session.NewSession(awsCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3Requestor{
+		client:      s3.New(sess),
+		bucket:      cfg.S3Config.Bucket,
+		keyTemplate: cfg.S3Config.KeyTemplate,
+		expiry:      time.Duration(cfg.S3Config.URLExpiry) * time.Second,
+	}, nil
+}
+
+// ArchiveLink checks that the archive exists and returns a presigned GET url for it
+func (s *S3Requestor) ArchiveLink(ctx context.Context, reqID string) (*structs.PayloadArchiveLink, error) {
+	key := strings.ReplaceAll(s.keyTemplate, "{request_id}", reqID)
+
+	_, err := s.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if reqErr, ok := err.(awserr.RequestFailure); ok && reqErr.StatusCode() == http.StatusNotFound {
+		return nil, ErrArchiveNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	req, _ := s.client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	url, err := req.Presign(s.expiry)
+	if err != nil {
+		return nil, err
+	}
+
+	return &structs.PayloadArchiveLink{Url: url}, nil
+}
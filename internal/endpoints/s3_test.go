@@ -0,0 +1,76 @@
+package endpoints_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/config"
+	"github.com/redhatinsights/payload-tracker-go/internal/endpoints"
+)
+
+var _ = Describe("S3Requestor", func() {
+	var (
+		requestor *endpoints.S3Requestor
+		objects   map[string]bool
+		server    *httptest.Server
+	)
+
+	BeforeEach(func() {
+		objects = make(map[string]bool)
+
+		// answers HEAD requests like an S3 compatible store with path style addressing
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodHead || !objects[r.URL.Path] {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		cfg := *config.Get()
+		cfg.S3Config = config.S3Cfg{
+			Bucket:         "archives",
+			KeyTemplate:    "payloads/{request_id}.tar.gz",
+			Region:         "us-east-1",
+			Endpoint:       server.URL,
+			AccessKey:      "access",
+			SecretKey:      "secret",
+			ForcePathStyle: true,
+			URLExpiry:      600,
+		}
+
+		var err error
+		requestor, err = endpoints.NewS3Requestor(cfg)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("Returns a presigned url for an existing archive", func() {
+		requestId := getUUID()
+		objects["/archives/payloads/"+requestId+".tar.gz"] = true
+
+		link, err := requestor.ArchiveLink(context.Background(), requestId)
+		Expect(err).ToNot(HaveOccurred())
+
+		u, err := url.Parse(link.Url)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(u.Scheme + "://" + u.Host).To(Equal(server.URL))
+		Expect(u.Path).To(Equal("/archives/payloads/" + requestId + ".tar.gz"))
+		Expect(u.Query().Get("X-Amz-Expires")).To(Equal("600"))
+		Expect(u.Query().Get("X-Amz-Signature")).ToNot(BeEmpty())
+	})
+
+	It("Returns ErrArchiveNotFound for a missing archive", func() {
+		_, err := requestor.ArchiveLink(context.Background(), getUUID())
+		Expect(errors.Is(err, endpoints.ErrArchiveNotFound)).To(BeTrue())
+	})
+})
@@ -0,0 +1,131 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/config"
+	l "github.com/redhatinsights/payload-tracker-go/internal/logging"
+	"github.com/redhatinsights/payload-tracker-go/internal/queries"
+	"github.com/redhatinsights/payload-tracker-go/internal/structs"
+)
+
+var RetrieveSLOTotals = queries.RetrieveSLOTotals
+
+type sloWindow struct {
+	name     string
+	duration time.Duration
+}
+
+// SLOs are the configured SLO definitions and the windows their burn rates are reported for
+type SLOs struct {
+	Definitions []structs.SLODefinition
+	windows     []sloWindow
+	grace       time.Duration
+}
+
+// ParseSLOs validates the SLO definitions and windows from the config
+func ParseSLOs(cfg config.TrackerConfig) (SLOs, error) {
+	slos := SLOs{grace: time.Duration(cfg.SLOConfig.GraceSeconds) * time.Second}
+
+	if err := json.Unmarshal([]byte(cfg.SLOConfig.Definitions), &slos.Definitions); err != nil {
+		return slos, fmt.Errorf("invalid SLO definitions: %v", err)
+	}
+
+	names := make(map[string]bool)
+	for i := range slos.Definitions {
+		d := &slos.Definitions[i]
+		d.Service = strings.ToLower(d.Service)
+		d.Status = strings.ToLower(d.Status)
+		if d.Status == "" {
+			d.Status = "success"
+		}
+
+		switch {
+		case d.Name == "":
+			return slos, fmt.Errorf("SLO %d has no name", i)
+		case names[d.Name]:
+			return slos, fmt.Errorf("SLO %s is defined twice", d.Name)
+		case d.ThresholdSeconds <= 0:
+			return slos, fmt.Errorf("SLO %s needs a positive threshold_seconds", d.Name)
+		case d.Objective <= 0 || d.Objective >= 1:
+			return slos, fmt.Errorf("SLO %s needs an objective between 0 and 1", d.Name)
+		}
+		names[d.Name] = true
+	}
+
+	for _, w := range cfg.SLOConfig.Windows {
+		duration, err := time.ParseDuration(w)
+		if err != nil || duration < time.Minute {
+			return slos, fmt.Errorf("SLO window %s is not a duration of at least a minute", w)
+		}
+		slos.windows = append(slos.windows, sloWindow{name: w, duration: duration.Truncate(time.Minute)})
+	}
+
+	return slos, nil
+}
+
+// Frontier is the end of the evaluated minutes of an SLO. Payloads first seen before it had the
+// threshold and grace period to reach the status.
+func (s SLOs) Frontier(d structs.SLODefinition, now time.Time) time.Time {
+	return now.Add(-time.Duration(d.ThresholdSeconds)*time.Second - s.grace).UTC().Truncate(time.Minute)
+}
+
+// MaxWindow is the longest window burn rates are reported for
+func (s SLOs) MaxWindow() time.Duration {
+	var max time.Duration
+	for _, w := range s.windows {
+		if w.duration > max {
+			max = w.duration
+		}
+	}
+	return max
+}
+
+// Report returns the SLI and burn rate of each SLO over each window ending at its frontier
+func (s SLOs) Report(db *gorm.DB, now time.Time) ([]structs.SLOStatus, error) {
+	report := make([]structs.SLOStatus, 0, len(s.Definitions))
+
+	for _, d := range s.Definitions {
+		to := s.Frontier(d, now)
+		status := structs.SLOStatus{SLODefinition: d}
+
+		for _, w := range s.windows {
+			from := to.Add(-w.duration)
+			total, good, err := RetrieveSLOTotals(db, d.Name, from, to)
+			if err != nil {
+				return nil, err
+			}
+
+			window := structs.SLOWindow{Window: w.name, From: from, To: to, Total: total, Good: good, SLI: 1}
+			if total > 0 {
+				window.SLI = float64(good) / float64(total)
+				window.BurnRate = (1 - window.SLI) / (1 - d.Objective)
+			}
+			status.Windows = append(status.Windows, window)
+		}
+
+		report = append(report, status)
+	}
+
+	return report, nil
+}
+
+// ServiceLevelObjectives returns a handler for GET /slo
+func ServiceLevelObjectives(slos SLOs) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report, err := slos.Report(requestDb(r), time.Now())
+		if err != nil {
+			l.Log.Errorf("Unable to report SLOs: %v", err)
+			writeResponse(w, http.StatusInternalServerError, getErrorBody("Internal Server Issue", http.StatusInternalServerError))
+			return
+		}
+
+		writeJSON(w, http.StatusOK, structs.SLOData{Data: report})
+	}
+}
@@ -0,0 +1,107 @@
+package endpoints_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gorm.io/gorm"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/config"
+	"github.com/redhatinsights/payload-tracker-go/internal/endpoints"
+	"github.com/redhatinsights/payload-tracker-go/internal/structs"
+	"github.com/redhatinsights/payload-tracker-go/internal/utils/test"
+)
+
+var _ = Describe("SLOs", func() {
+	var cfg config.TrackerConfig
+
+	BeforeEach(func() {
+		cfg = *config.Get()
+		cfg.SLOConfig.Definitions = `[{"name": "payload-success", "service": "Puptoo", "threshold_seconds": 600, "objective": 0.99}]`
+		cfg.SLOConfig.Windows = []string{"1h", "6h"}
+		cfg.SLOConfig.GraceSeconds = 120
+	})
+
+	It("Should parse the definitions and default the status to success", func() {
+		slos, err := endpoints.ParseSLOs(cfg)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(slos.Definitions).To(Equal([]structs.SLODefinition{
+			{Name: "payload-success", Service: "puptoo", Status: "success", ThresholdSeconds: 600, Objective: 0.99},
+		}))
+		Expect(slos.MaxWindow()).To(Equal(6 * time.Hour))
+	})
+
+	It("Should reject invalid definitions and windows", func() {
+		for _, definitions := range []string{
+			`{"name": "payload-success"}`,
+			`[{"threshold_seconds": 600, "objective": 0.99}]`,
+			`[{"name": "payload-success", "objective": 0.99}]`,
+			`[{"name": "payload-success", "threshold_seconds": 600, "objective": 99}]`,
+			`[{"name": "a", "threshold_seconds": 600, "objective": 0.9}, {"name": "a", "threshold_seconds": 60, "objective": 0.9}]`,
+		} {
+			cfg.SLOConfig.Definitions = definitions
+			_, err := endpoints.ParseSLOs(cfg)
+			Expect(err).To(HaveOccurred(), definitions)
+		}
+
+		cfg.SLOConfig.Definitions = "[]"
+		cfg.SLOConfig.Windows = []string{"1d"}
+		_, err := endpoints.ParseSLOs(cfg)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should report the SLI and burn rate of each window ending at the frontier", func() {
+		slos, err := endpoints.ParseSLOs(cfg)
+		Expect(err).ToNot(HaveOccurred())
+
+		now, _ := time.Parse(time.RFC3339, "2022-06-07T12:30:30Z")
+		frontier, _ := time.Parse(time.RFC3339, "2022-06-07T12:18:00Z")
+		Expect(slos.Frontier(slos.Definitions[0], now)).To(Equal(frontier))
+
+		var windows [][2]time.Time
+		endpoints.RetrieveSLOTotals = func(_ *gorm.DB, slo string, from time.Time, to time.Time) (int64, int64, error) {
+			windows = append(windows, [2]time.Time{from, to})
+			if to.Sub(from) == time.Hour {
+				return 1000, 980, nil
+			}
+			return 0, 0, nil
+		}
+
+		report, err := slos.Report(nil, now)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(windows).To(Equal([][2]time.Time{{frontier.Add(-time.Hour), frontier}, {frontier.Add(-6 * time.Hour), frontier}}))
+
+		Expect(report[0].Windows[0].SLI).To(BeNumerically("~", 0.98))
+		Expect(report[0].Windows[0].BurnRate).To(BeNumerically("~", 2))
+		// no payloads spend no budget
+		Expect(report[0].Windows[1].SLI).To(Equal(1.0))
+		Expect(report[0].Windows[1].BurnRate).To(Equal(0.0))
+	})
+
+	It("Should serve the report", func() {
+		slos, err := endpoints.ParseSLOs(cfg)
+		Expect(err).ToNot(HaveOccurred())
+
+		endpoints.Db = func() *gorm.DB { return nil }
+		endpoints.RetrieveSLOTotals = func(_ *gorm.DB, slo string, from time.Time, to time.Time) (int64, int64, error) {
+			return 10, 10, nil
+		}
+
+		rr := httptest.NewRecorder()
+		req, err := test.MakeTestRequest("/api/v1/slo", map[string]interface{}{})
+		Expect(err).To(BeNil())
+		endpoints.ServiceLevelObjectives(slos).ServeHTTP(rr, req)
+		Expect(rr.Code).To(Equal(http.StatusOK))
+
+		var respData structs.SLOData
+		Expect(json.Unmarshal(rr.Body.Bytes(), &respData)).To(Succeed())
+		Expect(respData.Data).To(HaveLen(1))
+		Expect(respData.Data[0].Name).To(Equal("payload-success"))
+		Expect(respData.Data[0].Windows).To(HaveLen(2))
+		Expect(respData.Data[0].Windows[0].Total).To(Equal(int64(10)))
+	})
+})
@@ -1,7 +1,24 @@
 package endpoints
 
 import (
+	"encoding/json"
 	"net/http"
+	"strings"
+	"time"
+
+	l "github.com/redhatinsights/payload-tracker-go/internal/logging"
+	"github.com/redhatinsights/payload-tracker-go/internal/queries"
+	"github.com/redhatinsights/payload-tracker-go/internal/structs"
+)
+
+var (
+	RetrieveTimeseries = queries.RetrieveTimeseries
+
+	validBuckets           = []string{"hour", "day", "week"}
+	validTimeseriesGroupBy = []string{"service", "source", "status", "org_id"}
+
+	defaultTimeseriesRange = 7 * 24 * time.Hour
+	maxTimeseriesRange     = 366 * 24 * time.Hour
 )
 
 // StatsRetrieve holds a given stat
@@ -16,3 +33,69 @@ func Stats(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Statuses"))
 }
+
+// StatsTimeseries returns the hourly status rollups for /stats/timeseries, summed into buckets
+func StatsTimeseries(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	q := structs.TimeseriesQuery{
+		Bucket:  "hour",
+		GroupBy: []string{"service", "status"},
+		Service: r.URL.Query().Get("service"),
+		Source:  r.URL.Query().Get("source"),
+		Status:  r.URL.Query().Get("status"),
+		OrgID:   r.URL.Query().Get("org_id"),
+		End:     start.UTC(),
+	}
+
+	if bucket := r.URL.Query().Get("bucket"); bucket != "" {
+		q.Bucket = bucket
+	}
+	if !stringInSlice(q.Bucket, validBuckets) {
+		message := "bucket must be one of " + strings.Join(validBuckets, ", ")
+		writeResponse(w, http.StatusBadRequest, getErrorBody(message, http.StatusBadRequest))
+		return
+	}
+
+	if groupBy := r.URL.Query().Get("group_by"); groupBy != "" {
+		q.GroupBy = strings.Split(groupBy, ",")
+	}
+	for _, column := range q.GroupBy {
+		if !stringInSlice(column, validTimeseriesGroupBy) {
+			message := "group_by must be a comma separated list of " + strings.Join(validTimeseriesGroupBy, ", ")
+			writeResponse(w, http.StatusBadRequest, getErrorBody(message, http.StatusBadRequest))
+			return
+		}
+	}
+
+	var err error
+	if q.End, err = timeParam(r, "date_lt", start, q.End); err != nil {
+		writeResponse(w, http.StatusBadRequest, getErrorBody(err.Error(), http.StatusBadRequest))
+		return
+	}
+	if q.Start, err = timeParam(r, "date_gte", start, q.End.Add(-defaultTimeseriesRange)); err != nil {
+		writeResponse(w, http.StatusBadRequest, getErrorBody(err.Error(), http.StatusBadRequest))
+		return
+	}
+
+	if !q.Start.Before(q.End) || q.End.Sub(q.Start) > maxTimeseriesRange {
+		message := "date_gte must be before date_lt and the range can span at most 366 days"
+		writeResponse(w, http.StatusBadRequest, getErrorBody(message, http.StatusBadRequest))
+		return
+	}
+
+	points := RetrieveTimeseries(requestDb(r), q)
+	duration := time.Since(start).Seconds()
+	observeDBTime(time.Since(start))
+
+	timeseriesData := structs.TimeseriesData{Bucket: q.Bucket, Elapsed: duration, Data: points}
+
+	dataJson, err := json.Marshal(timeseriesData)
+	if err != nil {
+		l.Log.Error(err)
+		writeResponse(w, http.StatusInternalServerError, getErrorBody("Internal Server Issue", http.StatusInternalServerError))
+		return
+	}
+
+	writeResponse(w, http.StatusOK, string(dataJson))
+}
@@ -0,0 +1,103 @@
+package endpoints_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gorm.io/gorm"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/endpoints"
+	"github.com/redhatinsights/payload-tracker-go/internal/structs"
+	"github.com/redhatinsights/payload-tracker-go/internal/utils/test"
+)
+
+var (
+	timeseriesQuery structs.TimeseriesQuery
+	timeseriesData  []structs.TimeseriesPoint
+)
+
+func mockedRetrieveTimeseries(_ *gorm.DB, q structs.TimeseriesQuery) []structs.TimeseriesPoint {
+	timeseriesQuery = q
+	return timeseriesData
+}
+
+var _ = Describe("Stats timeseries", func() {
+	var (
+		handler http.Handler
+		rr      *httptest.ResponseRecorder
+		query   map[string]interface{}
+	)
+
+	BeforeEach(func() {
+		rr = httptest.NewRecorder()
+		handler = http.HandlerFunc(endpoints.StatsTimeseries)
+
+		endpoints.RetrieveTimeseries = mockedRetrieveTimeseries
+		query = make(map[string]interface{})
+	})
+
+	Describe("Get to stats timeseries endpoint", func() {
+		Context("With a valid request", func() {
+			It("should default to hourly buckets over the last week", func() {
+				req, err := test.MakeTestRequest("/api/v1/stats/timeseries", query)
+				Expect(err).To(BeNil())
+				handler.ServeHTTP(rr, req)
+				Expect(rr.Code).To(Equal(200))
+
+				Expect(timeseriesQuery.Bucket).To(Equal("hour"))
+				Expect(timeseriesQuery.GroupBy).To(Equal([]string{"service", "status"}))
+				Expect(timeseriesQuery.End.Sub(timeseriesQuery.Start)).To(Equal(7 * 24 * time.Hour))
+			})
+		})
+
+		Context("With valid data from DB", func() {
+			It("should pass the data forward", func() {
+				query["bucket"] = "day"
+				query["group_by"] = "service"
+				query["date_gte"] = "2022-06-01T00:00:00Z"
+				query["date_lt"] = "2022-06-08T00:00:00Z"
+				req, err := test.MakeTestRequest("/api/v1/stats/timeseries", query)
+				Expect(err).To(BeNil())
+
+				day, _ := time.Parse(time.RFC3339, "2022-06-07T00:00:00Z")
+				timeseriesData = []structs.TimeseriesPoint{{Time: day, Service: "puptoo", Count: 42}}
+
+				handler.ServeHTTP(rr, req)
+				Expect(rr.Code).To(Equal(200))
+
+				var respData structs.TimeseriesData
+
+				readBody, _ := ioutil.ReadAll(rr.Body)
+				json.Unmarshal(readBody, &respData)
+
+				Expect(respData.Bucket).To(Equal("day"))
+				Expect(respData.Data).To(Equal(timeseriesData))
+				Expect(timeseriesQuery.GroupBy).To(Equal([]string{"service"}))
+			})
+		})
+
+		invalidParams := []map[string]interface{}{
+			{"bucket": "minute"},
+			{"group_by": "service,request_id"},
+			{"date_gte": "yesterday"},
+			{"date_gte": "2022-06-08T00:00:00Z", "date_lt": "2022-06-01T00:00:00Z"},
+			{"date_gte": "2020-01-01T00:00:00Z", "date_lt": "2022-01-01T00:00:00Z"},
+		}
+		Context("With invalid query parameters", func() {
+			It("should return HTTP 400", func() {
+				for _, query := range invalidParams {
+					rr = httptest.NewRecorder()
+					req, err := test.MakeTestRequest("/api/v1/stats/timeseries", query)
+					Expect(err).To(BeNil())
+					handler.ServeHTTP(rr, req)
+					Expect(rr.Code).To(Equal(400))
+				}
+			})
+		})
+	})
+})
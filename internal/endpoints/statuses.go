@@ -27,6 +27,21 @@ func Statuses(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// searches are sorted by rank unless asked otherwise
+	q.Search = strings.TrimSpace(r.URL.Query().Get("q"))
+	if len(q.Search) > maxSearchLength {
+		message := fmt.Sprintf("q can be at most %d characters", maxSearchLength)
+		writeResponse(w, http.StatusBadRequest, getErrorBody(message, http.StatusBadRequest))
+		return
+	}
+	if q.Search != "" && r.URL.Query().Get("sort_by") == "" {
+		q.SortBy = "rank"
+	}
+	if q.SortBy == "rank" && q.Search == "" {
+		writeResponse(w, http.StatusBadRequest, getErrorBody("sort_by rank requires q", http.StatusBadRequest))
+		return
+	}
+
 	if !stringInSlice(q.SortBy, validStatusesSortBy) {
 		message := "sort_by must be one of " + strings.Join(validStatusesSortBy, ", ")
 		writeResponse(w, http.StatusBadRequest, getErrorBody(message, http.StatusBadRequest))
@@ -37,15 +52,11 @@ func Statuses(w http.ResponseWriter, r *http.Request) {
 		writeResponse(w, http.StatusBadRequest, getErrorBody(message, http.StatusBadRequest))
 		return
 	}
-	if !validTimestamps(q, true) {
-		message := "invalid timestamp format provided"
-		writeResponse(w, http.StatusBadRequest, getErrorBody(message, http.StatusBadRequest))
-		return
-	}
-	count, payloads := RetrieveStatuses(Db(), q)
+	count, payloads := RetrieveStatuses(requestDb(r), q)
 	duration := time.Since(start).Seconds()
+	observeDBTime(time.Since(start))
 
-	statusesData := structs.StatusesData{count, duration, payloads}
+	statusesData := structs.StatusesData{Count: count, Elapsed: duration, Data: payloads}
 
 	dataJson, err := json.Marshal(statusesData)
 	if err != nil {
@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -111,6 +112,111 @@ var _ = Describe("Statuses", func() {
 			})
 		})
 
+		Context("With filter parameters", func() {
+			It("should pass repeated, comma separated and negated values", func() {
+				var apiQuery structs.Query
+				endpoints.RetrieveStatuses = func(_ *gorm.DB, q structs.Query) (int64, []structs.StatusRetrieve) {
+					apiQuery = q
+					return 0, nil
+				}
+
+				req, err := http.NewRequest("GET", "/api/v1/statuses?service=ingress,puptoo&service=advisor&status=!success&status_msg=a,b&status_msg_contains=timeout", nil)
+				Expect(err).To(BeNil())
+				handler.ServeHTTP(rr, req)
+				Expect(rr.Code).To(Equal(200))
+
+				Expect(apiQuery.Service).To(Equal(structs.Filter{Values: []string{"ingress", "puptoo", "advisor"}}))
+				Expect(apiQuery.Status).To(Equal(structs.Filter{Values: []string{"success"}, Negate: true}))
+				Expect(apiQuery.StatusMsg).To(Equal(structs.Filter{Values: []string{"a,b"}}))
+				Expect(apiQuery.StatusMsgContains).To(Equal(structs.Filter{Values: []string{"timeout"}}))
+				Expect(apiQuery.Source.Values).To(BeEmpty())
+			})
+
+			It("should return HTTP 400 naming the invalid filter", func() {
+				for url, param := range map[string]string{
+					"/api/v1/statuses?service=ingress,!puptoo": "service",
+					"/api/v1/statuses?status=error,,success":   "status",
+					"/api/v1/statuses?source=!":                "source",
+				} {
+					rr = httptest.NewRecorder()
+					req, err := http.NewRequest("GET", url, nil)
+					Expect(err).To(BeNil())
+					handler.ServeHTTP(rr, req)
+					Expect(rr.Code).To(Equal(400))
+					Expect(rr.Body.String()).To(ContainSubstring(param))
+				}
+			})
+		})
+
+		Context("With a search query", func() {
+			It("should sort by rank unless asked otherwise", func() {
+				var apiQuery structs.Query
+				endpoints.RetrieveStatuses = func(_ *gorm.DB, q structs.Query) (int64, []structs.StatusRetrieve) {
+					apiQuery = q
+					return 0, nil
+				}
+
+				req, err := test.MakeTestRequest("/api/v1/statuses", map[string]interface{}{"q": " certificate expired "})
+				Expect(err).To(BeNil())
+				handler.ServeHTTP(rr, req)
+				Expect(rr.Code).To(Equal(200))
+				Expect(apiQuery.Search).To(Equal("certificate expired"))
+				Expect(apiQuery.SortBy).To(Equal("rank"))
+
+				rr = httptest.NewRecorder()
+				req, err = test.MakeTestRequest("/api/v1/statuses", map[string]interface{}{"q": "expired", "sort_by": "date"})
+				Expect(err).To(BeNil())
+				handler.ServeHTTP(rr, req)
+				Expect(rr.Code).To(Equal(200))
+				Expect(apiQuery.SortBy).To(Equal("date"))
+			})
+
+			It("should return HTTP 400 when sorting by rank without a query", func() {
+				query["sort_by"] = "rank"
+				req, err := test.MakeTestRequest("/api/v1/statuses", query)
+				Expect(err).To(BeNil())
+				handler.ServeHTTP(rr, req)
+				Expect(rr.Code).To(Equal(400))
+			})
+		})
+
+		Context("With relative and date only timestamps", func() {
+			It("should normalize them to UTC timestamps", func() {
+				var apiQuery structs.Query
+				endpoints.RetrieveStatuses = func(_ *gorm.DB, q structs.Query) (int64, []structs.StatusRetrieve) {
+					apiQuery = q
+					return 0, nil
+				}
+
+				before := time.Now()
+				req, err := http.NewRequest("GET", "/api/v1/statuses?date_gte=now-7d&date_lt=2022-06-07&created_at_gt=2022-06-07T10:00:00%2B02:00&since=2h", nil)
+				Expect(err).To(BeNil())
+				handler.ServeHTTP(rr, req)
+				Expect(rr.Code).To(Equal(200))
+
+				dateGTE, err := time.Parse(time.RFC3339, apiQuery.DateGTE)
+				Expect(err).To(BeNil())
+				Expect(dateGTE).To(BeTemporally("~", before.AddDate(0, 0, -7), time.Second))
+				Expect(apiQuery.DateLT).To(Equal("2022-06-07T00:00:00Z"))
+				Expect(apiQuery.CreatedAtGT).To(Equal("2022-06-07T08:00:00Z"))
+
+				since, err := time.Parse(time.RFC3339, apiQuery.Since)
+				Expect(err).To(BeNil())
+				Expect(since).To(BeTemporally("~", before.Add(-2*time.Hour), time.Second))
+			})
+
+			It("should return HTTP 400 naming the invalid parameter", func() {
+				for param, value := range map[string]string{"date_lte": "now-7x", "created_at_gte": "06/07/2022", "since": "yesterday"} {
+					rr = httptest.NewRecorder()
+					req, err := test.MakeTestRequest("/api/v1/statuses", map[string]interface{}{param: value})
+					Expect(err).To(BeNil())
+					handler.ServeHTTP(rr, req)
+					Expect(rr.Code).To(Equal(400))
+					Expect(rr.Body.String()).To(ContainSubstring(param + " must be"))
+				}
+			})
+		})
+
 		validTimestamps := map[string]string{
 			"created_at_lt":  "2021-08-04T17:53:29.724476-04:00",
 			"created_at_lte": "2021-08-04T17:53:29.724476-04:00",
@@ -0,0 +1,288 @@
+package endpoints
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/config"
+	l "github.com/redhatinsights/payload-tracker-go/internal/logging"
+	"github.com/redhatinsights/payload-tracker-go/internal/structs"
+)
+
+var (
+	// ErrArchiveNotFound is returned when there is no archive for the request id
+	ErrArchiveNotFound = errors.New("archive not found")
+	// ErrStorageBrokerUnavailable is returned without calling storage-broker while the circuit breaker is open
+	ErrStorageBrokerUnavailable = errors.New("storage-broker is unavailable")
+)
+
+// StorageBrokerStatusError is returned when storage-broker responds with an unexpected status code
+type StorageBrokerStatusError struct {
+	StatusCode int
+}
+
+func (e *StorageBrokerStatusError) Error() string {
+	return fmt.Sprintf("storage-broker responded with %d", e.StatusCode)
+}
+
+// StorageBrokerClient requests archive links from storage-broker. Server errors are retried
+// with exponential backoff, timeouts are not as they already took the whole timeout. Repeated
+// failures open a circuit breaker and links are cached per request id for a short while.
+type StorageBrokerClient struct {
+	url        string
+	client     *http.Client
+	maxRetries int
+	backoff    time.Duration
+	breaker    *circuitBreaker
+
+	cacheTTL  time.Duration
+	cacheSize int
+	cacheLock sync.Mutex
+	cache     map[string]*list.Element
+	// cacheOrder holds the cached links, most recently used first
+	cacheOrder *list.List
+}
+
+type cachedLink struct {
+	reqID   string
+	link    structs.PayloadArchiveLink
+	expires time.Time
+}
+
+func NewStorageBrokerClient(cfg config.TrackerConfig) *StorageBrokerClient {
+	return &StorageBrokerClient{
+		url:        cfg.StorageBrokerURL,
+		client:     &http.Client{Timeout: time.Duration(cfg.StorageBrokerRequestTimeout) * time.Millisecond},
+		maxRetries: cfg.StorageBrokerMaxRetries,
+		backoff:    time.Duration(cfg.StorageBrokerRetryBackoffMs) * time.Millisecond,
+		breaker: newCircuitBreaker(
+			cfg.StorageBrokerBreakerThreshold,
+			time.Duration(cfg.StorageBrokerBreakerCooldownMs)*time.Millisecond,
+		),
+		cacheTTL:   time.Duration(cfg.StorageBrokerLinkCacheSeconds) * time.Second,
+		cacheSize:  cfg.StorageBrokerLinkCacheSize,
+		cache:      make(map[string]*list.Element),
+		cacheOrder: list.New(),
+	}
+}
+
+// ArchiveLink returns the archive link for the request id
+func (c *StorageBrokerClient) ArchiveLink(ctx context.Context, reqID string) (*structs.PayloadArchiveLink, error) {
+	if link, ok := c.cached(reqID, time.Now()); ok {
+		incStorageBrokerLinkCache("hit")
+		return link, nil
+	}
+	incStorageBrokerLinkCache("miss")
+
+	if !c.breaker.allow(time.Now()) {
+		observeStorageBrokerRequest("circuit_open", 0)
+		return nil, ErrStorageBrokerUnavailable
+	}
+
+	var (
+		link   *structs.PayloadArchiveLink
+		err    error
+		failed bool
+	)
+attempts:
+	for attempt := 0; ; attempt++ {
+		link, failed, err = c.request(ctx, reqID)
+		if !failed || isTimeout(err) || attempt >= c.maxRetries {
+			break
+		}
+
+		wait := c.backoff << attempt
+		l.Log.Warnf("Retrying storage-broker request for %s in %s: %v", reqID, wait, err)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			err = ctx.Err()
+			break attempts
+		}
+	}
+
+	// only failures of storage-broker itself count towards opening the breaker,
+	// not the caller giving up
+	if ctx.Err() != nil {
+		c.breaker.abandon()
+	} else {
+		c.breaker.record(!failed, time.Now())
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	c.store(reqID, *link, time.Now())
+	return link, nil
+}
+
+// request calls storage-broker once, failed is true for server errors, failed connections and timeouts
+func (c *StorageBrokerClient) request(ctx context.Context, reqID string) (link *structs.PayloadArchiveLink, failed bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+"?request_id="+url.QueryEscape(reqID), nil)
+	if err != nil {
+		return nil, false, err
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	start := time.Now()
+	response, err := c.client.Do(req)
+	if err != nil {
+		observeStorageBrokerRequest("error", time.Since(start))
+		return nil, ctx.Err() == nil, err
+	}
+	defer response.Body.Close()
+
+	switch {
+	case response.StatusCode == http.StatusNotFound:
+		observeStorageBrokerRequest("not_found", time.Since(start))
+		return nil, false, ErrArchiveNotFound
+	case response.StatusCode >= http.StatusInternalServerError:
+		observeStorageBrokerRequest("server_error", time.Since(start))
+		return nil, true, &StorageBrokerStatusError{StatusCode: response.StatusCode}
+	case response.StatusCode != http.StatusOK:
+		observeStorageBrokerRequest("client_error", time.Since(start))
+		return nil, false, &StorageBrokerStatusError{StatusCode: response.StatusCode}
+	}
+
+	var archiveLink structs.PayloadArchiveLink
+	if err := json.NewDecoder(response.Body).Decode(&archiveLink); err != nil {
+		observeStorageBrokerRequest("invalid_response", time.Since(start))
+		return nil, false, err
+	}
+
+	if archiveLink.Url == "" {
+		observeStorageBrokerRequest("not_found", time.Since(start))
+		return nil, false, ErrArchiveNotFound
+	}
+
+	observeStorageBrokerRequest("success", time.Since(start))
+	return &archiveLink, false, nil
+}
+
+// isTimeout is true when the storage-broker request ran into the client timeout
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func (c *StorageBrokerClient) cached(reqID string, now time.Time) (*structs.PayloadArchiveLink, bool) {
+	c.cacheLock.Lock()
+	defer c.cacheLock.Unlock()
+
+	element, ok := c.cache[reqID]
+	if !ok {
+		return nil, false
+	}
+	cached := element.Value.(*cachedLink)
+	if now.After(cached.expires) {
+		c.cacheOrder.Remove(element)
+		delete(c.cache, reqID)
+		return nil, false
+	}
+
+	c.cacheOrder.MoveToFront(element)
+	link := cached.link
+	return &link, true
+}
+
+// store caches a link, evicting the least recently used ones beyond the cache size
+func (c *StorageBrokerClient) store(reqID string, link structs.PayloadArchiveLink, now time.Time) {
+	if c.cacheTTL <= 0 || c.cacheSize <= 0 {
+		return
+	}
+
+	c.cacheLock.Lock()
+	defer c.cacheLock.Unlock()
+
+	if element, ok := c.cache[reqID]; ok {
+		element.Value = &cachedLink{reqID: reqID, link: link, expires: now.Add(c.cacheTTL)}
+		c.cacheOrder.MoveToFront(element)
+		return
+	}
+
+	c.cache[reqID] = c.cacheOrder.PushFront(&cachedLink{reqID: reqID, link: link, expires: now.Add(c.cacheTTL)})
+	for c.cacheOrder.Len() > c.cacheSize {
+		oldest := c.cacheOrder.Back()
+		c.cacheOrder.Remove(oldest)
+		delete(c.cache, oldest.Value.(*cachedLink).reqID)
+	}
+}
+
+// circuitBreaker opens after threshold consecutive failures and rejects calls until the
+// cooldown has passed. A single trial call is then let through, it closes the breaker
+// again on success. A threshold of 0 disables the breaker.
+type circuitBreaker struct {
+	sync.Mutex
+	threshold int
+	cooldown  time.Duration
+
+	failures int
+	open     bool
+	trial    bool
+	openedAt time.Time
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+func (b *circuitBreaker) allow(now time.Time) bool {
+	b.Lock()
+	defer b.Unlock()
+
+	if !b.open {
+		return true
+	}
+	if b.trial || now.Sub(b.openedAt) < b.cooldown {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+// abandon lets another trial call through when the current one was canceled
+func (b *circuitBreaker) abandon() {
+	b.Lock()
+	b.trial = false
+	b.Unlock()
+}
+
+func (b *circuitBreaker) record(success bool, now time.Time) {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.threshold <= 0 {
+		return
+	}
+
+	wasOpen := b.open
+	b.trial = false
+
+	if success {
+		b.failures = 0
+		b.open = false
+	} else {
+		b.failures++
+		if wasOpen || b.failures >= b.threshold {
+			b.open = true
+			b.openedAt = now
+		}
+	}
+
+	if b.open != wasOpen {
+		l.Log.Warnf("storage-broker circuit breaker open: %v", b.open)
+		setStorageBrokerCircuitOpen(b.open)
+	}
+}
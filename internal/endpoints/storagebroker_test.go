@@ -0,0 +1,159 @@
+package endpoints_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/config"
+	"github.com/redhatinsights/payload-tracker-go/internal/endpoints"
+)
+
+var _ = Describe("StorageBrokerClient", func() {
+	var (
+		cfg       config.TrackerConfig
+		calls     int32
+		responses []int
+		delay     time.Duration
+		server    *httptest.Server
+	)
+
+	BeforeEach(func() {
+		calls = 0
+		responses = nil
+		delay = 0
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			call := int(atomic.AddInt32(&calls, 1)) - 1
+			time.Sleep(delay)
+			status := http.StatusOK
+			if call < len(responses) {
+				status = responses[call]
+			}
+			w.WriteHeader(status)
+			if status == http.StatusOK {
+				w.Write([]byte(`{"url": "www.example.com/` + r.URL.Query().Get("request_id") + `"}`))
+			}
+		}))
+
+		cfg = *config.Get()
+		cfg.StorageBrokerURL = server.URL
+		cfg.StorageBrokerRequestTimeout = 1000
+		cfg.StorageBrokerMaxRetries = 2
+		cfg.StorageBrokerRetryBackoffMs = 1
+		cfg.StorageBrokerBreakerThreshold = 2
+		cfg.StorageBrokerBreakerCooldownMs = 60000
+		cfg.StorageBrokerLinkCacheSeconds = 60
+		cfg.StorageBrokerLinkCacheSize = 100
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("Returns ErrArchiveNotFound without retrying on a 404", func() {
+		responses = []int{http.StatusNotFound}
+		_, err := endpoints.NewStorageBrokerClient(cfg).ArchiveLink(context.Background(), getUUID())
+		Expect(errors.Is(err, endpoints.ErrArchiveNotFound)).To(BeTrue())
+		Expect(calls).To(Equal(int32(1)))
+	})
+
+	It("Returns the status code of other client errors", func() {
+		responses = []int{http.StatusBadRequest}
+		_, err := endpoints.NewStorageBrokerClient(cfg).ArchiveLink(context.Background(), getUUID())
+		var statusErr *endpoints.StorageBrokerStatusError
+		Expect(errors.As(err, &statusErr)).To(BeTrue())
+		Expect(statusErr.StatusCode).To(Equal(http.StatusBadRequest))
+		Expect(calls).To(Equal(int32(1)))
+	})
+
+	It("Retries server errors", func() {
+		responses = []int{http.StatusBadGateway, http.StatusServiceUnavailable}
+		requestId := getUUID()
+		link, err := endpoints.NewStorageBrokerClient(cfg).ArchiveLink(context.Background(), requestId)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(link.Url).To(Equal("www.example.com/" + requestId))
+		Expect(calls).To(Equal(int32(3)))
+	})
+
+	It("Opens the circuit breaker after consecutive failures", func() {
+		responses = []int{500, 500, 500, 500, 500, 500}
+		client := endpoints.NewStorageBrokerClient(cfg)
+
+		for i := 0; i < 2; i++ {
+			_, err := client.ArchiveLink(context.Background(), getUUID())
+			Expect(err).To(HaveOccurred())
+		}
+		Expect(calls).To(Equal(int32(6)))
+
+		_, err := client.ArchiveLink(context.Background(), getUUID())
+		Expect(errors.Is(err, endpoints.ErrStorageBrokerUnavailable)).To(BeTrue())
+		Expect(calls).To(Equal(int32(6)))
+	})
+
+	It("Doesn't retry timeouts but counts them towards the circuit breaker", func() {
+		cfg.StorageBrokerRequestTimeout = 20
+		delay = 200 * time.Millisecond
+		client := endpoints.NewStorageBrokerClient(cfg)
+
+		for i := 0; i < 2; i++ {
+			_, err := client.ArchiveLink(context.Background(), getUUID())
+			Expect(err).To(HaveOccurred())
+		}
+		Expect(calls).To(Equal(int32(2)))
+
+		_, err := client.ArchiveLink(context.Background(), getUUID())
+		Expect(errors.Is(err, endpoints.ErrStorageBrokerUnavailable)).To(BeTrue())
+	})
+
+	It("Caches links per request id", func() {
+		client := endpoints.NewStorageBrokerClient(cfg)
+		requestId := getUUID()
+
+		for i := 0; i < 3; i++ {
+			link, err := client.ArchiveLink(context.Background(), requestId)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(link.Url).To(Equal("www.example.com/" + requestId))
+		}
+		Expect(calls).To(Equal(int32(1)))
+
+		_, err := client.ArchiveLink(context.Background(), getUUID())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(calls).To(Equal(int32(2)))
+	})
+
+	It("Evicts the least recently used links", func() {
+		cfg.StorageBrokerLinkCacheSize = 2
+		client := endpoints.NewStorageBrokerClient(cfg)
+		first, second, third := getUUID(), getUUID(), getUUID()
+
+		for _, requestId := range []string{first, second, first, third} {
+			_, err := client.ArchiveLink(context.Background(), requestId)
+			Expect(err).ToNot(HaveOccurred())
+		}
+		Expect(calls).To(Equal(int32(3)))
+
+		// second was used least recently when third was stored
+		_, err := client.ArchiveLink(context.Background(), first)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(calls).To(Equal(int32(3)))
+
+		_, err = client.ArchiveLink(context.Background(), second)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(calls).To(Equal(int32(4)))
+	})
+
+	It("Stops when the context is canceled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := endpoints.NewStorageBrokerClient(cfg).ArchiveLink(ctx, getUUID())
+		Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+		Expect(calls).To(Equal(int32(0)))
+	})
+})
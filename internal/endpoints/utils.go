@@ -1,13 +1,14 @@
 package endpoints
 
 import (
-	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
-	"io/ioutil"
+	"fmt"
 	"net/http"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -21,37 +22,31 @@ import (
 
 var (
 	validSortBy         = []string{"created_at", "account", "org_id", "system_id", "inventory_id", "service", "source", "status_msg", "date", "request_id", "status"}
-	validAllSortBy      = []string{"account", "org_id", "inventory_id", "system_id", "created_at"}
+	validAllSortBy      = []string{"account", "org_id", "inventory_id", "system_id", "created_at", "last_status_date", "first_seen"}
 	validIDSortBy       = []string{"service", "source", "status_msg", "date", "created_at"}
-	validStatusesSortBy = []string{"service", "source", "request_id", "status", "status_msg", "date", "created_at"}
+	validStatusesSortBy = []string{"service", "source", "request_id", "status", "status_msg", "date", "created_at", "rank"}
 	validSortDir        = []string{"asc", "desc"}
+	validTerminal       = []string{"true", "false"}
+
+	maxFilterValues = 50
+	maxSearchLength = 256
+
+	// timeLayouts are tried in order, the layouts without a zone are read as UTC
+	timeLayouts       = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+	relativeTimeRegex = regexp.MustCompile(`^now(?:\s*([+-]?)\s*(\d{1,6})([smhdw]))?$`)
+	durationRegex     = regexp.MustCompile(`^(\d{1,6})([smhdw])$`)
+	durationUnits     = map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour, "d": 24 * time.Hour, "w": 7 * 24 * time.Hour}
 )
 
 // initQuery intializes the query with default values
 func initQuery(r *http.Request) (structs.Query, error) {
 
 	q := structs.Query{
-		Page:         0,
-		PageSize:     10,
-		SortBy:       "date",
-		SortDir:      "desc",
-		InventoryID:  r.URL.Query().Get("inventory_id"),
-		SystemID:     r.URL.Query().Get("system_id"),
-		CreatedAtLT:  r.URL.Query().Get("created_at_lt"),
-		CreatedAtGT:  r.URL.Query().Get("created_at_gt"),
-		CreatedAtLTE: r.URL.Query().Get("created_at_lte"),
-		CreatedAtGTE: r.URL.Query().Get("created_at_gte"),
-		Account:      r.URL.Query().Get("account"),
-		OrgID:        r.URL.Query().Get("org_id"),
-
-		Service:   r.URL.Query().Get("service"),
-		Source:    r.URL.Query().Get("source"),
-		Status:    r.URL.Query().Get("status"),
-		StatusMsg: r.URL.Query().Get("status_msg"),
-		DateLT:    r.URL.Query().Get("date_lt"),
-		DateLTE:   r.URL.Query().Get("date_lte"),
-		DateGT:    r.URL.Query().Get("date_gt"),
-		DateGTE:   r.URL.Query().Get("date_gte"),
+		Page:     0,
+		PageSize: 10,
+		SortBy:   "date",
+		SortDir:  "desc",
+		Terminal: r.URL.Query().Get("terminal"),
 	}
 
 	var err error
@@ -65,18 +60,183 @@ func initQuery(r *http.Request) (structs.Query, error) {
 	}
 
 	if r.URL.Query().Get("page") != "" {
-		q.Page, err = strconv.Atoi(r.URL.Query().Get("page"))
+		if q.Page, err = strconv.Atoi(r.URL.Query().Get("page")); err != nil {
+			return q, err
+		}
 	}
 
 	if r.URL.Query().Get("page_size") != "" {
-		q.PageSize, err = strconv.Atoi(r.URL.Query().Get("page_size"))
+		if q.PageSize, err = strconv.Atoi(r.URL.Query().Get("page_size")); err != nil {
+			return q, err
+		}
+	}
+
+	// status messages may contain commas, their values are only taken from repeated params
+	filters := []struct {
+		param  string
+		filter *structs.Filter
+		split  bool
+	}{
+		{"account", &q.Account, true},
+		{"org_id", &q.OrgID, true},
+		{"inventory_id", &q.InventoryID, true},
+		{"system_id", &q.SystemID, true},
+		{"service", &q.Service, true},
+		{"source", &q.Source, true},
+		{"status", &q.Status, true},
+		{"fingerprint", &q.Fingerprint, true},
+		{"status_msg", &q.StatusMsg, false},
+		{"status_msg_contains", &q.StatusMsgContains, false},
+	}
+	for _, f := range filters {
+		if *f.filter, err = parseFilter(r, f.param, f.split); err != nil {
+			return q, err
+		}
+	}
+
+	// time filters are normalized to UTC timestamps, relative ones against the same now
+	now := time.Now()
+	timeFilters := []struct {
+		param string
+		value *string
+	}{
+		{"created_at_lt", &q.CreatedAtLT},
+		{"created_at_lte", &q.CreatedAtLTE},
+		{"created_at_gt", &q.CreatedAtGT},
+		{"created_at_gte", &q.CreatedAtGTE},
+		{"date_lt", &q.DateLT},
+		{"date_lte", &q.DateLTE},
+		{"date_gt", &q.DateGT},
+		{"date_gte", &q.DateGTE},
+	}
+	for _, f := range timeFilters {
+		t, err := timeParam(r, f.param, now, time.Time{})
+		if err != nil {
+			return q, err
+		}
+		if !t.IsZero() {
+			*f.value = t.Format(time.RFC3339Nano)
+		}
+	}
+
+	if since := r.URL.Query().Get("since"); since != "" {
+		d, err := parseDuration(since)
+		if err != nil {
+			return q, fmt.Errorf("since must be %v", err)
+		}
+		q.Since = now.Add(-d).UTC().Format(time.RFC3339Nano)
+	}
+
+	return q, nil
+}
+
+// timeParam reads a time param with parseTime, or returns fallback when the param is missing
+func timeParam(r *http.Request, param string, now time.Time, fallback time.Time) (time.Time, error) {
+	value := r.URL.Query().Get(param)
+	if value == "" {
+		return fallback, nil
+	}
+
+	t, err := parseTime(value, now)
+	if err != nil {
+		return t, fmt.Errorf("%s must be an RFC3339 timestamp, a date such as 2006-01-02 or relative to now such as now-7d", param)
+	}
+	return t, nil
+}
+
+// parseTime reads a timestamp, a date or a time relative to now such as now-7d, in UTC. A + in a
+// query string decodes to a space so now 1h is read as now+1h.
+func parseTime(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+
+	if match := relativeTimeRegex.FindStringSubmatch(strings.ToLower(value)); match != nil {
+		if match[2] == "" {
+			return now.UTC(), nil
+		}
+		n, _ := strconv.Atoi(match[2])
+		d := time.Duration(n) * durationUnits[match[3]]
+		if match[1] == "-" {
+			d = -d
+		}
+		return now.Add(d).UTC(), nil
+	}
+
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", value)
+}
+
+// parseDuration reads a duration such as 30m, 2h or 7d
+func parseDuration(value string) (time.Duration, error) {
+	match := durationRegex.FindStringSubmatch(strings.ToLower(strings.TrimSpace(value)))
+	if match == nil {
+		return 0, fmt.Errorf("a duration such as 30m, 2h or 7d")
+	}
+
+	n, _ := strconv.Atoi(match[1])
+	return time.Duration(n) * durationUnits[match[2]], nil
+}
+
+// parseFilter reads the repeated and comma separated values of a filter param. Values of split
+// filters prefixed with ! negate the filter, a filter is either negated or not so they can't be
+// mixed. Status messages are taken as they are, they may start with a ! themselves.
+func parseFilter(r *http.Request, param string, split bool) (structs.Filter, error) {
+	var filter structs.Filter
+
+	for _, raw := range r.URL.Query()[param] {
+		if raw == "" {
+			continue
+		}
+
+		values := []string{raw}
+		if split {
+			values = strings.Split(raw, ",")
+		}
+
+		for _, value := range values {
+			if split {
+				value = strings.TrimSpace(value)
+			}
+			negate := split && strings.HasPrefix(value, "!")
+			if negate {
+				value = strings.TrimPrefix(value, "!")
+			}
+
+			if value == "" {
+				return filter, fmt.Errorf("%s must not contain empty values", param)
+			}
+			if len(filter.Values) > 0 && negate != filter.Negate {
+				return filter, fmt.Errorf("%s cannot mix negated and plain values", param)
+			}
+
+			filter.Negate = negate
+			filter.Values = append(filter.Values, value)
+		}
+	}
+
+	if len(filter.Values) > maxFilterValues {
+		return filter, fmt.Errorf("%s accepts at most %d values", param, maxFilterValues)
 	}
 
-	return q, err
+	return filter, nil
 }
 
+// getDb returns the database API reads are served from, a read replica when one is healthy
 func getDb() *gorm.DB {
-	return db.DB
+	return db.ReadDB()
+}
+
+// requestDb binds the request context to the database session so that queries are
+// canceled together with the request, e.g. when the client disconnects
+func requestDb(r *http.Request) *gorm.DB {
+	db := Db()
+	if db == nil {
+		return nil
+	}
+	return db.WithContext(r.Context())
 }
 
 func getErrorBody(message string, status int) string {
@@ -100,25 +260,6 @@ func stringInSlice(a string, list []string) bool {
 	return false
 }
 
-// Check timestamp format
-func validTimestamps(q structs.Query, all bool) bool {
-	timestampQueries := []string{q.CreatedAtLT, q.CreatedAtGT, q.CreatedAtLTE, q.CreatedAtGTE, q.DateLT, q.DateGT, q.DateLTE, q.DateGTE}
-
-	if !all {
-		timestampQueries = []string{q.CreatedAtLT, q.CreatedAtGT, q.CreatedAtLTE, q.CreatedAtGTE}
-	}
-
-	for _, ts := range timestampQueries {
-		if ts != "" {
-			_, err := time.Parse(time.RFC3339, ts)
-			if err != nil {
-				return false
-			}
-		}
-	}
-	return true
-}
-
 // Check for a specified role in the user's identity header, returns (200, nil) if the role is found
 func checkForRole(r *http.Request, role string) (int, error) {
 	identityHeader := r.Header.Get("x-rh-identity")
@@ -150,10 +291,9 @@ func checkForRole(r *http.Request, role string) (int, error) {
 	}
 
 	if !stringInSlice(role, identityHeaderData.Identity.Associate.Roles) {
-		l.Log.WithFields(logrus.Fields{
+		l.Log.WithFields(identityFields(r)).WithFields(logrus.Fields{
 			"role":              role,
 			"roles_from_header": identityHeaderData.Identity.Associate.Roles,
-			"identity_header":   identityHeader,
 		}).Infof("Unable to find required role")
 		return http.StatusForbidden, errors.New("You do not have the required permissions to access this resource")
 	}
@@ -168,36 +308,6 @@ func writeResponse(w http.ResponseWriter, status int, message string) {
 	w.Write([]byte(message))
 }
 
-// Send a request for an ArchiveLink to storage-broker
-func RequestArchiveLink(baseUrl string, timeout int) func(ctx context.Context, reqID string) (*structs.PayloadArchiveLink, error) {
-
-	return func(ctx context.Context, reqID string) (*structs.PayloadArchiveLink, error) {
-		client := http.Client{
-			Timeout: time.Duration(timeout) * time.Millisecond,
-		}
-
-		response, err := client.Get(baseUrl + "?request_id=" + reqID)
-		if err != nil {
-			return nil, err
-		}
-
-		defer response.Body.Close()
-
-		body, err := ioutil.ReadAll(response.Body)
-		if err != nil {
-			return nil, err
-		}
-
-		var archiveLink structs.PayloadArchiveLink
-		err = json.Unmarshal(body, &archiveLink)
-		if err != nil {
-			return nil, err
-		}
-
-		return &archiveLink, nil
-	}
-}
-
 func isValidUUID(id string) bool {
 	_, err := uuid.Parse(id)
 	return err == nil
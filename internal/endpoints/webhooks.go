@@ -0,0 +1,270 @@
+package endpoints
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/config"
+	l "github.com/redhatinsights/payload-tracker-go/internal/logging"
+	models "github.com/redhatinsights/payload-tracker-go/internal/models/db"
+	"github.com/redhatinsights/payload-tracker-go/internal/queries"
+	"github.com/redhatinsights/payload-tracker-go/internal/structs"
+)
+
+var (
+	RetrieveWebhookSubscriptions = queries.RetrieveWebhookSubscriptions
+	GetWebhookSubscription       = queries.GetWebhookSubscription
+	CreateWebhookSubscription    = queries.CreateWebhookSubscription
+	UpdateWebhookSubscription    = queries.UpdateWebhookSubscription
+	DeleteWebhookSubscription    = queries.DeleteWebhookSubscription
+	RetrieveWebhookDeliveries    = queries.RetrieveWebhookDeliveries
+
+	validWebhookStates = []string{queries.WebhookPending, queries.WebhookDelivered, queries.WebhookFailed}
+)
+
+const maxWebhookBodyBytes = 64 * 1024
+
+// Webhooks returns a response for GET /webhooks
+func Webhooks(w http.ResponseWriter, r *http.Request) {
+	if !checkWebhookRole(w, r) {
+		return
+	}
+
+	subscriptions := RetrieveWebhookSubscriptions(primaryRequestDb(r))
+	for i := range subscriptions {
+		subscriptions[i].Secret = ""
+	}
+
+	writeJSON(w, http.StatusOK, structs.WebhookSubscriptionsData{Count: len(subscriptions), Data: subscriptions})
+}
+
+// CreateWebhook returns a response for POST /webhooks. The secret is generated when none
+// is given and only returned by this request.
+func CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	if !checkWebhookRole(w, r) {
+		return
+	}
+
+	var subscription models.WebhookSubscription
+	if err := applyWebhookRequest(r, &subscription); err != nil {
+		writeResponse(w, http.StatusBadRequest, getErrorBody(err.Error(), http.StatusBadRequest))
+		return
+	}
+
+	if subscription.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			l.Log.Error(err)
+			writeResponse(w, http.StatusInternalServerError, getErrorBody("Internal Server Issue", http.StatusInternalServerError))
+			return
+		}
+		subscription.Secret = hex.EncodeToString(secret)
+	}
+
+	if err := CreateWebhookSubscription(primaryRequestDb(r), &subscription).Error; err != nil {
+		l.Log.Errorf("Unable to create webhook subscription: %v", err)
+		writeResponse(w, http.StatusInternalServerError, getErrorBody("Internal Server Issue", http.StatusInternalServerError))
+		return
+	}
+
+	l.Log.WithFields(identityFields(r)).Infof("Created webhook subscription %d for %s", subscription.Id, subscription.Url)
+	writeJSON(w, http.StatusCreated, subscription)
+}
+
+// GetWebhook returns a response for GET /webhooks/{id}
+func GetWebhook(w http.ResponseWriter, r *http.Request) {
+	if !checkWebhookRole(w, r) {
+		return
+	}
+
+	subscription, ok := getWebhookSubscription(w, r)
+	if !ok {
+		return
+	}
+
+	subscription.Secret = ""
+	writeJSON(w, http.StatusOK, subscription)
+}
+
+// UpdateWebhook returns a response for PUT /webhooks/{id}, the secret is kept when none is given
+func UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	if !checkWebhookRole(w, r) {
+		return
+	}
+
+	subscription, ok := getWebhookSubscription(w, r)
+	if !ok {
+		return
+	}
+
+	if err := applyWebhookRequest(r, &subscription); err != nil {
+		writeResponse(w, http.StatusBadRequest, getErrorBody(err.Error(), http.StatusBadRequest))
+		return
+	}
+
+	if err := UpdateWebhookSubscription(primaryRequestDb(r), &subscription).Error; err != nil {
+		l.Log.Errorf("Unable to update webhook subscription %d: %v", subscription.Id, err)
+		writeResponse(w, http.StatusInternalServerError, getErrorBody("Internal Server Issue", http.StatusInternalServerError))
+		return
+	}
+
+	l.Log.WithFields(identityFields(r)).Infof("Updated webhook subscription %d", subscription.Id)
+	subscription.Secret = ""
+	writeJSON(w, http.StatusOK, subscription)
+}
+
+// DeleteWebhook returns a response for DELETE /webhooks/{id}
+func DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if !checkWebhookRole(w, r) {
+		return
+	}
+
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+
+	found, err := DeleteWebhookSubscription(primaryRequestDb(r), id)
+	if err != nil {
+		l.Log.Errorf("Unable to delete webhook subscription %d: %v", id, err)
+		writeResponse(w, http.StatusInternalServerError, getErrorBody("Internal Server Issue", http.StatusInternalServerError))
+		return
+	}
+	if !found {
+		writeResponse(w, http.StatusNotFound, getErrorBody(fmt.Sprintf("webhook subscription %d not found", id), http.StatusNotFound))
+		return
+	}
+
+	l.Log.WithFields(identityFields(r)).Infof("Deleted webhook subscription %d", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// WebhookDeliveries returns a response for GET /webhooks/{id}/deliveries
+func WebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	if !checkWebhookRole(w, r) {
+		return
+	}
+
+	subscription, ok := getWebhookSubscription(w, r)
+	if !ok {
+		return
+	}
+
+	state := r.URL.Query().Get("state")
+	if state != "" && !stringInSlice(state, validWebhookStates) {
+		message := "state must be one of " + strings.Join(validWebhookStates, ", ")
+		writeResponse(w, http.StatusBadRequest, getErrorBody(message, http.StatusBadRequest))
+		return
+	}
+
+	page, pageSize := 0, 10
+	for param, value := range map[string]*int{"page": &page, "page_size": &pageSize} {
+		if r.URL.Query().Get(param) == "" {
+			continue
+		}
+		var err error
+		*value, err = strconv.Atoi(r.URL.Query().Get(param))
+		if err != nil || *value < 0 {
+			message := param + " must be a non-negative integer"
+			writeResponse(w, http.StatusBadRequest, getErrorBody(message, http.StatusBadRequest))
+			return
+		}
+	}
+
+	count, deliveries := RetrieveWebhookDeliveries(primaryRequestDb(r), subscription.Id, state, page, pageSize)
+	writeJSON(w, http.StatusOK, structs.WebhookDeliveriesData{Count: count, Data: deliveries})
+}
+
+func checkWebhookRole(w http.ResponseWriter, r *http.Request) bool {
+	statusCode, err := checkForRole(r, config.Get().WebhookConfig.Role)
+	if err != nil {
+		writeResponse(w, statusCode, getErrorBody(fmt.Sprintf("%v", err), statusCode))
+		return false
+	}
+	return true
+}
+
+func webhookID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, getErrorBody("id must be a webhook subscription id", http.StatusBadRequest))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func getWebhookSubscription(w http.ResponseWriter, r *http.Request) (models.WebhookSubscription, bool) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return models.WebhookSubscription{}, false
+	}
+
+	subscription, err := GetWebhookSubscription(primaryRequestDb(r), id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		writeResponse(w, http.StatusNotFound, getErrorBody(fmt.Sprintf("webhook subscription %d not found", id), http.StatusNotFound))
+		return subscription, false
+	}
+	if err != nil {
+		l.Log.Errorf("Unable to get webhook subscription %d: %v", id, err)
+		writeResponse(w, http.StatusInternalServerError, getErrorBody("Internal Server Issue", http.StatusInternalServerError))
+		return subscription, false
+	}
+
+	return subscription, true
+}
+
+// applyWebhookRequest validates the request body and copies it onto the subscription
+func applyWebhookRequest(r *http.Request, subscription *models.WebhookSubscription) error {
+	var req structs.WebhookSubscriptionRequest
+	if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxWebhookBodyBytes)).Decode(&req); err != nil {
+		return fmt.Errorf("invalid webhook subscription: %v", err)
+	}
+
+	if req.Name == "" {
+		return errors.New("name is required")
+	}
+	if u, err := url.Parse(req.Url); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http or https url")
+	}
+	if req.StatusMsgPattern != "" {
+		if _, err := regexp.Compile(req.StatusMsgPattern); err != nil {
+			return fmt.Errorf("status_msg_pattern is not a valid regular expression: %v", err)
+		}
+	}
+
+	subscription.Name = req.Name
+	subscription.Url = req.Url
+	// the consumer lowercases service and status before matching
+	subscription.Service = strings.ToLower(req.Service)
+	subscription.Status = strings.ToLower(req.Status)
+	subscription.OrgId = req.OrgID
+	subscription.StatusMsgPattern = req.StatusMsgPattern
+	if req.Secret != "" {
+		subscription.Secret = req.Secret
+	}
+	subscription.Enabled = req.Enabled == nil || *req.Enabled
+
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	dataJson, err := json.Marshal(data)
+	if err != nil {
+		l.Log.Error(err)
+		writeResponse(w, http.StatusInternalServerError, getErrorBody("Internal Server Issue", http.StatusInternalServerError))
+		return
+	}
+
+	writeResponse(w, status, string(dataJson))
+}
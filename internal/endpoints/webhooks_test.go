@@ -0,0 +1,164 @@
+package endpoints_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/go-chi/chi/v5"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gorm.io/gorm"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/endpoints"
+	models "github.com/redhatinsights/payload-tracker-go/internal/models/db"
+	"github.com/redhatinsights/payload-tracker-go/internal/structs"
+)
+
+// an associate with the platform-payload-tracker-webhooks role
+const webhooksIdentityHeader = "eyJpZGVudGl0eSI6IHsiYXNzb2NpYXRlIjogeyJSb2xlIjogWyJwbGF0Zm9ybS1wYXlsb2FkLXRyYWNrZXItd2ViaG9va3MiXSwgInJoYXRVVUlEIjogImExYjIifSwgInR5cGUiOiAiQXNzb2NpYXRlIiwgIm9yZ19pZCI6ICIwMDAwMDEiLCAiaW50ZXJuYWwiOiB7Im9yZ19pZCI6ICIwMDAwMDEifX19"
+
+var _ = Describe("Webhooks", func() {
+	var (
+		rr       *httptest.ResponseRecorder
+		identity string
+		stored   models.WebhookSubscription
+		saved    *models.WebhookSubscription
+	)
+
+	request := func(handler http.HandlerFunc, method string, id string, body string) {
+		req, err := http.NewRequest(method, "/api/v1/webhooks", bytes.NewBufferString(body))
+		Expect(err).To(BeNil())
+		req.Header.Set("x-rh-identity", identity)
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+		handler.ServeHTTP(rr, req)
+	}
+
+	BeforeEach(func() {
+		rr = httptest.NewRecorder()
+		identity = webhooksIdentityHeader
+		stored = models.WebhookSubscription{Id: 3, Name: "errors", Url: "https://example.com/hook", Secret: "stored", Enabled: true}
+		saved = nil
+
+		endpoints.PrimaryDb = func() *gorm.DB { return nil }
+		endpoints.RetrieveWebhookSubscriptions = func(_ *gorm.DB) []models.WebhookSubscription {
+			return []models.WebhookSubscription{stored}
+		}
+		endpoints.GetWebhookSubscription = func(_ *gorm.DB, id uint) (models.WebhookSubscription, error) {
+			if id != stored.Id {
+				return models.WebhookSubscription{}, gorm.ErrRecordNotFound
+			}
+			return stored, nil
+		}
+		endpoints.CreateWebhookSubscription = func(_ *gorm.DB, subscription *models.WebhookSubscription) *gorm.DB {
+			subscription.Id = 4
+			saved = subscription
+			return &gorm.DB{}
+		}
+		endpoints.UpdateWebhookSubscription = func(_ *gorm.DB, subscription *models.WebhookSubscription) *gorm.DB {
+			copied := *subscription
+			saved = &copied
+			return &gorm.DB{}
+		}
+		endpoints.DeleteWebhookSubscription = func(_ *gorm.DB, id uint) (bool, error) {
+			return id == stored.Id, nil
+		}
+	})
+
+	It("Should return 403 without the webhooks role", func() {
+		identity = validIdentityHeader
+		request(endpoints.Webhooks, http.MethodGet, "", "")
+		Expect(rr.Code).To(Equal(http.StatusForbidden))
+	})
+
+	It("Should list subscriptions without their secrets", func() {
+		request(endpoints.Webhooks, http.MethodGet, "", "")
+		Expect(rr.Code).To(Equal(http.StatusOK))
+		Expect(rr.Body.String()).ToNot(ContainSubstring("stored"))
+
+		var respData structs.WebhookSubscriptionsData
+		Expect(json.Unmarshal(rr.Body.Bytes(), &respData)).To(Succeed())
+		Expect(respData.Count).To(Equal(1))
+		Expect(respData.Data[0].Name).To(Equal("errors"))
+	})
+
+	It("Should create a subscription with a generated secret", func() {
+		request(endpoints.CreateWebhook, http.MethodPost, "", `{"name": "errors", "url": "https://example.com/hook", "service": "Puptoo", "status_msg_pattern": "^timeout"}`)
+		Expect(rr.Code).To(Equal(http.StatusCreated))
+
+		Expect(saved.Service).To(Equal("puptoo"))
+		Expect(saved.Enabled).To(BeTrue())
+		Expect(saved.Secret).To(HaveLen(64))
+
+		var respData models.WebhookSubscription
+		Expect(json.Unmarshal(rr.Body.Bytes(), &respData)).To(Succeed())
+		Expect(respData.Id).To(Equal(uint(4)))
+		Expect(respData.Secret).To(Equal(saved.Secret))
+	})
+
+	It("Should reject invalid subscriptions", func() {
+		for _, body := range []string{
+			`{"url": "https://example.com/hook"}`,
+			`{"name": "errors", "url": "/hook"}`,
+			`{"name": "errors", "url": "ftp://example.com/hook"}`,
+			`{"name": "errors", "url": "https://example.com/hook", "status_msg_pattern": "("}`,
+			`{"name": `,
+		} {
+			rr = httptest.NewRecorder()
+			request(endpoints.CreateWebhook, http.MethodPost, "", body)
+			Expect(rr.Code).To(Equal(http.StatusBadRequest), body)
+			Expect(saved).To(BeNil())
+		}
+	})
+
+	It("Should keep the secret when updating without one", func() {
+		request(endpoints.UpdateWebhook, http.MethodPut, "3", `{"name": "errors", "url": "https://example.com/other", "enabled": false}`)
+		Expect(rr.Code).To(Equal(http.StatusOK))
+		Expect(saved.Secret).To(Equal("stored"))
+		Expect(saved.Url).To(Equal("https://example.com/other"))
+		Expect(saved.Enabled).To(BeFalse())
+		Expect(rr.Body.String()).ToNot(ContainSubstring("stored"))
+	})
+
+	It("Should return 404 for unknown subscriptions", func() {
+		request(endpoints.GetWebhook, http.MethodGet, "9", "")
+		Expect(rr.Code).To(Equal(http.StatusNotFound))
+
+		rr = httptest.NewRecorder()
+		request(endpoints.DeleteWebhook, http.MethodDelete, "9", "")
+		Expect(rr.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("Should return 400 for an invalid id", func() {
+		request(endpoints.GetWebhook, http.MethodGet, "errors", "")
+		Expect(rr.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("Should delete subscriptions", func() {
+		request(endpoints.DeleteWebhook, http.MethodDelete, "3", "")
+		Expect(rr.Code).To(Equal(http.StatusNoContent))
+	})
+
+	It("Should return the deliveries of a subscription", func() {
+		var state string
+		endpoints.RetrieveWebhookDeliveries = func(_ *gorm.DB, subscriptionId uint, s string, page int, pageSize int) (int64, []models.WebhookDelivery) {
+			state = s
+			return 1, []models.WebhookDelivery{{Id: 1, SubscriptionId: subscriptionId, State: s}}
+		}
+
+		request(endpoints.WebhookDeliveries, http.MethodGet, "3", "")
+		Expect(rr.Code).To(Equal(http.StatusOK))
+
+		var respData structs.WebhookDeliveriesData
+		Expect(json.Unmarshal(rr.Body.Bytes(), &respData)).To(Succeed())
+		Expect(respData.Count).To(Equal(int64(1)))
+		Expect(respData.Data[0].SubscriptionId).To(Equal(uint(3)))
+		Expect(state).To(Equal(""))
+	})
+})
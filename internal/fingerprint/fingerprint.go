@@ -0,0 +1,50 @@
+package fingerprint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+)
+
+// longer messages are cut before they are normalized, stack traces repeat past it
+const maxMessageLength = 1024
+
+// replacements are applied in order, the earlier ones match text the later ones would split
+var replacements = []struct {
+	pattern     *regexp.Regexp
+	placeholder string
+}{
+	{regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.-]*://\S+`), "<url>"},
+	{regexp.MustCompile(`(?:\b[a-zA-Z]:)?(?:[\\/][\w.@~-]+){2,}[\\/]?`), "<path>"},
+	{regexp.MustCompile(`\b[0-9a-fA-F]{8}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{12}\b`), "<uuid>"},
+	{regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}(?::\d+)?\b`), "<ip>"},
+	{regexp.MustCompile(`\b0[xX][0-9a-fA-F]+\b|\b[0-9a-fA-F]*\d[0-9a-fA-F]*[a-fA-F][0-9a-fA-F]*\b|\b[0-9a-fA-F]*[a-fA-F][0-9a-fA-F]*\d[0-9a-fA-F]*\b`), "<hex>"},
+	{regexp.MustCompile(`\d+`), "<num>"},
+	{regexp.MustCompile(`\s+`), " "},
+}
+
+// Normalize replaces the urls, paths, uuids, ips, hex values and numbers of a status message
+// with placeholders, so that messages differing only in them normalize the same
+func Normalize(msg string) string {
+	if len(msg) > maxMessageLength {
+		msg = strings.ToValidUTF8(msg[:maxMessageLength], "")
+	}
+
+	for _, r := range replacements {
+		msg = r.pattern.ReplaceAllString(msg, r.placeholder)
+	}
+
+	return strings.TrimSpace(msg)
+}
+
+// Fingerprint identifies the normalized status message, it's empty for an empty message
+func Fingerprint(msg string) string {
+	normalized := Normalize(msg)
+	if normalized == "" {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:8])
+}
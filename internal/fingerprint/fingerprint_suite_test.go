@@ -0,0 +1,13 @@
+package fingerprint_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestFingerprint(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Fingerprint Suite")
+}
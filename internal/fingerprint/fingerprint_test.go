@@ -0,0 +1,34 @@
+package fingerprint_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/fingerprint"
+)
+
+var _ = Describe("Fingerprint", func() {
+	It("Should replace the variable parts of a message", func() {
+		for msg, normalized := range map[string]string{
+			"failed to upload 1f3e0a52-7b4c-4d5e-9f10-0a1b2c3d4e5f after 3 attempts":    "failed to upload <uuid> after <num> attempts",
+			"request 3f9c2a1d7b4e4c5d8e9f0a1b2c3d4e5f timed out":                        "request <uuid> timed out",
+			"GET https://console.redhat.com/api/inventory/v1/hosts?page=2 returned 502": "GET <url> returned <num>",
+			"File \"/usr/lib/python3.9/site-packages/app.py\", line 42, in process":     "File \"<path>\", line <num>, in process",
+			"connection to 10.0.12.7:5432 refused":                                      "connection to <ip> refused",
+			"segfault at 0x7ffd4c2a in worker deadbeef01":                               "segfault at <hex> in worker <hex>",
+			"  too   many\n\tspaces  ":                                                  "too many spaces",
+		} {
+			Expect(fingerprint.Normalize(msg)).To(Equal(normalized), msg)
+		}
+	})
+
+	It("Should give messages that only differ in ids the same fingerprint", func() {
+		first := fingerprint.Fingerprint("upload 1f3e0a52-7b4c-4d5e-9f10-0a1b2c3d4e5f failed with code 17")
+		second := fingerprint.Fingerprint("upload 9e8d7c6b-5a4f-4e3d-8c2b-1a0f9e8d7c6b failed with code 42")
+
+		Expect(first).To(HaveLen(16))
+		Expect(first).To(Equal(second))
+		Expect(fingerprint.Fingerprint("upload failed")).ToNot(Equal(first))
+		Expect(fingerprint.Fingerprint(" ")).To(BeEmpty())
+	})
+})
@@ -0,0 +1,73 @@
+package kafka
+
+import (
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	l "github.com/redhatinsights/payload-tracker-go/internal/logging"
+	"github.com/redhatinsights/payload-tracker-go/internal/queries"
+)
+
+// catalogTouchInterval is how far the last seen time of a service, source or status may lag behind
+const catalogTouchInterval = time.Minute
+
+type catalogKey struct {
+	table string
+	name  string
+}
+
+// catalogDates are the first and last status dates recorded for a name
+type catalogDates struct {
+	first time.Time
+	last  time.Time
+}
+
+// catalogTouches throttles the updates of the first and last seen times of the services, sources
+// and statuses, every status would update them otherwise. A status earlier than the first date
+// recorded for its name is always recorded, a later one only once it is an interval past the last.
+type catalogTouches struct {
+	mu       sync.Mutex
+	interval time.Duration
+	touched  map[catalogKey]catalogDates
+}
+
+func newCatalogTouches(interval time.Duration) *catalogTouches {
+	return &catalogTouches{interval: interval, touched: make(map[catalogKey]catalogDates)}
+}
+
+// touch records that the name was reported by a status dated date
+func (c *catalogTouches) touch(db *gorm.DB, table string, name string, date time.Time) {
+	if c == nil || name == "" {
+		return
+	}
+
+	key := catalogKey{table, name}
+	c.mu.Lock()
+	recorded, ok := c.touched[key]
+	if ok && !date.Before(recorded.first) && date.Sub(recorded.last) < c.interval {
+		c.mu.Unlock()
+		return
+	}
+	dates := recorded
+	if !ok || date.Before(dates.first) {
+		dates.first = date
+	}
+	if !ok || date.After(dates.last) {
+		dates.last = date
+	}
+	c.touched[key] = dates
+	c.mu.Unlock()
+
+	if err := queries.TouchCatalogEntry(db, table, name, date).Error; err != nil {
+		l.Log.Error("ERROR Catalog last seen update failed: ", err)
+		c.mu.Lock()
+		if ok {
+			c.touched[key] = recorded
+		} else {
+			delete(c.touched, key)
+		}
+		c.mu.Unlock()
+	}
+}
@@ -0,0 +1,56 @@
+package kafka
+
+import (
+	"bufio"
+	"context"
+	"os"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"gorm.io/gorm"
+
+	config "github.com/redhatinsights/payload-tracker-go/internal/config"
+	"github.com/redhatinsights/payload-tracker-go/internal/endpoints"
+	l "github.com/redhatinsights/payload-tracker-go/internal/logging"
+)
+
+// NewFileEventLoop processes payload status messages from a file instead of a kafka topic.
+// The file holds one JSON message per line. It is meant for local development and demos.
+func NewFileEventLoop(
+	ctx context.Context,
+	cfg *config.TrackerConfig,
+	path string,
+	db *gorm.DB,
+) error {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	handler := newHandler(cfg, db, nil)
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		value := make([]byte, len(line))
+		copy(value, line)
+
+		endpoints.IncConsumedMessages()
+		handler.onMessage(ctx, &kafka.Message{Value: value}, cfg)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	l.Log.Infof("Finished processing messages from %s", path)
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/config"
+	"github.com/redhatinsights/payload-tracker-go/internal/db"
+	"github.com/redhatinsights/payload-tracker-go/internal/queries"
+	"github.com/redhatinsights/payload-tracker-go/internal/structs"
+)
+
+var _ = Describe("File event loop", func() {
+	var (
+		dir string
+		gdb *gorm.DB
+		cfg config.TrackerConfig
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "events")
+		Expect(err).ToNot(HaveOccurred())
+
+		gdb, err = gorm.Open(sqlite.Open("file:"+uuid.New().String()+"?mode=memory&cache=shared"), &gorm.Config{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(db.Migrate(gdb)).To(Succeed())
+
+		cfg = *config.Get()
+		cfg.WebhookConfig.Enabled = false
+	})
+
+	AfterEach(func() {
+		if sqlDB, err := gdb.DB(); err == nil {
+			sqlDB.Close()
+		}
+		os.RemoveAll(dir)
+	})
+
+	It("Stores the statuses of the file in a migrated sqlite database", func() {
+		status := getSimplePayloadStatusMessage()
+		status.RequestID = strings.ReplaceAll(uuid.New().String(), "-", "")
+		status.Account = uuid.New().String()
+		line, err := json.Marshal(status)
+		Expect(err).ToNot(HaveOccurred())
+
+		path := filepath.Join(dir, "events.jsonl")
+		Expect(ioutil.WriteFile(path, append(line, '\n'), 0600)).To(Succeed())
+
+		Expect(NewFileEventLoop(context.Background(), &cfg, path, gdb)).To(Succeed())
+
+		statuses := queries.RetrieveRequestIdPayloads(gdb, status.RequestID, "date", "asc", "0")
+		Expect(statuses).To(HaveLen(1))
+		Expect(statuses[0].Service).To(Equal(status.Service))
+		Expect(statuses[0].Source).To(Equal(status.Source))
+		Expect(statuses[0].Status).To(Equal(status.Status))
+		Expect(statuses[0].StatusMsg).To(Equal(status.StatusMSG))
+
+		count, payloads := queries.RetrievePayloads(gdb, 0, 10, structs.Query{
+			SortBy:  "created_at",
+			SortDir: "desc",
+			Account: structs.Filter{Values: []string{status.Account}},
+		})
+		Expect(count).To(Equal(int64(1)))
+		Expect(payloads[0].RequestId).To(Equal(status.RequestID))
+		Expect(payloads[0].LastStatus).To(Equal(status.Status))
+	})
+})
@@ -7,40 +7,83 @@ import (
 	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
 
 	"github.com/redhatinsights/payload-tracker-go/internal/config"
 	"github.com/redhatinsights/payload-tracker-go/internal/endpoints"
+	"github.com/redhatinsights/payload-tracker-go/internal/fingerprint"
 	l "github.com/redhatinsights/payload-tracker-go/internal/logging"
 	models "github.com/redhatinsights/payload-tracker-go/internal/models/db"
 	"github.com/redhatinsights/payload-tracker-go/internal/models/message"
 	"github.com/redhatinsights/payload-tracker-go/internal/queries"
+	"github.com/redhatinsights/payload-tracker-go/internal/tracing"
+	"github.com/redhatinsights/payload-tracker-go/internal/webhooks"
 )
 
 
 type handler struct {
-	db *gorm.DB
+	db        *gorm.DB
+	webhooks  *webhooks.Matcher
+	lifecycle lifecyclePublisher
+	catalog   *catalogTouches
+}
+
+func newHandler(cfg *config.TrackerConfig, db *gorm.DB, lifecycle *LifecycleProducer) *handler {
+	h := &handler{db: db, catalog: newCatalogTouches(catalogTouchInterval)}
+	if cfg.WebhookConfig.Enabled {
+		h.webhooks = webhooks.NewMatcher(cfg)
+	}
+	if lifecycle != nil {
+		h.lifecycle = lifecycle
+	}
+	return h
 }
 
 // OnMessage takes in each payload status message and processes it
 func (this *handler) onMessage(ctx context.Context, msg *kafka.Message, cfg *config.TrackerConfig) {
 	// Track the time from beginning of handling the message to the insert
 	start := time.Now()
+
+	// continue the producer's trace when the message carries a traceparent header
+	ctx = otel.GetTextMapPropagator().Extract(ctx, headerCarrier{msg})
+	ctx, span := tracing.Tracer().Start(ctx, "process payload status",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(messageAttributes(msg)...),
+	)
+	defer span.End()
+
+	db := this.db.WithContext(ctx)
 	l.Log.Debug("Processing Payload Message ", msg.Value)
 
 	payloadStatus := &message.PayloadStatusMessage{}
 	sanitizedPayloadStatus := &models.PayloadStatuses{}
 
-	if err := json.Unmarshal(msg.Value, payloadStatus); err != nil {
+	_, unmarshalSpan := tracing.Tracer().Start(ctx, "unmarshal")
+	err := json.Unmarshal(msg.Value, payloadStatus)
+	unmarshalSpan.End()
+	if err != nil {
 		// PROBE: Add probe here for error unmarshaling JSON
 		if cfg.DebugConfig.LogStatusJson {
 			l.Log.Error("ERROR: Unmarshaling Payload Status Event: ", err, " Raw Message: ", string(msg.Value))
 		} else {
 			l.Log.Error("ERROR: Unmarshaling Payload Status Event: ", err)
 		}
+		failMessage(span, "unmarshal", err)
 		return
 	}
 
+	endpoints.ObserveMessageAge(payloadStatus.Date.Time, msg.Timestamp, start)
+	span.SetAttributes(
+		attribute.String("payload.request_id", payloadStatus.RequestID),
+		attribute.String("payload.service", payloadStatus.Service),
+		attribute.String("payload.source", payloadStatus.Source),
+		attribute.String("payload.status", payloadStatus.Status),
+	)
+
 	if !validateRequestID(cfg.RequestConfig.ValidateRequestIDLength, payloadStatus.RequestID) {
 		return
 	}
@@ -51,9 +94,10 @@ func (this *handler) onMessage(ctx context.Context, msg *kafka.Message, cfg *con
 	// Upsert into Payloads Table
 	payload := createPayload(payloadStatus)
 
-	upsertResult, payloadId := queries.UpsertPayloadByRequestId(this.db, payloadStatus.RequestID, payload)
+	upsertResult, payloadId := queries.UpsertPayloadByRequestId(db, payloadStatus.RequestID, payload)
 	if upsertResult.Error != nil {
 		l.Log.Error("ERROR Payload table upsert failed: ", upsertResult.Error)
+		failMessage(span, "payload_upsert", upsertResult.Error)
 		return
 	}
 	sanitizedPayloadStatus.PayloadId = payloadId
@@ -63,12 +107,13 @@ func (this *handler) onMessage(ctx context.Context, msg *kafka.Message, cfg *con
 	l.Log.Debug("Adding Status, Sources, and Services to sanitizedPayload")
 
 	// Status & Service: Always defined in the message
-	existingStatus := queries.GetStatusByName(this.db, payloadStatus.Status)
-	existingService := queries.GetServiceByName(this.db, payloadStatus.Service)
+	existingStatus := queries.GetStatusByName(db, payloadStatus.Status)
+	existingService := queries.GetServiceByName(db, payloadStatus.Service)
 	if (models.Statuses{}) == existingStatus {
-		statusResult, newStatus := queries.CreateStatusTableEntry(this.db, payloadStatus.Status)
+		statusResult, newStatus := queries.CreateStatusTableEntry(db, payloadStatus.Status)
 		if statusResult.Error != nil {
 			l.Log.Error("Error Creating Statuses Table Entry ERROR: ", statusResult.Error)
+			failMessage(span, "status_lookup", statusResult.Error)
 			return
 		}
 
@@ -78,9 +123,10 @@ func (this *handler) onMessage(ctx context.Context, msg *kafka.Message, cfg *con
 	}
 
 	if (models.Services{}) == existingService {
-		serviceResult, newService := queries.CreateServiceTableEntry(this.db, payloadStatus.Service)
+		serviceResult, newService := queries.CreateServiceTableEntry(db, payloadStatus.Service)
 		if serviceResult.Error != nil {
 			l.Log.Error("Error Creating Service Table Entry ERROR: ", serviceResult.Error)
+			failMessage(span, "service_lookup", serviceResult.Error)
 			return
 		}
 
@@ -91,11 +137,12 @@ func (this *handler) onMessage(ctx context.Context, msg *kafka.Message, cfg *con
 
 	// Sources
 	if payloadStatus.Source != "" {
-		existingSource := queries.GetSourceByName(this.db, payloadStatus.Source)
+		existingSource := queries.GetSourceByName(db, payloadStatus.Source)
 		if (models.Sources{}) == existingSource {
-			result, newSource := queries.CreateSourceTableEntry(this.db, payloadStatus.Source)
+			result, newSource := queries.CreateSourceTableEntry(db, payloadStatus.Source)
 			if result.Error != nil {
 				l.Log.Error("Error Creating Sources Table Entry ERROR: ", result.Error)
+				failMessage(span, "source_lookup", result.Error)
 				return
 			}
 
@@ -107,6 +154,8 @@ func (this *handler) onMessage(ctx context.Context, msg *kafka.Message, cfg *con
 
 	if payloadStatus.StatusMSG != "" {
 		sanitizedPayloadStatus.StatusMsg = payloadStatus.StatusMSG
+		sanitizedPayloadStatus.Fingerprint = fingerprint.Fingerprint(payloadStatus.StatusMSG)
+		sanitizedPayloadStatus.StatusMsgSearch = models.SearchVector(payloadStatus.StatusMSG)
 	}
 
 	// Insert Date
@@ -114,20 +163,106 @@ func (this *handler) onMessage(ctx context.Context, msg *kafka.Message, cfg *con
 
 	// Insert payload into DB
 	endpoints.ObserveMessageProcessTime(time.Since(start))
-	endpoints.IncMessagesProcessed()
-	result := queries.InsertPayloadStatus(this.db, sanitizedPayloadStatus)
+	endpoints.IncMessagesProcessed(payloadStatus.Service, payloadStatus.Status)
+	result := queries.InsertPayloadStatus(db, sanitizedPayloadStatus)
 	if result.Error != nil {
-		endpoints.IncMessageProcessErrors()
+		endpoints.IncMessageProcessErrors("status_insert")
+		span.AddEvent("retrying status insert", trace.WithAttributes(attribute.String("error", result.Error.Error())))
 		l.Log.Debug("Failed to insert sanitized PayloadStatus with ERROR: ", result.Error)
-		result = queries.InsertPayloadStatus(this.db, sanitizedPayloadStatus)
+		result = queries.InsertPayloadStatus(db, sanitizedPayloadStatus)
 		if result.Error != nil {
+			endpoints.IncMessageProcessErrors("status_insert")
+			span.AddEvent("retrying status insert", trace.WithAttributes(attribute.String("error", result.Error.Error())))
 			l.Log.Debug("Failed to re-insert sanitized PayloadStatus with ERROR: ", result.Error)
-			result = queries.InsertPayloadStatus(this.db, sanitizedPayloadStatus)
+			result = queries.InsertPayloadStatus(db, sanitizedPayloadStatus)
 			if result.Error != nil {
+				failMessage(span, "status_dropped", result.Error)
 				l.Log.Error("Failed final attempt to re-insert PayloadStatus with ERROR: ", result.Error)
+				return
 			}
 		}
 	}
+
+	this.catalog.touch(db, queries.CatalogServices, payloadStatus.Service, sanitizedPayloadStatus.Date)
+	this.catalog.touch(db, queries.CatalogSources, payloadStatus.Source, sanitizedPayloadStatus.Date)
+	this.catalog.touch(db, queries.CatalogStatuses, payloadStatus.Status, sanitizedPayloadStatus.Date)
+
+	if this.webhooks != nil {
+		if err := this.webhooks.Enqueue(db, createWebhookEvent(payloadStatus), time.Now()); err != nil {
+			l.Log.Error("ERROR Queueing webhook deliveries failed: ", err)
+			failMessage(span, "webhook_enqueue", err)
+		}
+	}
+
+	// Keep the latest status summary in sync with the inserted status
+	latest := createLatestStatus(payloadId, payloadStatus)
+	terminates := this.lifecycle != nil && terminatesPayload(db, latest)
+	latestResult := queries.UpsertLatestStatus(db, latest)
+	if latestResult.Error != nil {
+		l.Log.Error("ERROR Payload latest status upsert failed: ", latestResult.Error)
+		failMessage(span, "latest_status", latestResult.Error)
+	} else if terminates {
+		if err := this.publishTerminal(db, payloadStatus); err != nil {
+			l.Log.Error("ERROR Publishing payload lifecycle event failed: ", err)
+			failMessage(span, "lifecycle_publish", err)
+		}
+	}
+	rollupResult := queries.IncrementRollup(db, createRollup(payloadStatus))
+	if rollupResult.Error != nil {
+		l.Log.Error("ERROR Payload status rollup update failed: ", rollupResult.Error)
+		failMessage(span, "rollup", rollupResult.Error)
+	}
+}
+
+// terminatesPayload reports whether the status moves the payload from a non terminal to a terminal status
+func terminatesPayload(db *gorm.DB, latest models.PayloadLatestStatus) bool {
+	if !latest.Terminal {
+		return false
+	}
+
+	previous, found, err := queries.GetLatestStatus(db, latest.PayloadId)
+	if err != nil {
+		l.Log.Error("ERROR Payload latest status lookup failed: ", err)
+		return false
+	}
+
+	return !found || (!previous.Terminal && !latest.Date.Before(previous.Date))
+}
+
+// publishTerminal publishes the terminal lifecycle event of the payload the message ended
+func (this *handler) publishTerminal(db *gorm.DB, msg *message.PayloadStatusMessage) error {
+	statuses := retrieveLifecycleStatuses(db, msg.RequestID)
+	if len(statuses) == 0 {
+		return nil
+	}
+
+	event := newLifecycleMessage(message.LifecycleTerminal, statuses, time.Now())
+	// statuses reported at the same time may sort either way
+	event.Service = msg.Service
+	event.Source = msg.Source
+	event.Status = msg.Status
+	event.StatusMsg = msg.StatusMSG
+
+	return this.lifecycle.Publish(event)
+}
+
+// failMessage counts a processing error and records it on the message span
+func failMessage(span trace.Span, reason string, err error) {
+	endpoints.IncMessageProcessErrors(reason)
+	span.RecordError(err, trace.WithAttributes(attribute.String("reason", reason)))
+	span.SetStatus(codes.Error, reason)
+}
+
+func messageAttributes(msg *kafka.Message) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{attribute.String("messaging.system", "kafka")}
+	if msg.TopicPartition.Topic != nil {
+		attrs = append(attrs,
+			attribute.String("messaging.destination.name", *msg.TopicPartition.Topic),
+			attribute.Int("messaging.kafka.destination.partition", int(msg.TopicPartition.Partition)),
+			attribute.Int64("messaging.kafka.message.offset", int64(msg.TopicPartition.Offset)),
+		)
+	}
+	return attrs
 }
 
 func validateRequestID(requestIDLength int, requestID string) bool {
@@ -163,3 +298,57 @@ func createPayload(msg *message.PayloadStatusMessage) (table models.Payloads) {
 
 	return payloadTable
 }
+
+func createLatestStatus(payloadId uint, msg *message.PayloadStatusMessage) models.PayloadLatestStatus {
+	date := msg.Date.Time.UTC()
+
+	latest := models.PayloadLatestStatus{
+		PayloadId: payloadId,
+		Service:   msg.Service,
+		Source:    msg.Source,
+		Status:    msg.Status,
+		Date:      date,
+		FirstSeen: date,
+		Terminal:  isTerminalStatus(msg.Status),
+	}
+
+	if msg.Status == "error" {
+		latest.ErrorMsg = msg.StatusMSG
+	}
+
+	return latest
+}
+
+func createWebhookEvent(msg *message.PayloadStatusMessage) webhooks.Event {
+	return webhooks.Event{
+		RequestID:   msg.RequestID,
+		Service:     msg.Service,
+		Source:      msg.Source,
+		Status:      msg.Status,
+		StatusMsg:   msg.StatusMSG,
+		OrgID:       msg.OrgID,
+		Account:     msg.Account,
+		InventoryID: msg.InventoryID,
+		SystemID:    msg.SystemID,
+		Date:        msg.Date.Time.UTC(),
+	}
+}
+
+func createRollup(msg *message.PayloadStatusMessage) models.PayloadStatusRollup {
+	return models.PayloadStatusRollup{
+		Hour:    msg.Date.Time.UTC().Truncate(time.Hour),
+		Service: msg.Service,
+		Source:  msg.Source,
+		Status:  msg.Status,
+		OrgId:   msg.OrgID,
+	}
+}
+
+func isTerminalStatus(status string) bool {
+	for _, terminal := range queries.TerminalStatuses {
+		if status == terminal {
+			return true
+		}
+	}
+	return false
+}
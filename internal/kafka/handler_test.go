@@ -3,6 +3,7 @@ package kafka
 import (
 	"context"
 	"encoding/json"
+	"strings"
 	"time"
 
 	k "github.com/confluentinc/confluent-kafka-go/kafka"
@@ -11,6 +12,7 @@ import (
 	. "github.com/onsi/gomega"
 
 	"github.com/redhatinsights/payload-tracker-go/internal/config"
+	"github.com/redhatinsights/payload-tracker-go/internal/fingerprint"
 	"github.com/redhatinsights/payload-tracker-go/internal/models/message"
 	"github.com/redhatinsights/payload-tracker-go/internal/queries"
 	"github.com/redhatinsights/payload-tracker-go/internal/utils/test"
@@ -76,8 +78,42 @@ var _ = Describe("Kafka message handler", func() {
 			Expect(dbResult[0].SystemID).To(Equal(payloadMsgVal.SystemID))
 			Expect(dbResult[0].Status).To(Equal(payloadMsgVal.Status))
 			Expect(dbResult[0].StatusMsg).To(Equal(payloadMsgVal.StatusMSG))
+			Expect(dbResult[0].Fingerprint).To(Equal(fingerprint.Fingerprint(payloadMsgVal.StatusMSG)))
 			Expect(dbResult[0].Source).To(Equal(payloadMsgVal.Source))
 		})
+
+		It("Records when the service was first and last seen", func() {
+			msgHandler.catalog = newCatalogTouches(time.Hour)
+
+			payloadMsgVal := getSimplePayloadStatusMessage()
+			payloadMsgVal.Service = "catalog-" + uuid.New().String()[:8]
+
+			msgHandler.onMessage(context.Background(), newKafkaMessage(payloadMsgVal), config.Get())
+
+			// the status date, not when it was consumed
+			service := queries.GetServiceByName(db(), payloadMsgVal.Service)
+			Expect(*service.FirstSeen).To(BeTemporally("==", payloadMsgVal.Date.Time))
+			Expect(*service.LastSeen).To(BeTemporally("==", payloadMsgVal.Date.Time))
+
+			first := payloadMsgVal.Date.Time
+			report := func(date time.Time) {
+				payloadMsgVal.RequestID = strings.ReplaceAll(uuid.New().String(), "-", "")
+				payloadMsgVal.Date = message.FormatedTime{Time: date}
+				msgHandler.onMessage(context.Background(), newKafkaMessage(payloadMsgVal), config.Get())
+				service = queries.GetServiceByName(db(), payloadMsgVal.Service)
+			}
+
+			// later statuses move the last seen time at most once per interval
+			report(first.Add(time.Minute))
+			Expect(*service.LastSeen).To(BeTemporally("==", first))
+			report(first.Add(2 * time.Hour))
+			Expect(*service.LastSeen).To(BeTemporally("==", first.Add(2*time.Hour)))
+
+			// an earlier status always moves the first seen time back
+			report(first.Add(-time.Minute))
+			Expect(*service.FirstSeen).To(BeTemporally("==", first.Add(-time.Minute)))
+			Expect(*service.LastSeen).To(BeTemporally("==", first.Add(2*time.Hour)))
+		})
 	})
 
 	Describe("On valid request ID", func() {
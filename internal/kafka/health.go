@@ -0,0 +1,198 @@
+package kafka
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+
+	config "github.com/redhatinsights/payload-tracker-go/internal/config"
+	"github.com/redhatinsights/payload-tracker-go/internal/endpoints"
+	l "github.com/redhatinsights/payload-tracker-go/internal/logging"
+)
+
+// statistics is the part of the librdkafka statistics event the consumer health uses,
+// see https://github.com/confluentinc/librdkafka/blob/master/STATISTICS.md
+type statistics struct {
+	Brokers map[string]struct {
+		State  string `json:"state"`
+		NodeId int32  `json:"nodeid"`
+	} `json:"brokers"`
+	Topics map[string]struct {
+		Partitions map[string]struct {
+			Partition   int32 `json:"partition"`
+			ConsumerLag int64 `json:"consumer_lag"`
+		} `json:"partitions"`
+	} `json:"topics"`
+}
+
+type partition struct {
+	topic string
+	id    int32
+}
+
+func newPartition(tp kafka.TopicPartition) partition {
+	p := partition{id: tp.Partition}
+	if tp.Topic != nil {
+		p.topic = *tp.Topic
+	}
+	return p
+}
+
+// ConsumerHealth tracks the partitions assigned to the consumer, the last poll and the lag
+// of each partition so that readiness can fail when the consumer can't keep up
+type ConsumerHealth struct {
+	sync.RWMutex
+
+	maxLag       int64
+	staleAfter   time.Duration
+	statsEnabled bool
+
+	assigned  map[partition]bool
+	lag       map[partition]int64
+	brokersUp int
+	lastPoll  time.Time
+	lastStats time.Time
+}
+
+// NewConsumerHealth considers polls and statistics stale after three statistics intervals.
+// Without statistics only the last poll is checked.
+func NewConsumerHealth(cfg *config.TrackerConfig) *ConsumerHealth {
+	h := &ConsumerHealth{
+		maxLag:       cfg.KafkaConfig.KafkaMaxLag,
+		staleAfter:   3 * time.Duration(cfg.KafkaConfig.KafkaStatsInterval) * time.Millisecond,
+		statsEnabled: cfg.KafkaConfig.KafkaStatsInterval > 0,
+		assigned:     make(map[partition]bool),
+		lag:          make(map[partition]int64),
+	}
+	if !h.statsEnabled {
+		h.staleAfter = 30 * time.Second
+	}
+	return h
+}
+
+// onRebalance records partition assignments, librdkafka still assigns them itself
+func (h *ConsumerHealth) onRebalance(c *kafka.Consumer, event kafka.Event) error {
+	h.Lock()
+	defer h.Unlock()
+
+	switch e := event.(type) {
+	case kafka.AssignedPartitions:
+		l.Log.Infof("Assigned partitions: %v", e.Partitions)
+		for _, tp := range e.Partitions {
+			h.assigned[newPartition(tp)] = true
+		}
+	case kafka.RevokedPartitions:
+		l.Log.Infof("Revoked partitions: %v", e.Partitions)
+		for _, tp := range e.Partitions {
+			p := newPartition(tp)
+			delete(h.assigned, p)
+			delete(h.lag, p)
+			endpoints.DeleteConsumerLag(p.topic, p.id)
+		}
+	}
+
+	endpoints.SetConsumerAssignedPartitions(len(h.assigned))
+
+	return nil
+}
+
+func (h *ConsumerHealth) onPoll(now time.Time) {
+	h.Lock()
+	h.lastPoll = now
+	h.Unlock()
+
+	endpoints.SetConsumerLastPoll(now)
+}
+
+func (h *ConsumerHealth) onAllBrokersDown() {
+	h.Lock()
+	h.brokersUp = 0
+	h.Unlock()
+
+	endpoints.SetKafkaBrokersUp(0)
+}
+
+func (h *ConsumerHealth) onStats(statsJSON string, now time.Time) error {
+	var stats statistics
+	if err := json.Unmarshal([]byte(statsJSON), &stats); err != nil {
+		return err
+	}
+
+	h.Lock()
+	defer h.Unlock()
+
+	h.lastStats = now
+
+	// bootstrap brokers show up with nodeid -1 next to the real ones
+	h.brokersUp = 0
+	for _, broker := range stats.Brokers {
+		if broker.NodeId >= 0 && broker.State == "UP" {
+			h.brokersUp++
+		}
+	}
+	endpoints.SetKafkaBrokersUp(h.brokersUp)
+
+	for name, topic := range stats.Topics {
+		for _, stat := range topic.Partitions {
+			p := partition{topic: name, id: stat.Partition}
+
+			// skips the internal -1 partition, lag is -1 until the offsets are known
+			if !h.assigned[p] || stat.ConsumerLag < 0 {
+				continue
+			}
+
+			h.lag[p] = stat.ConsumerLag
+			endpoints.SetConsumerLag(p.topic, p.id, stat.ConsumerLag)
+		}
+	}
+
+	return nil
+}
+
+// Check returns an error when kafka is unreachable, the event loop stopped polling or
+// an assigned partition is lagging more than the configured maximum
+func (h *ConsumerHealth) Check() error {
+	h.RLock()
+	defer h.RUnlock()
+
+	if err := h.checkPoll(); err != nil {
+		return err
+	}
+	if !h.statsEnabled {
+		return nil
+	}
+	if time.Since(h.lastStats) > h.staleAfter {
+		return fmt.Errorf("no kafka statistics received in the last %s", h.staleAfter)
+	}
+	if h.brokersUp == 0 {
+		return fmt.Errorf("no kafka brokers are reachable")
+	}
+
+	if h.maxLag > 0 {
+		for p, lag := range h.lag {
+			if lag > h.maxLag {
+				return fmt.Errorf("partition %s[%d] is lagging %d messages behind, more than %d", p.topic, p.id, lag, h.maxLag)
+			}
+		}
+	}
+
+	return nil
+}
+
+// CheckPoll returns an error when the event loop stopped polling kafka
+func (h *ConsumerHealth) CheckPoll() error {
+	h.RLock()
+	defer h.RUnlock()
+
+	return h.checkPoll()
+}
+
+func (h *ConsumerHealth) checkPoll() error {
+	if time.Since(h.lastPoll) > h.staleAfter {
+		return fmt.Errorf("kafka has not been polled in the last %s", h.staleAfter)
+	}
+	return nil
+}
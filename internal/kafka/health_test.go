@@ -0,0 +1,77 @@
+package kafka
+
+import (
+	"fmt"
+	"time"
+
+	k "github.com/confluentinc/confluent-kafka-go/kafka"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/config"
+)
+
+const statsJSON = `{
+	"brokers": {
+		"localhost:29092/bootstrap": {"state": "UP", "nodeid": -1},
+		"kafka:29092/1": {"state": "%s", "nodeid": 1}
+	},
+	"topics": {
+		"platform.payload-status": {
+			"partitions": {
+				"0": {"partition": 0, "consumer_lag": %d},
+				"1": {"partition": 1, "consumer_lag": 500000},
+				"-1": {"partition": -1, "consumer_lag": -1}
+			}
+		}
+	}
+}`
+
+var _ = Describe("Consumer health", func() {
+	var (
+		health *ConsumerHealth
+		topic  = "platform.payload-status"
+	)
+
+	BeforeEach(func() {
+		cfg := config.Get()
+		cfg.KafkaConfig.KafkaStatsInterval = 1000
+		cfg.KafkaConfig.KafkaMaxLag = 1000
+
+		health = NewConsumerHealth(cfg)
+		health.onRebalance(nil, k.AssignedPartitions{Partitions: []k.TopicPartition{{Topic: &topic, Partition: 0}}})
+		health.onPoll(time.Now())
+	})
+
+	It("is healthy when the assigned partitions are caught up", func() {
+		Expect(health.onStats(fmt.Sprintf(statsJSON, "UP", 10), time.Now())).To(Succeed())
+		Expect(health.Check()).To(Succeed())
+		Expect(health.lag).To(HaveLen(1))
+	})
+
+	It("fails when an assigned partition lags too far behind", func() {
+		Expect(health.onStats(fmt.Sprintf(statsJSON, "UP", 5000), time.Now())).To(Succeed())
+		Expect(health.Check()).To(MatchError(ContainSubstring("partition platform.payload-status[0]")))
+	})
+
+	It("fails when no broker is up", func() {
+		Expect(health.onStats(fmt.Sprintf(statsJSON, "DOWN", 10), time.Now())).To(Succeed())
+		Expect(health.Check()).To(MatchError("no kafka brokers are reachable"))
+	})
+
+	It("fails when statistics or polls are stale", func() {
+		Expect(health.onStats(fmt.Sprintf(statsJSON, "UP", 10), time.Now().Add(-time.Minute))).To(Succeed())
+		Expect(health.Check()).To(HaveOccurred())
+
+		Expect(health.onStats(fmt.Sprintf(statsJSON, "UP", 10), time.Now())).To(Succeed())
+		health.onPoll(time.Now().Add(-time.Minute))
+		Expect(health.Check()).To(MatchError(ContainSubstring("has not been polled")))
+	})
+
+	It("forgets the lag of revoked partitions", func() {
+		Expect(health.onStats(fmt.Sprintf(statsJSON, "UP", 5000), time.Now())).To(Succeed())
+		health.onRebalance(nil, k.RevokedPartitions{Partitions: []k.TopicPartition{{Topic: &topic, Partition: 0}}})
+
+		Expect(health.Check()).To(Succeed())
+	})
+})
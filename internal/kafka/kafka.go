@@ -5,6 +5,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 	"gorm.io/gorm"
@@ -15,7 +16,7 @@ import (
 )
 
 // NewConsumer Creates brand new consumer instance based on topic
-func NewConsumer(ctx context.Context, config *config.TrackerConfig, topic string) (*kafka.Consumer, error) {
+func NewConsumer(ctx context.Context, config *config.TrackerConfig, topic string, health *ConsumerHealth) (*kafka.Consumer, error) {
 	var configMap kafka.ConfigMap
 
 	if config.KafkaConfig.SASLMechanism != "" {
@@ -29,6 +30,7 @@ func NewConsumer(ctx context.Context, config *config.TrackerConfig, topic string
 			"sasl.password":            config.KafkaConfig.KafkaPassword,
 			"go.logs.channel.enable":   true,
 			"allow.auto.create.topics": true,
+			"statistics.interval.ms":   config.KafkaConfig.KafkaStatsInterval,
 		}
 	} else {
 		configMap = kafka.ConfigMap{
@@ -38,6 +40,7 @@ func NewConsumer(ctx context.Context, config *config.TrackerConfig, topic string
 			"auto.commit.interval.ms":  config.KafkaConfig.KafkaAutoCommitInterval,
 			"go.logs.channel.enable":   true,
 			"allow.auto.create.topics": true,
+			"statistics.interval.ms":   config.KafkaConfig.KafkaStatsInterval,
 		}
 	}
 
@@ -47,7 +50,7 @@ func NewConsumer(ctx context.Context, config *config.TrackerConfig, topic string
 		return nil, err
 	}
 
-	err = consumer.SubscribeTopics([]string{topic}, nil)
+	err = consumer.SubscribeTopics([]string{topic}, health.onRebalance)
 
 	if err != nil {
 		return nil, err
@@ -64,14 +67,14 @@ func NewConsumerEventLoop(
 	cfg *config.TrackerConfig,
 	consumer *kafka.Consumer,
 	db *gorm.DB,
+	health *ConsumerHealth,
+	lifecycle *LifecycleProducer,
 ) {
 
 	sigchan := make(chan os.Signal, 1)
 	signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM)
 
-	handler := &handler{
-		db: db,
-	}
+	handler := newHandler(cfg, db, lifecycle)
 
 	run := true
 
@@ -83,6 +86,9 @@ func NewConsumerEventLoop(
 		default:
 
 			event := consumer.Poll(100)
+			if _, failed := event.(kafka.Error); !failed {
+				health.onPoll(time.Now())
+			}
 			if event == nil {
 				continue
 			}
@@ -91,8 +97,15 @@ func NewConsumerEventLoop(
 			case *kafka.Message:
 				endpoints.IncConsumedMessages()
 				handler.onMessage(ctx, e, cfg)
+			case *kafka.Stats:
+				if err := health.onStats(e.String(), time.Now()); err != nil {
+					l.Log.Errorf("Unable to parse kafka statistics: %v", err)
+				}
 			case kafka.Error:
 				endpoints.IncConsumeErrors()
+				if e.Code() == kafka.ErrAllBrokersDown {
+					health.onAllBrokersDown()
+				}
 				l.Log.Errorf("Consumer error: %v (%v)\n", e.Code(), e)
 			default:
 				l.Log.Infof("Ignored %v\n", e)
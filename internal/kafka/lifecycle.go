@@ -0,0 +1,195 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"gorm.io/gorm"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/config"
+	"github.com/redhatinsights/payload-tracker-go/internal/endpoints"
+	l "github.com/redhatinsights/payload-tracker-go/internal/logging"
+	"github.com/redhatinsights/payload-tracker-go/internal/models/message"
+	"github.com/redhatinsights/payload-tracker-go/internal/queries"
+	"github.com/redhatinsights/payload-tracker-go/internal/structs"
+)
+
+type lifecyclePublisher interface {
+	Publish(event message.PayloadLifecycleMessage) error
+}
+
+// LifecycleProducer publishes payload lifecycle events keyed by request id
+type LifecycleProducer struct {
+	producer *kafka.Producer
+	topic    string
+}
+
+func NewLifecycleProducer(cfg *config.TrackerConfig) (*LifecycleProducer, error) {
+	configMap := kafka.ConfigMap{
+		"bootstrap.servers":        cfg.KafkaConfig.KafkaBootstrapServers,
+		"request.required.acks":    cfg.KafkaConfig.KafkaRequestRequiredAcks,
+		"message.send.max.retries": cfg.KafkaConfig.KafkaMessageSendMaxRetries,
+		"retry.backoff.ms":         cfg.KafkaConfig.KafkaRetryBackoffMs,
+	}
+
+	if cfg.KafkaConfig.SASLMechanism != "" {
+		configMap["security.protocol"] = cfg.KafkaConfig.Protocol
+		configMap["sasl.mechanism"] = cfg.KafkaConfig.SASLMechanism
+		configMap["ssl.ca.location"] = cfg.KafkaConfig.KafkaCA
+		configMap["sasl.username"] = cfg.KafkaConfig.KafkaUsername
+		configMap["sasl.password"] = cfg.KafkaConfig.KafkaPassword
+	}
+
+	producer, err := kafka.NewProducer(&configMap)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &LifecycleProducer{producer: producer, topic: cfg.KafkaConfig.KafkaLifecycleTopic}
+	go p.deliveryReports()
+
+	return p, nil
+}
+
+// Publish queues the event, the outcome is counted once kafka acknowledged it
+func (p *LifecycleProducer) Publish(event message.PayloadLifecycleMessage) error {
+	value, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return p.producer.Produce(&kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &p.topic, Partition: kafka.PartitionAny},
+		Key:            []byte(event.RequestID),
+		Value:          value,
+		Opaque:         event.Event,
+	}, nil)
+}
+
+// Close waits up to ten seconds for queued events to be delivered
+func (p *LifecycleProducer) Close() {
+	if remaining := p.producer.Flush(10000); remaining > 0 {
+		l.Log.Warnf("Closing the lifecycle producer with %d undelivered events", remaining)
+	}
+	p.producer.Close()
+}
+
+func (p *LifecycleProducer) deliveryReports() {
+	for e := range p.producer.Events() {
+		msg, ok := e.(*kafka.Message)
+		if !ok {
+			continue
+		}
+
+		event, _ := msg.Opaque.(string)
+		if msg.TopicPartition.Error != nil {
+			endpoints.IncLifecycleEvents(event, "failed")
+			l.Log.Errorf("Unable to publish %s lifecycle event for %s: %v", event, msg.Key, msg.TopicPartition.Error)
+			continue
+		}
+		endpoints.IncLifecycleEvents(event, "delivered")
+	}
+}
+
+// newLifecycleMessage summarizes the statuses of a payload, sorted by date, as a lifecycle event
+func newLifecycleMessage(event string, statuses []structs.SinglePayloadData, now time.Time) message.PayloadLifecycleMessage {
+	first, last := statuses[0], statuses[len(statuses)-1]
+
+	return message.PayloadLifecycleMessage{
+		Event:       event,
+		RequestID:   last.RequestID,
+		Account:     last.Account,
+		OrgID:       last.OrgID,
+		InventoryID: last.InventoryID,
+		SystemID:    last.SystemID,
+		Service:     last.Service,
+		Source:      last.Source,
+		Status:      last.Status,
+		StatusMsg:   last.StatusMsg,
+		FirstSeen:   first.Date.UTC(),
+		LastSeen:    last.Date.UTC(),
+		Durations:   queries.CalculateDurations(statuses),
+		Date:        now.UTC(),
+	}
+}
+
+// retrieveLifecycleStatuses returns all statuses of a payload, oldest first
+func retrieveLifecycleStatuses(db *gorm.DB, requestID string) []structs.SinglePayloadData {
+	return queries.RetrieveRequestIdPayloads(db, requestID, "date", "asc", "0")
+}
+
+// StuckDetector declares payloads stuck when their latest status is not terminal and no new
+// status arrived for a while. Each payload is declared stuck once, until it moves again.
+type StuckDetector struct {
+	db         *gorm.DB
+	publisher  lifecyclePublisher
+	interval   time.Duration
+	stuckAfter time.Duration
+	lookback   time.Duration
+	batchSize  int
+}
+
+func NewStuckDetector(cfg *config.TrackerConfig, db *gorm.DB, publisher *LifecycleProducer) *StuckDetector {
+	return &StuckDetector{
+		db:         db,
+		publisher:  publisher,
+		interval:   time.Duration(cfg.LifecycleConfig.StuckCheckIntervalSeconds) * time.Second,
+		stuckAfter: time.Duration(cfg.LifecycleConfig.StuckAfterMinutes) * time.Minute,
+		lookback:   time.Duration(cfg.LifecycleConfig.StuckLookbackHours) * time.Hour,
+		batchSize:  cfg.LifecycleConfig.StuckBatchSize,
+	}
+}
+
+// Run checks for stuck payloads every interval until the context is done
+func (d *StuckDetector) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// keep going while full batches are found
+			for d.batchSize > 0 && d.Check(ctx, time.Now()) == d.batchSize && ctx.Err() == nil {
+			}
+		}
+	}
+}
+
+// Check publishes a stuck event for a batch of stuck payloads and returns how many were found
+func (d *StuckDetector) Check(ctx context.Context, now time.Time) int {
+	db := d.db.WithContext(ctx)
+	before := now.Add(-d.stuckAfter)
+
+	payloads, err := queries.StuckPayloads(db, before.Add(-d.lookback), before, d.batchSize)
+	if err != nil {
+		l.Log.Errorf("Unable to load stuck payloads: %v", err)
+		return 0
+	}
+
+	for _, payload := range payloads {
+		claimed, err := queries.ClaimStuckPayload(db, payload.Id, before, now)
+		if err != nil {
+			l.Log.Errorf("Unable to declare payload %s stuck: %v", payload.RequestId, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		statuses := retrieveLifecycleStatuses(db, payload.RequestId)
+		if len(statuses) == 0 {
+			continue
+		}
+
+		// the payload stays declared stuck, a failed event is not retried
+		if err := d.publisher.Publish(newLifecycleMessage(message.LifecycleStuck, statuses, now)); err != nil {
+			l.Log.Errorf("Unable to publish stuck lifecycle event for %s: %v", payload.RequestId, err)
+		}
+	}
+
+	return len(payloads)
+}
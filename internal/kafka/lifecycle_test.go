@@ -0,0 +1,124 @@
+package kafka
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/config"
+	"github.com/redhatinsights/payload-tracker-go/internal/models/message"
+	"github.com/redhatinsights/payload-tracker-go/internal/utils/test"
+)
+
+type fakePublisher struct {
+	events []message.PayloadLifecycleMessage
+}
+
+func (f *fakePublisher) Publish(event message.PayloadLifecycleMessage) error {
+	f.events = append(f.events, event)
+	return nil
+}
+
+// eventsFor filters out the events of payloads left behind by other tests
+func (f *fakePublisher) eventsFor(requestID string) (events []message.PayloadLifecycleMessage) {
+	for _, e := range f.events {
+		if e.RequestID == requestID {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+var _ = Describe("Payload lifecycle events", func() {
+	var (
+		msgHandler handler
+		publisher  *fakePublisher
+		requestID  string
+		received   time.Time
+	)
+
+	db := test.WithDatabase()
+
+	send := func(service string, status string, date time.Time) {
+		msg := getSimplePayloadStatusMessage()
+		msg.RequestID = requestID
+		msg.Service = service
+		msg.Status = status
+		msg.Date = message.FormatedTime{Time: date}
+		msgHandler.onMessage(context.Background(), newKafkaMessage(msg), config.Get())
+	}
+
+	BeforeEach(func() {
+		publisher = &fakePublisher{}
+		msgHandler = handler{db: db(), lifecycle: publisher}
+		requestID = strings.ReplaceAll(uuid.New().String(), "-", "")
+		received = time.Now().UTC().Add(-3 * time.Hour).Truncate(time.Second)
+	})
+
+	It("Publishes a terminal event once the payload reaches a terminal status", func() {
+		send("ingress", "received", received)
+		send("puptoo", "processing", received.Add(time.Minute))
+		Expect(publisher.eventsFor(requestID)).To(BeEmpty())
+
+		send("puptoo", "success", received.Add(2*time.Minute))
+		send("inventory", "success", received.Add(3*time.Minute))
+
+		events := publisher.eventsFor(requestID)
+		Expect(events).To(HaveLen(1))
+		Expect(events[0].Event).To(Equal(message.LifecycleTerminal))
+		Expect(events[0].Service).To(Equal("puptoo"))
+		Expect(events[0].Status).To(Equal("success"))
+		Expect(events[0].OrgID).To(Equal("5678"))
+		Expect(events[0].FirstSeen).To(BeTemporally("==", received))
+		Expect(events[0].LastSeen).To(BeTemporally("==", received.Add(2*time.Minute)))
+		Expect(events[0].Durations).To(HaveKeyWithValue("total_time", "00:02:00.000000"))
+		Expect(events[0].Durations).To(HaveKey("puptoo:test-source"))
+	})
+
+	It("Ignores terminal statuses arriving after a more recent status", func() {
+		send("ingress", "received", received.Add(time.Minute))
+		send("puptoo", "error", received)
+
+		Expect(publisher.eventsFor(requestID)).To(BeEmpty())
+	})
+
+	It("Declares payloads without a new status stuck once", func() {
+		send("ingress", "received", received)
+		send("puptoo", "processing", received.Add(time.Minute))
+
+		detector := &StuckDetector{db: db(), publisher: publisher, stuckAfter: time.Hour, lookback: 24 * time.Hour, batchSize: 1000}
+		now := time.Now()
+
+		detector.Check(context.Background(), now)
+		detector.Check(context.Background(), now)
+
+		events := publisher.eventsFor(requestID)
+		Expect(events).To(HaveLen(1))
+		Expect(events[0].Event).To(Equal(message.LifecycleStuck))
+		Expect(events[0].Status).To(Equal("processing"))
+		Expect(events[0].Durations).To(HaveKeyWithValue("total_time", "00:01:00.000000"))
+
+		// a stuck payload that finishes still gets its terminal event
+		send("puptoo", "success", received.Add(2*time.Minute))
+		Expect(publisher.eventsFor(requestID)).To(HaveLen(2))
+		Expect(publisher.eventsFor(requestID)[1].Event).To(Equal(message.LifecycleTerminal))
+	})
+
+	It("Leaves payloads alone that are too old or still moving", func() {
+		send("ingress", "received", received.Add(-48*time.Hour))
+
+		detector := &StuckDetector{db: db(), publisher: publisher, stuckAfter: 4 * time.Hour, lookback: 24 * time.Hour, batchSize: 1000}
+		detector.Check(context.Background(), time.Now())
+
+		Expect(publisher.eventsFor(requestID)).To(BeEmpty())
+
+		send("puptoo", "processing", received)
+		detector.Check(context.Background(), time.Now())
+
+		Expect(publisher.eventsFor(requestID)).To(BeEmpty())
+	})
+})
@@ -1,22 +1,44 @@
 package models
 
 import (
+	"context"
 	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// StatusMsgSearchConfig is the postgres text search configuration status messages are indexed with
+const StatusMsgSearchConfig = "english"
+
 type PayloadStatuses struct {
-	ID        uint  `gorm:"primaryKey;not null;autoIncrement"`
-	PayloadId uint  `gorm:"not null"`
-	ServiceId int32 `gorm:"not null"`
-	SourceId  int32
-	StatusId  int32     `gorm:"not null"`
-	StatusMsg string    `gorm:"type:varchar"`
-	Date      time.Time `gorm:"primaryKey;not null"`
-	CreatedAt time.Time `gorm:"not null"`
-	Payload   Payloads
-	Service   Services
-	Source    Sources
-	Status    Statuses
+	ID          uint  `gorm:"primaryKey;not null;autoIncrement"`
+	PayloadId   uint  `gorm:"not null"`
+	ServiceId   int32 `gorm:"not null"`
+	SourceId    int32
+	StatusId    int32     `gorm:"not null"`
+	StatusMsg   string    `gorm:"type:varchar"`
+	Fingerprint string    `gorm:"type:varchar"`
+	Date        time.Time `gorm:"primaryKey;not null"`
+	CreatedAt   time.Time `gorm:"not null"`
+	Payload     Payloads
+	Service     Services
+	Source      Sources
+	Status      Statuses
+
+	// StatusMsgSearch is only written, it's searched by /statuses?q= but never read back
+	StatusMsgSearch SearchVector `gorm:"type:tsvector;->:false;<-:create"`
+}
+
+// SearchVector is the text of a status message stored as a full text search vector
+type SearchVector string
+
+// GormValue computes the vector in postgres, other dialects don't index status messages
+func (v SearchVector) GormValue(ctx context.Context, db *gorm.DB) clause.Expr {
+	if db.Dialector.Name() != "postgres" {
+		return clause.Expr{SQL: "NULL"}
+	}
+	return clause.Expr{SQL: "to_tsvector('" + StatusMsgSearchConfig + "', ?::text)", Vars: []interface{}{string(v)}}
 }
 
 type Payloads struct {
@@ -29,17 +51,129 @@ type Payloads struct {
 	OrgId       string    `json:"org_id" gorm:"type:varchar"`
 }
 
+// PayloadLatestStatus summarizes the most recent status of a payload, maintained by the consumer
+type PayloadLatestStatus struct {
+	PayloadId uint       `gorm:"primaryKey;not null;autoIncrement:false"`
+	Service   string     `gorm:"not null;type:varchar"`
+	Source    string     `gorm:"type:varchar"`
+	Status    string     `gorm:"not null;type:varchar;index"`
+	Date      time.Time  `gorm:"not null;index"`
+	FirstSeen time.Time  `gorm:"not null"`
+	Terminal  bool       `gorm:"not null;index"`
+	ErrorMsg  string     `gorm:"type:varchar"`
+	StuckAt   *time.Time `gorm:"index"`
+	UpdatedAt time.Time  `gorm:"not null"`
+}
+
+func (PayloadLatestStatus) TableName() string {
+	return "payload_latest_status"
+}
+
+// PayloadStatusRollup counts the statuses reported per hour for each service, source, status and org
+type PayloadStatusRollup struct {
+	Hour    time.Time `gorm:"primaryKey;not null;autoIncrement:false"`
+	Service string    `gorm:"primaryKey;not null;type:varchar"`
+	Source  string    `gorm:"primaryKey;not null;type:varchar"`
+	Status  string    `gorm:"primaryKey;not null;type:varchar"`
+	OrgId   string    `gorm:"primaryKey;not null;type:varchar"`
+	Count   int64     `gorm:"not null"`
+}
+
+// ArchiveLinkAudit records every request for a payload archive link and its outcome
+type ArchiveLinkAudit struct {
+	Id           uint      `json:"id" gorm:"primaryKey;not null;autoIncrement"`
+	RequestId    string    `json:"request_id" gorm:"not null;type:varchar;index"`
+	OrgId        string    `json:"org_id" gorm:"type:varchar;index"`
+	Username     string    `json:"user" gorm:"type:varchar;index"`
+	IdentityType string    `json:"identity_type" gorm:"type:varchar"`
+	Outcome      string    `json:"outcome" gorm:"not null;type:varchar"`
+	StatusCode   int       `json:"status_code" gorm:"not null"`
+	Error        string    `json:"error,omitempty" gorm:"type:varchar"`
+	ApiRequestId string    `json:"api_request_id" gorm:"type:varchar"`
+	CreatedAt    time.Time `json:"created_at" gorm:"not null;index"`
+}
+
+// WebhookSubscription is notified of the statuses matching all of its non-empty filters
+type WebhookSubscription struct {
+	Id               uint      `json:"id" gorm:"primaryKey;not null;autoIncrement"`
+	Name             string    `json:"name" gorm:"not null;type:varchar"`
+	Url              string    `json:"url" gorm:"not null;type:varchar"`
+	Secret           string    `json:"secret,omitempty" gorm:"not null;type:varchar"`
+	Service          string    `json:"service" gorm:"type:varchar"`
+	Status           string    `json:"status" gorm:"type:varchar"`
+	OrgId            string    `json:"org_id" gorm:"type:varchar"`
+	StatusMsgPattern string    `json:"status_msg_pattern" gorm:"type:varchar"`
+	Enabled          bool      `json:"enabled" gorm:"not null"`
+	CreatedAt        time.Time `json:"created_at" gorm:"not null"`
+	UpdatedAt        time.Time `json:"updated_at" gorm:"not null"`
+}
+
+// WebhookDelivery is a status event queued for a subscription, kept as the delivery log
+// once it was delivered or given up on
+type WebhookDelivery struct {
+	Id             uint       `json:"id" gorm:"primaryKey;not null;autoIncrement"`
+	SubscriptionId uint       `json:"subscription_id" gorm:"not null;index"`
+	RequestId      string     `json:"request_id" gorm:"not null;type:varchar"`
+	Event          string     `json:"event" gorm:"not null;type:text"`
+	State          string     `json:"state" gorm:"not null;type:varchar;index:idx_webhook_deliveries_due,priority:1"`
+	Attempts       int        `json:"attempts" gorm:"not null"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at" gorm:"not null;index:idx_webhook_deliveries_due,priority:2"`
+	LastStatusCode int        `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty" gorm:"type:varchar"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"not null;index"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+// SLOEvaluation counts the payloads first seen in a minute and how many of them met an SLO
+type SLOEvaluation struct {
+	Slo    string    `gorm:"primaryKey;not null;type:varchar"`
+	Minute time.Time `gorm:"primaryKey;not null;autoIncrement:false"`
+	Total  int64     `gorm:"not null"`
+	Good   int64     `gorm:"not null"`
+}
+
+// Anomaly is an error rate spike or a never seen before error message of a service. A spike
+// stays open while it lasts, new messages are resolved once detected.
+type Anomaly struct {
+	Id           uint       `json:"id" gorm:"primaryKey;not null;autoIncrement"`
+	Kind         string     `json:"kind" gorm:"not null;type:varchar;uniqueIndex:idx_anomalies_open,where:resolved_at IS NULL"`
+	Service      string     `json:"service" gorm:"not null;type:varchar;uniqueIndex:idx_anomalies_open"`
+	StatusMsg    string     `json:"status_msg,omitempty" gorm:"type:varchar"`
+	Fingerprint  string     `json:"fingerprint,omitempty" gorm:"type:varchar"`
+	Errors       int64      `json:"errors" gorm:"not null"`
+	Total        int64      `json:"total" gorm:"not null"`
+	ErrorRate    float64    `json:"error_rate" gorm:"not null"`
+	BaselineRate float64    `json:"baseline_rate" gorm:"not null"`
+	DetectedAt   time.Time  `json:"detected_at" gorm:"not null;index"`
+	LastSeenAt   time.Time  `json:"last_seen_at" gorm:"not null"`
+	ResolvedAt   *time.Time `json:"resolved_at,omitempty"`
+}
+
+// ServiceErrorMessage is the fingerprint of an error message a service reported, new ones are anomalies
+type ServiceErrorMessage struct {
+	Service     string    `gorm:"primaryKey;not null;type:varchar"`
+	Fingerprint string    `gorm:"primaryKey;not null;type:varchar"`
+	FirstSeen   time.Time `gorm:"not null"`
+	LastSeen    time.Time `gorm:"not null;index"`
+}
+
 type Services struct {
-	Id   int32  `gorm:"primaryKey;not null;autoIncrement"`
-	Name string `gorm:"not null;type:varchar"`
+	Id        int32  `gorm:"primaryKey;not null;autoIncrement"`
+	Name      string `gorm:"not null;type:varchar"`
+	FirstSeen *time.Time
+	LastSeen  *time.Time
 }
 
 type Sources struct {
-	Id   int32  `gorm:"primaryKey;not null;autoIncrement"`
-	Name string `gorm:"not null;type:varchar"`
+	Id        int32  `gorm:"primaryKey;not null;autoIncrement"`
+	Name      string `gorm:"not null;type:varchar"`
+	FirstSeen *time.Time
+	LastSeen  *time.Time
 }
 
 type Statuses struct {
-	Id   int32  `gorm:"primaryKey;not null;autoIncrement"`
-	Name string `gorm:"not null;type:varchar"`
+	Id        int32  `gorm:"primaryKey;not null;autoIncrement"`
+	Name      string `gorm:"not null;type:varchar"`
+	FirstSeen *time.Time
+	LastSeen  *time.Time
 }
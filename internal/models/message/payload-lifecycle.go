@@ -0,0 +1,29 @@
+package message
+
+import (
+	"time"
+)
+
+const (
+	LifecycleTerminal = "terminal"
+	LifecycleStuck    = "stuck"
+)
+
+// PayloadLifecycleMessage is published on platform.payload-lifecycle when a payload reaches a
+// terminal status or is declared stuck. Durations are keyed like those of /payloads/{request_id}.
+type PayloadLifecycleMessage struct {
+	Event       string            `json:"event"`
+	RequestID   string            `json:"request_id"`
+	Account     string            `json:"account,omitempty"`
+	OrgID       string            `json:"org_id,omitempty"`
+	InventoryID string            `json:"inventory_id,omitempty"`
+	SystemID    string            `json:"system_id,omitempty"`
+	Service     string            `json:"service"`
+	Source      string            `json:"source,omitempty"`
+	Status      string            `json:"status"`
+	StatusMsg   string            `json:"status_msg,omitempty"`
+	FirstSeen   time.Time         `json:"first_seen"`
+	LastSeen    time.Time         `json:"last_seen"`
+	Durations   map[string]string `json:"durations"`
+	Date        time.Time         `json:"date"`
+}
@@ -5,18 +5,19 @@ import (
 )
 
 type PayloadStatuses struct {
-	ID        uint  `gorm:"primaryKey;not null;autoIncrement;type:bigint"`
-	PayloadId uint  `gorm:"not null"`
-	ServiceId int32 `gorm:"not null"`
-	SourceId  int32
-	StatusId  int32     `gorm:"not null"`
-	StatusMsg string    `gorm:"type:varchar"`
-	Date      time.Time `gorm:"primaryKey;not null"`
-	CreatedAt time.Time `gorm:"not null"`
-	Payload   Payloads
-	Service   Services
-	Source    Sources
-	Status    Statuses
+	ID          uint  `gorm:"primaryKey;not null;autoIncrement;type:bigint"`
+	PayloadId   uint  `gorm:"not null"`
+	ServiceId   int32 `gorm:"not null"`
+	SourceId    int32
+	StatusId    int32     `gorm:"not null"`
+	StatusMsg   string    `gorm:"type:varchar"`
+	Fingerprint string    `gorm:"type:varchar"`
+	Date        time.Time `gorm:"primaryKey;not null"`
+	CreatedAt   time.Time `gorm:"not null"`
+	Payload     Payloads
+	Service     Services
+	Source      Sources
+	Status      Statuses
 }
 
 type Payloads struct {
@@ -27,6 +28,15 @@ type Payloads struct {
 	SystemId    string    `json:"system_id" gorm:"type:varchar"`
 	CreatedAt   time.Time `json:"created_at" gorm:"not null"`
 	OrgId       string    `json:"org_id" gorm:"varchar"`
+
+	// Read-only fields joined from the payload_latest_status summary
+	LastService    string     `json:"last_service,omitempty" gorm:"->;-:migration"`
+	LastSource     string     `json:"last_source,omitempty" gorm:"->;-:migration"`
+	LastStatus     string     `json:"last_status,omitempty" gorm:"->;-:migration"`
+	LastStatusDate *time.Time `json:"last_status_date,omitempty" gorm:"->;-:migration"`
+	FirstSeen      *time.Time `json:"first_seen,omitempty" gorm:"->;-:migration"`
+	Terminal       *bool      `json:"terminal,omitempty" gorm:"->;-:migration"`
+	ErrorMsg       string     `json:"error_msg,omitempty" gorm:"->;-:migration"`
 }
 
 type Services struct {
@@ -0,0 +1,165 @@
+package queries
+
+import (
+	"time"
+
+	models "github.com/redhatinsights/payload-tracker-go/internal/models/db"
+	"github.com/redhatinsights/payload-tracker-go/internal/structs"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	AnomalyErrorRateSpike  = "error_rate_spike"
+	AnomalyNewErrorMessage = "new_error_message"
+)
+
+// ServiceErrorCounts are the statuses and error statuses a service reported
+type ServiceErrorCounts struct {
+	Service string
+	Total   int64
+	Errors  int64
+}
+
+// RecentErrorCounts counts the statuses and error statuses of each service dated from to to
+func RecentErrorCounts(db *gorm.DB, from time.Time, to time.Time) (counts []ServiceErrorCounts, err error) {
+	err = db.Table("payload_statuses").
+		Select("services.name as service, COUNT(*) as total, SUM(CASE WHEN statuses.name = ? THEN 1 ELSE 0 END) as errors", "error").
+		Joins("JOIN services ON services.id = payload_statuses.service_id").
+		Joins("JOIN statuses ON statuses.id = payload_statuses.status_id").
+		Where("payload_statuses.date >= ? AND payload_statuses.date < ?", from, to).
+		Group("services.name").Scan(&counts).Error
+
+	return counts, err
+}
+
+// BaselineErrorCounts counts the statuses and error statuses of each service from the hourly
+// rollups of the hours from from to to
+func BaselineErrorCounts(db *gorm.DB, from time.Time, to time.Time) (counts []ServiceErrorCounts, err error) {
+	err = db.Table("payload_status_rollups").
+		Select("service, SUM(count) as total, SUM(CASE WHEN status = ? THEN count ELSE 0 END) as errors", "error").
+		Where("hour >= ? AND hour < ?", from.UTC(), to.UTC()).
+		Group("service").Scan(&counts).Error
+
+	return counts, err
+}
+
+// RecentErrorMessage is an error message fingerprint of a service with one of its messages
+type RecentErrorMessage struct {
+	Service     string
+	Fingerprint string
+	Message     string
+}
+
+// RecentErrorMessages returns the distinct fingerprints of the error statuses dated from to to,
+// the most recently reported first so the limit keeps the same ones between calls
+func RecentErrorMessages(db *gorm.DB, from time.Time, to time.Time, limit int) (messages []RecentErrorMessage, err error) {
+	err = db.Table("payload_statuses").
+		Select("services.name as service, payload_statuses.fingerprint, MIN(payload_statuses.status_msg) as message").
+		Joins("JOIN services ON services.id = payload_statuses.service_id").
+		Where("payload_statuses.status_id IN (SELECT id FROM statuses WHERE name = ?)", "error").
+		Where("payload_statuses.date >= ? AND payload_statuses.date < ?", from, to).
+		Where("payload_statuses.fingerprint <> ''").
+		Group("services.name, payload_statuses.fingerprint").
+		Order("MAX(payload_statuses.date) desc, services.name, payload_statuses.fingerprint").
+		Limit(limit).Scan(&messages).Error
+
+	return messages, err
+}
+
+// ErrorMessagesLearntSince returns when the first known error message of a service was seen
+func ErrorMessagesLearntSince(db *gorm.DB, service string) (since time.Time, found bool, err error) {
+	var message models.ServiceErrorMessage
+	result := db.Where("service = ?", service).Order("first_seen").Limit(1).Find(&message)
+	return message.FirstSeen, result.RowsAffected == 1, result.Error
+}
+
+// LearnErrorMessage stores an error message fingerprint of a service, learnt is false when it was known
+func LearnErrorMessage(db *gorm.DB, message models.ServiceErrorMessage) (learnt bool, err error) {
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&message)
+	return result.RowsAffected == 1, result.Error
+}
+
+func TouchErrorMessages(db *gorm.DB, service string, fingerprints []string, now time.Time) *gorm.DB {
+	return db.Model(&models.ServiceErrorMessage{}).
+		Where("service = ? AND fingerprint IN ?", service, fingerprints).
+		Update("last_seen", now)
+}
+
+func DeleteErrorMessagesBefore(db *gorm.DB, before time.Time) *gorm.DB {
+	return db.Where("last_seen < ?", before).Delete(&models.ServiceErrorMessage{})
+}
+
+// OpenAnomaly stores an anomaly unless one of its kind is open for the service, which is
+// updated instead. Opened is true when the anomaly is new.
+func OpenAnomaly(db *gorm.DB, anomaly models.Anomaly) (opened bool, err error) {
+	onConflict := clause.OnConflict{
+		Columns:     []clause.Column{{Name: "kind"}, {Name: "service"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "resolved_at IS NULL"}}},
+		DoNothing:   true,
+	}
+
+	result := db.Clauses(onConflict).Create(&anomaly)
+	if result.Error != nil || result.RowsAffected == 1 {
+		return result.RowsAffected == 1, result.Error
+	}
+
+	err = db.Model(&models.Anomaly{}).
+		Where("kind = ? AND service = ? AND resolved_at IS NULL", anomaly.Kind, anomaly.Service).
+		Updates(map[string]interface{}{
+			"errors":        anomaly.Errors,
+			"total":         anomaly.Total,
+			"error_rate":    anomaly.ErrorRate,
+			"baseline_rate": anomaly.BaselineRate,
+			"last_seen_at":  anomaly.LastSeenAt,
+		}).Error
+
+	return false, err
+}
+
+// ResolveAnomalies resolves the open anomalies of a kind, except those of the given services
+func ResolveAnomalies(db *gorm.DB, kind string, except []string, now time.Time) *gorm.DB {
+	dbQuery := db.Model(&models.Anomaly{}).Where("kind = ? AND resolved_at IS NULL", kind)
+	if len(except) > 0 {
+		dbQuery = dbQuery.Where("service NOT IN ?", except)
+	}
+	return dbQuery.Update("resolved_at", now)
+}
+
+func InsertAnomaly(db *gorm.DB, anomaly *models.Anomaly) *gorm.DB {
+	return db.Create(anomaly)
+}
+
+func DeleteAnomaliesBefore(db *gorm.DB, before time.Time) *gorm.DB {
+	return db.Where("resolved_at < ?", before).Delete(&models.Anomaly{})
+}
+
+// RetrieveAnomalies returns a page of the anomalies matching the query, newest first
+var RetrieveAnomalies = func(db *gorm.DB, apiQuery structs.AnomalyQuery) (count int64, anomalies []models.Anomaly) {
+	dbQuery := db.Model(&models.Anomaly{})
+
+	if apiQuery.Kind != "" {
+		dbQuery = dbQuery.Where("kind = ?", apiQuery.Kind)
+	}
+	if apiQuery.Service != "" {
+		dbQuery = dbQuery.Where("service = ?", apiQuery.Service)
+	}
+	if apiQuery.Active != nil {
+		if *apiQuery.Active {
+			dbQuery = dbQuery.Where("resolved_at IS NULL")
+		} else {
+			dbQuery = dbQuery.Where("resolved_at IS NOT NULL")
+		}
+	}
+	if !apiQuery.Start.IsZero() {
+		dbQuery = dbQuery.Where("detected_at >= ?", apiQuery.Start.UTC())
+	}
+	if !apiQuery.End.IsZero() {
+		dbQuery = dbQuery.Where("detected_at < ?", apiQuery.End.UTC())
+	}
+
+	dbQuery.Count(&count)
+	dbQuery.Order("detected_at desc, id desc").Limit(apiQuery.PageSize).Offset(apiQuery.Page * apiQuery.PageSize).Find(&anomalies)
+
+	return count, anomalies
+}
@@ -8,14 +8,16 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/redhatinsights/payload-tracker-go/internal/models"
+	dbmodels "github.com/redhatinsights/payload-tracker-go/internal/models/db"
 	"github.com/redhatinsights/payload-tracker-go/internal/structs"
 )
 
 var (
 	payloadFields         = []string{"payloads.id", "payloads.request_id"}
 	extraPayloadFields    = []string{"payloads.account", "payloads.org_id", "payloads.system_id", "payloads.inventory_id"}
-	payloadStatusesFields = []string{"payload_statuses.status_msg", "payload_statuses.date", "payload_statuses.created_at"}
+	payloadStatusesFields = []string{"payload_statuses.status_msg", "payload_statuses.date", "payload_statuses.created_at", "payload_statuses.fingerprint"}
 	otherFields           = []string{"services.name as service", "sources.name as source", "statuses.name as status"}
+	latestStatusFields    = []string{"payload_latest_status.service as last_service", "payload_latest_status.source as last_source", "payload_latest_status.status as last_status", "payload_latest_status.date as last_status_date", "payload_latest_status.first_seen", "payload_latest_status.terminal", "payload_latest_status.error_msg"}
 )
 
 func defineVerbosity(verbosity string) string {
@@ -73,39 +75,125 @@ func chainTimeConditions(dbColumn string, apiQuery structs.Query, dbQuery *gorm.
 	}
 
 	if timeFields["lt"] != "" {
-		dbQuery = dbQuery.Where(fmt.Sprintf("%s < ?", dbColumn), timeFields["lt"])
+		dbQuery = dbQuery.Where(fmt.Sprintf("%s < ?", dbColumn), parseTimestamp(timeFields["lt"]))
 	}
 	if timeFields["lte"] != "" {
-		dbQuery = dbQuery.Where(fmt.Sprintf("%s <= ?", dbColumn), timeFields["lte"])
+		dbQuery = dbQuery.Where(fmt.Sprintf("%s <= ?", dbColumn), parseTimestamp(timeFields["lte"]))
 	}
 	if timeFields["gt"] != "" {
-		dbQuery = dbQuery.Where(fmt.Sprintf("%s > ?", dbColumn), timeFields["gt"])
+		dbQuery = dbQuery.Where(fmt.Sprintf("%s > ?", dbColumn), parseTimestamp(timeFields["gt"]))
 	}
 	if timeFields["gte"] != "" {
-		dbQuery = dbQuery.Where(fmt.Sprintf("%s >= ?", dbColumn), timeFields["gte"])
+		dbQuery = dbQuery.Where(fmt.Sprintf("%s >= ?", dbColumn), parseTimestamp(timeFields["gte"]))
 	}
 	return dbQuery
 }
 
+// parseTimestamp binds time filters as time.Time rather than raw strings so that every
+// dialect compares them in its own datetime format (sqlite stores datetimes as text)
+func parseTimestamp(timestamp string) interface{} {
+	parsed, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return timestamp
+	}
+	return parsed.UTC()
+}
+
+// chainFilter matches the column against the filter values. A negated filter also matches rows
+// without a value, e.g. a payload without a status isn't a successful one.
+func chainFilter(column string, filter structs.Filter, dbQuery *gorm.DB) *gorm.DB {
+	switch {
+	case len(filter.Values) == 0:
+		return dbQuery
+	case filter.Negate:
+		return dbQuery.Where(fmt.Sprintf("(%s IS NULL OR %s NOT IN ?)", column, column), filter.Values)
+	default:
+		return dbQuery.Where(fmt.Sprintf("%s IN ?", column), filter.Values)
+	}
+}
+
+// containsCondition matches the column case insensitively against any of the filter values,
+// the values are escaped so that % and _ are matched literally
+func containsCondition(column string, filter structs.Filter) (string, []interface{}) {
+	conditions := make([]string, len(filter.Values))
+	args := make([]interface{}, len(filter.Values))
+	for i, value := range filter.Values {
+		conditions[i] = fmt.Sprintf("LOWER(%s) LIKE ? ESCAPE '\\'", column)
+		args[i] = "%" + likeEscaper.Replace(strings.ToLower(value)) + "%"
+	}
+
+	return "(" + strings.Join(conditions, " OR ") + ")", args
+}
+
+var likeEscaper = strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_")
+
+// statusesOrder qualifies sort columns that exist in both payloads and payload_statuses,
+// not every dialect resolves them to the selected column
+func statusesOrder(sortBy string, sortDir string) string {
+	switch sortBy {
+	case "created_at", "date":
+		sortBy = "payload_statuses." + sortBy
+	case "rank":
+		return fmt.Sprintf("rank %s, payload_statuses.date desc", sortDir)
+	}
+	return fmt.Sprintf("%s %s", sortBy, sortDir)
+}
+
+// searchStatuses matches the status messages against a web search style query such as
+// "certificate expired" -renewed, selecting their rank and a highlighted snippet. Only postgres
+// indexes status messages, other dialects match the messages containing every word unranked.
+// Snippets are highlighted in the HTML escaped message, only the <mark> tags are markup.
+func searchStatuses(search string, fields string, dbQuery *gorm.DB) *gorm.DB {
+	if dbQuery.Dialector.Name() != "postgres" {
+		for _, word := range strings.Fields(search) {
+			condition, args := containsCondition("payload_statuses.status_msg", structs.Filter{Values: []string{word}})
+			dbQuery = dbQuery.Where(condition, args...)
+		}
+		return dbQuery.Select(fields + ",0 as rank," + escapedStatusMsg + " as snippet")
+	}
+
+	config := dbmodels.StatusMsgSearchConfig
+	dbQuery = dbQuery.Joins(fmt.Sprintf("CROSS JOIN websearch_to_tsquery('%s', ?) AS search", config), search)
+	dbQuery = dbQuery.Where("payload_statuses.status_msg_search @@ search")
+
+	rank := "ts_rank_cd(payload_statuses.status_msg_search, search) as rank"
+	snippet := fmt.Sprintf("ts_headline('%s', %s, search, '%s') as snippet", config, escapedStatusMsg, snippetOptions)
+	return dbQuery.Select(fields + "," + rank + "," + snippet)
+}
+
+const snippetOptions = "StartSel=<mark>, StopSel=</mark>, MaxWords=30, MinWords=10, MaxFragments=2"
+
+// escapedStatusMsg escapes the HTML special characters of the status message like html.EscapeString
+const escapedStatusMsg = "replace(replace(replace(replace(replace(payload_statuses.status_msg" +
+	", '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '\"', '&#34;'), '''', '&#39;')"
+
 var RetrievePayloads = func(dbQuery *gorm.DB, page int, pageSize int, apiQuery structs.Query) (int64, []models.Payloads) {
 	var count int64
 	var payloads []models.Payloads
 
+	fields := fmt.Sprintf("payloads.*,%s", strings.Join(latestStatusFields, ","))
+	dbQuery = dbQuery.Table("payloads").Select(fields).Joins("LEFT JOIN payload_latest_status on payload_latest_status.payload_id = payloads.id")
+
 	// query chaining
-	if apiQuery.Account != "" {
-		dbQuery = dbQuery.Where("account = ?", apiQuery.Account)
+	dbQuery = chainFilter("payloads.account", apiQuery.Account, dbQuery)
+	dbQuery = chainFilter("payloads.org_id", apiQuery.OrgID, dbQuery)
+	dbQuery = chainFilter("payloads.inventory_id", apiQuery.InventoryID, dbQuery)
+	dbQuery = chainFilter("payloads.system_id", apiQuery.SystemID, dbQuery)
+	dbQuery = chainFilter("payload_latest_status.service", apiQuery.Service, dbQuery)
+	dbQuery = chainFilter("payload_latest_status.status", apiQuery.Status, dbQuery)
+	if apiQuery.Terminal != "" {
+		dbQuery = dbQuery.Where("payload_latest_status.terminal = ?", apiQuery.Terminal == "true")
 	}
-	if apiQuery.OrgID != "" {
-		dbQuery = dbQuery.Where("org_id = ?", apiQuery.OrgID)
-	}
-	if apiQuery.InventoryID != "" {
-		dbQuery = dbQuery.Where("inventory_id = ?", apiQuery.InventoryID)
-	}
-	if apiQuery.SystemID != "" {
-		dbQuery = dbQuery.Where("system_id = ?", apiQuery.SystemID)
+	// payloads have no message of their own, they match when any of their statuses does
+	if len(apiQuery.StatusMsgContains.Values) > 0 {
+		condition, args := containsCondition("payload_statuses.status_msg", apiQuery.StatusMsgContains)
+		dbQuery = dbQuery.Where("EXISTS (SELECT 1 FROM payload_statuses WHERE payload_statuses.payload_id = payloads.id AND "+condition+")", args...)
 	}
 
-	dbQuery = chainTimeConditions("created_at", apiQuery, dbQuery)
+	dbQuery = chainTimeConditions("payloads.created_at", apiQuery, dbQuery)
+	if apiQuery.Since != "" {
+		dbQuery = dbQuery.Where("payloads.created_at >= ?", parseTimestamp(apiQuery.Since))
+	}
 
 	orderString := fmt.Sprintf("%s %s", apiQuery.SortBy, apiQuery.SortDir)
 
@@ -121,11 +209,9 @@ var RetrieveRequestIdPayloads = func(dbQuery *gorm.DB, reqID string, sortBy stri
 	fields := defineVerbosity(verbosity)
 
 	dbQuery = dbQuery.Table("payload_statuses").Select(fields).Joins("JOIN payloads on payload_statuses.payload_id = payloads.id")
-	dbQuery = dbQuery.Joins("JOIN services on payload_statuses.service_id = services.id").Joins("FULL OUTER JOIN sources on payload_statuses.source_id = sources.id").Joins("JOIN statuses on payload_statuses.status_id = statuses.id")
-
-	orderString := fmt.Sprintf("%s %s", sortBy, sortDir)
+	dbQuery = dbQuery.Joins("JOIN services on payload_statuses.service_id = services.id").Joins("LEFT JOIN sources on payload_statuses.source_id = sources.id").Joins("JOIN statuses on payload_statuses.status_id = statuses.id")
 
-	dbQuery.Where("payloads.request_id = ?", reqID).Order(orderString).Scan(&payloads)
+	dbQuery.Where("payloads.request_id = ?", reqID).Order(statusesOrder(sortBy, sortDir)).Scan(&payloads)
 
 	return payloads
 }
@@ -140,28 +226,93 @@ var RetrieveStatuses = func(dbQuery *gorm.DB, apiQuery structs.Query) (int64, []
 	fields := fmt.Sprintf("%s,%s,%s", strings.Join(payloadFields, ","), strings.Join(payloadStatusesFields, ","), strings.Join(otherFields, ","))
 	dbQuery = dbQuery.Table("payload_statuses").Select(fields).Joins("JOIN payloads on payload_statuses.payload_id = payloads.id")
 	dbQuery = dbQuery.Joins("JOIN services on payload_statuses.service_id = services.id").Joins("JOIN sources on payload_statuses.source_id = sources.id").Joins("JOIN statuses on payload_statuses.status_id = statuses.id")
+	if apiQuery.Search != "" {
+		dbQuery = searchStatuses(apiQuery.Search, fields, dbQuery)
+	}
+
+	// query chaining
+	dbQuery = chainFilter("services.name", apiQuery.Service, dbQuery)
+	dbQuery = chainFilter("sources.name", apiQuery.Source, dbQuery)
+	dbQuery = chainFilter("statuses.name", apiQuery.Status, dbQuery)
+	dbQuery = chainFilter("payload_statuses.status_msg", apiQuery.StatusMsg, dbQuery)
+	dbQuery = chainFilter("payload_statuses.fingerprint", apiQuery.Fingerprint, dbQuery)
+	if len(apiQuery.StatusMsgContains.Values) > 0 {
+		condition, args := containsCondition("payload_statuses.status_msg", apiQuery.StatusMsgContains)
+		dbQuery = dbQuery.Where(condition, args...)
+	}
+	dbQuery = chainTimeConditions("date", apiQuery, dbQuery)
+	dbQuery = chainTimeConditions("payload_statuses.created_at", apiQuery, dbQuery)
+	if apiQuery.Since != "" {
+		dbQuery = dbQuery.Where("payload_statuses.date >= ?", parseTimestamp(apiQuery.Since))
+	}
+
+	dbQuery.Model(&payloads).Count(&count)
+	dbQuery.Order(statusesOrder(apiQuery.SortBy, apiQuery.SortDir)).Limit(pageSize).Offset(pageSize * page).Scan(&payloads)
+
+	return count, payloads
+}
+
+var RetrieveTimeseries = func(dbQuery *gorm.DB, apiQuery structs.TimeseriesQuery) []structs.TimeseriesPoint {
+	rows := []structs.TimeseriesPoint{}
+
+	fields := strings.Join(append([]string{"hour as time", "SUM(count) as count"}, apiQuery.GroupBy...), ",")
+	groupBy := strings.Join(append([]string{"hour"}, apiQuery.GroupBy...), ",")
+
+	dbQuery = dbQuery.Table("payload_status_rollups").Select(fields)
+	dbQuery = dbQuery.Where("hour >= ?", apiQuery.Start.UTC()).Where("hour < ?", apiQuery.End.UTC())
 
 	// query chaining
 	if apiQuery.Service != "" {
-		dbQuery = dbQuery.Where("services.name = ?", apiQuery.Service)
+		dbQuery = dbQuery.Where("service = ?", apiQuery.Service)
 	}
 	if apiQuery.Source != "" {
-		dbQuery = dbQuery.Where("sources.name = ?", apiQuery.Source)
+		dbQuery = dbQuery.Where("source = ?", apiQuery.Source)
 	}
 	if apiQuery.Status != "" {
-		dbQuery = dbQuery.Where("statuses.name = ?", apiQuery.Status)
+		dbQuery = dbQuery.Where("status = ?", apiQuery.Status)
 	}
-	if apiQuery.StatusMsg != "" {
-		dbQuery = dbQuery.Where("payload_statuses.status_msg = ?", apiQuery.StatusMsg)
+	if apiQuery.OrgID != "" {
+		dbQuery = dbQuery.Where("org_id = ?", apiQuery.OrgID)
 	}
-	dbQuery = chainTimeConditions("date", apiQuery, dbQuery)
-	dbQuery = chainTimeConditions("payload_statuses.created_at", apiQuery, dbQuery)
 
-	orderString := fmt.Sprintf("%s %s", apiQuery.SortBy, apiQuery.SortDir)
-	dbQuery.Model(&payloads).Count(&count)
-	dbQuery.Order(orderString).Limit(pageSize).Offset(pageSize * page).Scan(&payloads)
+	dbQuery.Group(groupBy).Order("hour asc").Scan(&rows)
 
-	return count, payloads
+	return bucketTimeseries(rows, apiQuery.Bucket)
+}
+
+// bucketTimeseries folds the hourly rollup rows into day or week buckets. Truncating in Go
+// keeps the query portable; the rows are already aggregated per hour so there are few of them.
+func bucketTimeseries(rows []structs.TimeseriesPoint, bucket string) []structs.TimeseriesPoint {
+	if bucket == "hour" {
+		return rows
+	}
+
+	points := []structs.TimeseriesPoint{}
+	index := make(map[structs.TimeseriesPoint]int)
+
+	for _, row := range rows {
+		t := row.Time.UTC()
+		t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		if bucket == "week" {
+			// weeks start on monday
+			t = t.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+		}
+
+		key := row
+		key.Time = t
+		key.Count = 0
+
+		if i, ok := index[key]; ok {
+			points[i].Count += row.Count
+			continue
+		}
+
+		index[key] = len(points)
+		key.Count = row.Count
+		points = append(points, key)
+	}
+
+	return points
 }
 
 func CalculateDurations(payloadData []structs.SinglePayloadData) map[string]string {
@@ -0,0 +1,31 @@
+package queries
+
+import (
+	models "github.com/redhatinsights/payload-tracker-go/internal/models/db"
+	"github.com/redhatinsights/payload-tracker-go/internal/structs"
+	"gorm.io/gorm"
+)
+
+var InsertArchiveLinkAudit = func(db *gorm.DB, audit *models.ArchiveLinkAudit) *gorm.DB {
+	return db.Create(audit)
+}
+
+// RetrieveArchiveLinkAudits returns a page of audit records, newest first
+var RetrieveArchiveLinkAudits = func(db *gorm.DB, q structs.ArchiveLinkAuditQuery) (count int64, audits []models.ArchiveLinkAudit) {
+	dbQuery := db.Model(&models.ArchiveLinkAudit{})
+
+	if q.User != "" {
+		dbQuery = dbQuery.Where("username = ?", q.User)
+	}
+	if q.OrgID != "" {
+		dbQuery = dbQuery.Where("org_id = ?", q.OrgID)
+	}
+	if q.RequestID != "" {
+		dbQuery = dbQuery.Where("request_id = ?", q.RequestID)
+	}
+
+	dbQuery.Count(&count)
+	dbQuery.Order("created_at desc").Order("id desc").Limit(q.PageSize).Offset(q.Page * q.PageSize).Find(&audits)
+
+	return count, audits
+}
@@ -0,0 +1,115 @@
+package queries
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/structs"
+)
+
+// Catalog tables list the names reported so far, each is counted in a column of the rollups
+const (
+	CatalogServices = "services"
+	CatalogSources  = "sources"
+	CatalogStatuses = "statuses"
+)
+
+var catalogRollupColumns = map[string]string{
+	CatalogServices: "service",
+	CatalogSources:  "source",
+	CatalogStatuses: "status",
+}
+
+// TouchCatalogEntry records that a name of a catalog table was reported by a status dated date,
+// statuses arriving out of order only move first_seen back and last_seen forward
+func TouchCatalogEntry(db *gorm.DB, table string, name string, date time.Time) *gorm.DB {
+	return db.Table(table).
+		Where("name = ? AND (first_seen IS NULL OR first_seen > ? OR last_seen IS NULL OR last_seen < ?)", name, date, date).
+		Updates(map[string]interface{}{
+			"first_seen": gorm.Expr("CASE WHEN first_seen IS NULL OR first_seen > ? THEN ? ELSE first_seen END", date, date),
+			"last_seen":  gorm.Expr("CASE WHEN last_seen IS NULL OR last_seen < ? THEN ? ELSE last_seen END", date, date),
+		})
+}
+
+// backfillCatalog widens the first and last seen times of a catalog table to the hours of the rollups
+const backfillCatalog = `UPDATE %[1]s SET
+	first_seen = CASE WHEN %[1]s.first_seen IS NULL OR %[1]s.first_seen > seen.first_seen THEN seen.first_seen ELSE %[1]s.first_seen END,
+	last_seen = CASE WHEN %[1]s.last_seen IS NULL OR %[1]s.last_seen < seen.last_seen THEN seen.last_seen ELSE %[1]s.last_seen END
+FROM (
+	SELECT %[2]s AS name, MIN(hour) AS first_seen, MAX(hour) AS last_seen FROM payload_status_rollups GROUP BY %[2]s
+) seen
+WHERE %[1]s.name = seen.name`
+
+// BackfillCatalog sets the first and last seen times of the names reported before the consumer
+// recorded them from the hourly rollups, the hours they were first and last counted in. It is
+// safe to re-run, later times recorded by the consumer are kept.
+func BackfillCatalog(db *gorm.DB) (updated int64, err error) {
+	for _, table := range []string{CatalogServices, CatalogSources, CatalogStatuses} {
+		result := db.Exec(fmt.Sprintf(backfillCatalog, table, catalogRollupColumns[table]))
+		if result.Error != nil {
+			return updated, result.Error
+		}
+		updated += result.RowsAffected
+	}
+	return updated, nil
+}
+
+// RetrieveCatalog returns the names of a catalog table sorted, with the statuses reported since
+// the start of the hour of RecentSince. The tables are small, names created twice by concurrent
+// consumers are merged here.
+var RetrieveCatalog = func(db *gorm.DB, table string, apiQuery structs.CatalogQuery) ([]structs.CatalogEntry, error) {
+	var rows []structs.CatalogEntry
+	if err := db.Table(table).Select("name, first_seen, last_seen").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	column := catalogRollupColumns[table]
+	var counts []structs.CatalogEntry
+	err := db.Table("payload_status_rollups").
+		Select(column+" as name, SUM(count) as recent_count").
+		Where("hour >= ?", apiQuery.RecentSince.UTC().Truncate(time.Hour)).
+		Group(column).Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	recent := make(map[string]int64, len(counts))
+	for _, count := range counts {
+		recent[count.Name] = count.RecentCount
+	}
+
+	index := make(map[string]int, len(rows))
+	entries := []structs.CatalogEntry{}
+	for _, row := range rows {
+		i, ok := index[row.Name]
+		if !ok {
+			index[row.Name] = len(entries)
+			row.RecentCount = recent[row.Name]
+			entries = append(entries, row)
+			continue
+		}
+
+		if row.FirstSeen != nil && (entries[i].FirstSeen == nil || row.FirstSeen.Before(*entries[i].FirstSeen)) {
+			entries[i].FirstSeen = row.FirstSeen
+		}
+		if row.LastSeen != nil && (entries[i].LastSeen == nil || row.LastSeen.After(*entries[i].LastSeen)) {
+			entries[i].LastSeen = row.LastSeen
+		}
+	}
+
+	if !apiQuery.SeenSince.IsZero() {
+		seen := entries[:0]
+		for _, entry := range entries {
+			if entry.LastSeen != nil && !entry.LastSeen.Before(apiQuery.SeenSince) {
+				seen = append(seen, entry)
+			}
+		}
+		entries = seen
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries, nil
+}
@@ -1,6 +1,9 @@
 package queries
 
 import (
+	"fmt"
+	"time"
+
 	models "github.com/redhatinsights/payload-tracker-go/internal/models/db"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
@@ -11,6 +14,8 @@ const (
 	PayloadJoins  = "left join Payloads on Payloads.id = PayloadStatuses.payload_id"
 )
 
+// TerminalStatuses are the statuses that end the processing of a payload
+var TerminalStatuses = []string{"success", "error"}
 
 func GetServiceByName(db *gorm.DB, service_id string) models.Services {
 	var service models.Services
@@ -102,3 +107,105 @@ func InsertPayloadStatus(db *gorm.DB, payloadStatus *models.PayloadStatuses) (tx
 	}
 	return db.Create(&payloadStatus)
 }
+
+// UpsertLatestStatus records a status in the payload_latest_status summary. Statuses arriving
+// out of order only move first_seen back and never replace a more recent latest status.
+func UpsertLatestStatus(db *gorm.DB, latest models.PayloadLatestStatus) (tx *gorm.DB) {
+	newer := "excluded.date >= payload_latest_status.date"
+	latestWins := func(column string) clause.Assignment {
+		return clause.Assignment{
+			Column: clause.Column{Name: column},
+			Value:  gorm.Expr(fmt.Sprintf("CASE WHEN %s THEN excluded.%s ELSE payload_latest_status.%s END", newer, column, column)),
+		}
+	}
+
+	onConflict := clause.OnConflict{
+		Columns: []clause.Column{{Name: "payload_id"}},
+		DoUpdates: clause.Set{
+			latestWins("service"),
+			latestWins("source"),
+			latestWins("status"),
+			latestWins("terminal"),
+			latestWins("date"),
+			// a new status means the payload is moving again
+			latestWins("stuck_at"),
+			{
+				Column: clause.Column{Name: "first_seen"},
+				Value:  gorm.Expr("CASE WHEN excluded.first_seen < payload_latest_status.first_seen THEN excluded.first_seen ELSE payload_latest_status.first_seen END"),
+			},
+			// only error statuses carry a message, a newer status clears it
+			latestWins("error_msg"),
+			{
+				Column: clause.Column{Name: "updated_at"},
+				Value:  gorm.Expr("excluded.updated_at"),
+			},
+		},
+	}
+
+	return db.Clauses(onConflict).Create(&latest)
+}
+
+// backfillLatestStatus summarizes the payloads that have statuses but no latest status yet,
+// e.g. the ones stored before the summary was added
+const backfillLatestStatus = `INSERT INTO payload_latest_status (payload_id, service, source, status, date, first_seen, terminal, error_msg, updated_at)
+SELECT latest.payload_id, latest.service, latest.source, latest.status, latest.date, latest.first_seen,
+	latest.status IN @terminal, CASE WHEN latest.status = 'error' THEN latest.status_msg ELSE '' END, now()
+FROM (
+	SELECT DISTINCT ON (payload_statuses.payload_id) payload_statuses.payload_id, services.name AS service,
+		COALESCE(sources.name, '') AS source, statuses.name AS status, COALESCE(payload_statuses.status_msg, '') AS status_msg, payload_statuses.date,
+		MIN(payload_statuses.date) OVER (PARTITION BY payload_statuses.payload_id) AS first_seen
+	FROM payload_statuses
+	JOIN services ON services.id = payload_statuses.service_id
+	LEFT JOIN sources ON sources.id = payload_statuses.source_id
+	JOIN statuses ON statuses.id = payload_statuses.status_id
+	WHERE NOT EXISTS (SELECT 1 FROM payload_latest_status WHERE payload_latest_status.payload_id = payload_statuses.payload_id)
+	ORDER BY payload_statuses.payload_id, payload_statuses.date DESC
+) latest
+ON CONFLICT (payload_id) DO NOTHING`
+
+// BackfillLatestStatus fills payload_latest_status for the payloads missing from it. It is
+// postgres only and safe to re-run, payloads the consumer summarized meanwhile are kept.
+func BackfillLatestStatus(db *gorm.DB) (tx *gorm.DB) {
+	return db.Exec(backfillLatestStatus, map[string]interface{}{"terminal": TerminalStatuses})
+}
+
+// GetLatestStatus returns the latest status summary of a payload, found is false for new payloads
+func GetLatestStatus(db *gorm.DB, payloadId uint) (latest models.PayloadLatestStatus, found bool, err error) {
+	result := db.Where("payload_id = ?", payloadId).Limit(1).Find(&latest)
+	return latest, result.RowsAffected == 1, result.Error
+}
+
+// StuckPayloads returns the id and request id of payloads whose latest status is not terminal
+// and was reported between since and before, skipping payloads already declared stuck
+func StuckPayloads(db *gorm.DB, since time.Time, before time.Time, limit int) (payloads []models.Payloads, err error) {
+	err = db.Table("payload_latest_status").Select("payloads.id, payloads.request_id").
+		Joins("JOIN payloads on payloads.id = payload_latest_status.payload_id").
+		Where("payload_latest_status.terminal = ? AND payload_latest_status.stuck_at IS NULL", false).
+		Where("payload_latest_status.date >= ? AND payload_latest_status.date < ?", since, before).
+		Order("payload_latest_status.date").Limit(limit).Scan(&payloads).Error
+	return payloads, err
+}
+
+// ClaimStuckPayload marks a payload stuck unless a new status arrived or another consumer
+// declared it stuck first
+func ClaimStuckPayload(db *gorm.DB, payloadId uint, before time.Time, now time.Time) (bool, error) {
+	result := db.Model(&models.PayloadLatestStatus{}).
+		Where("payload_id = ? AND terminal = ? AND stuck_at IS NULL AND date < ?", payloadId, false, before).
+		Update("stuck_at", now)
+	return result.RowsAffected == 1, result.Error
+}
+
+// IncrementRollup adds one to the hourly count of the rollup's service, source, status and org
+func IncrementRollup(db *gorm.DB, rollup models.PayloadStatusRollup) (tx *gorm.DB) {
+	rollup.Count = 1
+
+	onConflict := clause.OnConflict{
+		Columns: []clause.Column{{Name: "hour"}, {Name: "service"}, {Name: "source"}, {Name: "status"}, {Name: "org_id"}},
+		DoUpdates: clause.Set{{
+			Column: clause.Column{Name: "count"},
+			Value:  gorm.Expr("payload_status_rollups.count + 1"),
+		}},
+	}
+
+	return db.Clauses(onConflict).Create(&rollup)
+}
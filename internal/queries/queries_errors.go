@@ -0,0 +1,93 @@
+package queries
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/fingerprint"
+	"github.com/redhatinsights/payload-tracker-go/internal/structs"
+)
+
+// fingerprintStatuses selects the statuses with a fingerprint matching the query
+func fingerprintStatuses(db *gorm.DB, apiQuery structs.ErrorsQuery) *gorm.DB {
+	dbQuery := db.Table("payload_statuses").
+		Joins("JOIN services ON services.id = payload_statuses.service_id").
+		Where("payload_statuses.status_id IN (SELECT id FROM statuses WHERE name = ?)", apiQuery.Status).
+		Where("payload_statuses.date >= ? AND payload_statuses.date < ?", apiQuery.Start.UTC(), apiQuery.End.UTC()).
+		Where("payload_statuses.fingerprint <> ''")
+
+	if apiQuery.Service != "" {
+		dbQuery = dbQuery.Where("services.name = ?", apiQuery.Service)
+	}
+
+	return dbQuery
+}
+
+// RetrieveErrorFingerprints returns a page of the fingerprints of each service, most frequent
+// first, with the latest request ids that reported them
+var RetrieveErrorFingerprints = func(db *gorm.DB, apiQuery structs.ErrorsQuery) (count int64, fingerprints []structs.ErrorFingerprint, err error) {
+	groups := fingerprintStatuses(db, apiQuery).
+		Select("services.name as service, payload_statuses.fingerprint, COUNT(*) as count, MIN(payload_statuses.status_msg) as sample_msg").
+		Group("services.name, payload_statuses.fingerprint")
+	fingerprints = []structs.ErrorFingerprint{}
+
+	if err = db.Table("(?) as fingerprints", groups).Count(&count).Error; err != nil {
+		return count, fingerprints, err
+	}
+
+	err = groups.Order("count desc, service, fingerprint").
+		Limit(apiQuery.PageSize).Offset(apiQuery.Page * apiQuery.PageSize).
+		Scan(&fingerprints).Error
+	if err != nil {
+		return count, fingerprints, err
+	}
+
+	ids := make([]string, len(fingerprints))
+	index := make(map[[2]string]int)
+	for i, f := range fingerprints {
+		ids[i] = f.Fingerprint
+		index[[2]string{f.Service, f.Fingerprint}] = i
+		fingerprints[i].Pattern = fingerprint.Normalize(f.SampleMsg)
+		fingerprints[i].SampleRequestIDs = []string{}
+	}
+	if len(fingerprints) == 0 || apiQuery.Samples == 0 {
+		return count, fingerprints, nil
+	}
+
+	var samples []struct {
+		Service     string
+		Fingerprint string
+		RequestId   string
+	}
+	// the latest statuses of each fingerprint, payloads can report the same error more than once
+	latest := fingerprintStatuses(db, apiQuery).
+		Select("services.name as service, payload_statuses.fingerprint, payloads.request_id, ROW_NUMBER() OVER (PARTITION BY services.name, payload_statuses.fingerprint ORDER BY payload_statuses.date DESC) as n").
+		Joins("JOIN payloads ON payloads.id = payload_statuses.payload_id").
+		Where("payload_statuses.fingerprint IN ?", ids)
+	err = db.Table("(?) as samples", latest).Select("service, fingerprint, request_id").
+		Where("n <= ?", apiQuery.Samples*2).Order("n").Scan(&samples).Error
+	if err != nil {
+		return count, fingerprints, err
+	}
+
+	for _, s := range samples {
+		i, ok := index[[2]string{s.Service, s.Fingerprint}]
+		if !ok {
+			continue
+		}
+		f := &fingerprints[i]
+		if len(f.SampleRequestIDs) < apiQuery.Samples && !containsString(f.SampleRequestIDs, s.RequestId) {
+			f.SampleRequestIDs = append(f.SampleRequestIDs, s.RequestId)
+		}
+	}
+
+	return count, fingerprints, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package queries
+
+import (
+	"regexp"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var partitionBoundRegex = regexp.MustCompile(`FOR VALUES FROM \('([^']+)'\) TO \('([^']+)'\)`)
+
+var partitionBoundLayouts = []string{"2006-01-02 15:04:05-07", "2006-01-02 15:04:05", "2006-01-02"}
+
+// RetrievePartitionBounds returns the range bounds of every partition of a postgres table,
+// e.g. FOR VALUES FROM ('2024-01-01 00:00:00+00') TO ('2024-01-02 00:00:00+00'). Partitioned
+// is false when the table isn't partitioned at all.
+var RetrievePartitionBounds = func(db *gorm.DB, table string) (partitioned bool, bounds []string, err error) {
+	err = db.Raw("SELECT COALESCE(bool_or(relkind = 'p'), false) FROM pg_class WHERE oid = to_regclass(?)", table).Scan(&partitioned).Error
+	if err != nil || !partitioned {
+		return partitioned, nil, err
+	}
+
+	err = db.Raw(`SELECT pg_get_expr(c.relpartbound, c.oid) FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = to_regclass(?)`, table).Scan(&bounds).Error
+
+	return partitioned, bounds, err
+}
+
+// PartitionCovers checks whether one of the range partition bounds includes t. Default
+// partitions and bounds using MINVALUE or MAXVALUE are ignored.
+func PartitionCovers(bounds []string, t time.Time) bool {
+	for _, bound := range bounds {
+		match := partitionBoundRegex.FindStringSubmatch(bound)
+		if match == nil {
+			continue
+		}
+
+		from, fromErr := parsePartitionBound(match[1])
+		to, toErr := parsePartitionBound(match[2])
+		if fromErr != nil || toErr != nil {
+			continue
+		}
+
+		if !t.Before(from) && t.Before(to) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parsePartitionBound(value string) (t time.Time, err error) {
+	for _, layout := range partitionBoundLayouts {
+		if t, err = time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return t, err
+}
@@ -0,0 +1,119 @@
+package queries
+
+import (
+	"fmt"
+	"strings"
+
+	models "github.com/redhatinsights/payload-tracker-go/internal/models/db"
+	"gorm.io/gorm"
+)
+
+const statusMsgSearchIndex = "idx_payload_statuses_status_msg_search"
+
+// backfillStatusMsgSearch computes the search vectors of an id range, the same way the consumer does
+const backfillStatusMsgSearch = "UPDATE payload_statuses SET status_msg_search = to_tsvector('" + models.StatusMsgSearchConfig + "', COALESCE(status_msg, ''))" +
+	" WHERE id >= ? AND id < ? AND status_msg_search IS NULL"
+
+// BackfillStatusMsgSearch fills the search vectors of the statuses stored before the consumer
+// wrote them, batchSize ids at a time so no update holds its row locks for long. It is postgres
+// only and safe to re-run.
+func BackfillStatusMsgSearch(db *gorm.DB, batchSize int) (filled int64, err error) {
+	var ids struct {
+		First int64
+		Last  int64
+	}
+	err = db.Raw("SELECT COALESCE(MIN(id), 0) AS first, COALESCE(MAX(id), 0) AS last FROM payload_statuses").Scan(&ids).Error
+	if err != nil {
+		return 0, err
+	}
+
+	for from := ids.First; from <= ids.Last; from += int64(batchSize) {
+		result := db.Exec(backfillStatusMsgSearch, from, from+int64(batchSize))
+		if result.Error != nil {
+			return filled, result.Error
+		}
+		filled += result.RowsAffected
+	}
+
+	return filled, nil
+}
+
+// CreateStatusMsgSearchIndex creates the GIN index of the search vectors without blocking writes
+// to payload_statuses. Postgres can't build the index of a partitioned table concurrently, so it
+// is built on each partition and attached to the index of the table instead. It is postgres only
+// and safe to re-run.
+func CreateStatusMsgSearchIndex(db *gorm.DB) error {
+	exists, valid, err := indexValid(db, statusMsgSearchIndex)
+	if err != nil || valid {
+		return err
+	}
+
+	partitioned, _, err := RetrievePartitionBounds(db, "payload_statuses")
+	if err != nil {
+		return err
+	}
+	if !partitioned {
+		if exists {
+			// left behind by a build that failed
+			if err := db.Exec("DROP INDEX CONCURRENTLY " + statusMsgSearchIndex).Error; err != nil {
+				return err
+			}
+		}
+		return db.Exec("CREATE INDEX CONCURRENTLY " + statusMsgSearchIndex + " ON payload_statuses USING gin (status_msg_search)").Error
+	}
+
+	// only the index of the table, it becomes valid once every partition's index is attached
+	err = db.Exec("CREATE INDEX IF NOT EXISTS " + statusMsgSearchIndex + " ON ONLY payload_statuses USING gin (status_msg_search)").Error
+	if err != nil {
+		return err
+	}
+
+	var partitions []string
+	err = db.Raw("SELECT c.relname FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid WHERE i.inhparent = to_regclass(?)", "payload_statuses").
+		Scan(&partitions).Error
+	if err != nil {
+		return err
+	}
+
+	for _, partition := range partitions {
+		index := quoteIdentifier(partition + "_status_msg_search_idx")
+
+		exists, valid, err := indexValid(db, index)
+		if err != nil {
+			return err
+		}
+		if exists && !valid {
+			if err := db.Exec("DROP INDEX CONCURRENTLY " + index).Error; err != nil {
+				return err
+			}
+		}
+		if !valid {
+			err = db.Exec(fmt.Sprintf("CREATE INDEX CONCURRENTLY %s ON %s USING gin (status_msg_search)", index, quoteIdentifier(partition))).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		// attaching an index that already is attached does nothing
+		if err := db.Exec(fmt.Sprintf("ALTER INDEX %s ATTACH PARTITION %s", statusMsgSearchIndex, index)).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// indexValid checks whether an index exists and is valid, a failed concurrent build leaves an
+// invalid one behind
+func indexValid(db *gorm.DB, index string) (exists bool, valid bool, err error) {
+	var indexes []bool
+	err = db.Raw("SELECT indisvalid FROM pg_index WHERE indexrelid = to_regclass(?)", index).Scan(&indexes).Error
+	if err != nil || len(indexes) == 0 {
+		return false, false, err
+	}
+	return true, indexes[0], nil
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
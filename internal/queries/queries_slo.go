@@ -0,0 +1,92 @@
+package queries
+
+import (
+	"time"
+
+	models "github.com/redhatinsights/payload-tracker-go/internal/models/db"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SLOPayload is a payload first seen at FirstSeen with one of the dates it reached an SLO's
+// status, Reached is nil when it hasn't
+type SLOPayload struct {
+	PayloadId uint
+	FirstSeen time.Time
+	Reached   *time.Time
+}
+
+// RetrieveSLOTotals sums the evaluated payloads of an SLO first seen between from and to
+var RetrieveSLOTotals = func(db *gorm.DB, slo string, from time.Time, to time.Time) (total int64, good int64, err error) {
+	var totals struct {
+		Total int64
+		Good  int64
+	}
+
+	err = db.Model(&models.SLOEvaluation{}).Select("COALESCE(SUM(total), 0) as total, COALESCE(SUM(good), 0) as good").
+		Where("slo = ? AND minute >= ? AND minute < ?", slo, from, to).Scan(&totals).Error
+
+	return totals.Total, totals.Good, err
+}
+
+// LastSLOEvaluation returns the latest minute evaluated for an SLO
+func LastSLOEvaluation(db *gorm.DB, slo string) (minute time.Time, found bool, err error) {
+	var evaluation models.SLOEvaluation
+	result := db.Where("slo = ?", slo).Order("minute desc").Limit(1).Find(&evaluation)
+	return evaluation.Minute, result.RowsAffected == 1, result.Error
+}
+
+// RetrieveSLOPayloads returns the payloads first seen between from and to, with a row for each
+// time service reported status before reachedBefore. Any service counts when service is empty,
+// otherwise a payload is first seen when service first reported on it, so the time it spent
+// upstream doesn't count against the SLO, and only the payloads service reported on are returned.
+func RetrieveSLOPayloads(db *gorm.DB, service string, status string, from time.Time, to time.Time, reachedBefore time.Time) (payloads []SLOPayload, err error) {
+	// the date bounds let postgres skip the payload_statuses partitions that can't match
+	join := "LEFT JOIN payload_statuses ON payload_statuses.payload_id = payload_latest_status.payload_id" +
+		" AND payload_statuses.date >= ? AND payload_statuses.date < ?" +
+		" AND payload_statuses.status_id IN (SELECT id FROM statuses WHERE name = ?)"
+	args := []interface{}{from, reachedBefore, status}
+
+	if service == "" {
+		err = db.Table("payload_latest_status").
+			Select("payload_latest_status.payload_id, payload_latest_status.first_seen, payload_statuses.date as reached").
+			Joins(join, args...).
+			Where("payload_latest_status.first_seen >= ? AND payload_latest_status.first_seen < ?", from, to).
+			Scan(&payloads).Error
+
+		return payloads, err
+	}
+
+	// handled is the first status of the service for the payload, none of its statuses since
+	// the payload was first seen is earlier
+	handled := "JOIN payload_statuses handled ON handled.payload_id = payload_latest_status.payload_id" +
+		" AND handled.date >= ? AND handled.date < ?" +
+		" AND handled.service_id IN (SELECT id FROM services WHERE name = ?)" +
+		" AND NOT EXISTS (SELECT 1 FROM payload_statuses earlier WHERE earlier.payload_id = handled.payload_id" +
+		" AND earlier.service_id = handled.service_id AND earlier.date >= payload_latest_status.first_seen" +
+		" AND (earlier.date < handled.date OR (earlier.date = handled.date AND earlier.id < handled.id)))"
+	join += " AND payload_statuses.service_id = handled.service_id"
+
+	err = db.Table("payload_latest_status").
+		Select("payload_latest_status.payload_id, handled.date as first_seen, payload_statuses.date as reached").
+		Joins(handled, from, to, service).
+		Joins(join, args...).
+		Where("payload_latest_status.first_seen < ?", to).
+		Scan(&payloads).Error
+
+	return payloads, err
+}
+
+// UpsertSLOEvaluations stores evaluated minutes, evaluating a minute again replaces its counts
+func UpsertSLOEvaluations(db *gorm.DB, evaluations []models.SLOEvaluation) *gorm.DB {
+	onConflict := clause.OnConflict{
+		Columns:   []clause.Column{{Name: "slo"}, {Name: "minute"}},
+		DoUpdates: clause.AssignmentColumns([]string{"total", "good"}),
+	}
+
+	return db.Clauses(onConflict).Create(&evaluations)
+}
+
+func DeleteSLOEvaluationsBefore(db *gorm.DB, before time.Time) *gorm.DB {
+	return db.Where("minute < ?", before).Delete(&models.SLOEvaluation{})
+}
@@ -1,7 +1,10 @@
 package queries
 
 import (
+	"time"
+
 	models "github.com/redhatinsights/payload-tracker-go/internal/models/db"
+	"github.com/redhatinsights/payload-tracker-go/internal/structs"
 	"github.com/redhatinsights/payload-tracker-go/internal/utils/test"
 
 	"github.com/google/uuid"
@@ -162,4 +165,144 @@ var _ = Describe("Queries", func() {
 		Expect(payload.Account).To(Equal("1234"))
 		Expect(payload.OrgId).To(Equal("1234"))
 	})
+	It("Keeps the most recent status as the latest status", func() {
+		payload := models.Payloads{RequestId: getUUID()}
+		Expect(db().Create(&payload).Error).ToNot(HaveOccurred())
+
+		received, _ := time.Parse(time.RFC3339, "2022-06-07T11:00:00Z")
+		failed := received.Add(time.Minute)
+		processing := received.Add(30 * time.Second)
+
+		for _, latest := range []models.PayloadLatestStatus{
+			{PayloadId: payload.Id, Service: "ingress", Status: "received", Date: received, FirstSeen: received},
+			{PayloadId: payload.Id, Service: "puptoo", Status: "error", Date: failed, FirstSeen: failed, Terminal: true, ErrorMsg: "timeout"},
+			{PayloadId: payload.Id, Service: "puptoo", Status: "processing", Date: processing, FirstSeen: processing},
+		} {
+			Expect(UpsertLatestStatus(db(), latest).Error).ToNot(HaveOccurred())
+		}
+
+		var latest models.PayloadLatestStatus
+		Expect(db().Where("payload_id = ?", payload.Id).First(&latest).Error).ToNot(HaveOccurred())
+
+		Expect(latest.Service).To(Equal("puptoo"))
+		Expect(latest.Status).To(Equal("error"))
+		Expect(latest.Terminal).To(BeTrue())
+		Expect(latest.ErrorMsg).To(Equal("timeout"))
+		Expect(latest.Date.Equal(failed)).To(BeTrue())
+		Expect(latest.FirstSeen.Equal(received)).To(BeTrue())
+	})
+	It("Clears the error message when a newer status replaces the error", func() {
+		payload := models.Payloads{RequestId: getUUID()}
+		Expect(db().Create(&payload).Error).ToNot(HaveOccurred())
+
+		failed, _ := time.Parse(time.RFC3339, "2022-06-07T11:00:00Z")
+		retried := failed.Add(time.Minute)
+
+		for _, latest := range []models.PayloadLatestStatus{
+			{PayloadId: payload.Id, Service: "puptoo", Status: "error", Date: failed, FirstSeen: failed, Terminal: true, ErrorMsg: "timeout"},
+			{PayloadId: payload.Id, Service: "puptoo", Status: "success", Date: retried, FirstSeen: retried, Terminal: true},
+		} {
+			Expect(UpsertLatestStatus(db(), latest).Error).ToNot(HaveOccurred())
+		}
+
+		var latest models.PayloadLatestStatus
+		Expect(db().Where("payload_id = ?", payload.Id).First(&latest).Error).ToNot(HaveOccurred())
+
+		Expect(latest.Status).To(Equal("success"))
+		Expect(latest.ErrorMsg).To(BeEmpty())
+	})
+	It("Retrieves archive link audits newest first", func() {
+		requestId := getUUID()
+		user := getUUID()
+		for _, outcome := range []string{"forbidden", "success", "not_found"} {
+			audit := models.ArchiveLinkAudit{RequestId: requestId, OrgId: "1234", Username: user, Outcome: outcome, StatusCode: 200}
+			Expect(InsertArchiveLinkAudit(db(), &audit).Error).ToNot(HaveOccurred())
+		}
+		other := models.ArchiveLinkAudit{RequestId: getUUID(), OrgId: "1234", Username: getUUID(), Outcome: "success", StatusCode: 200}
+		Expect(InsertArchiveLinkAudit(db(), &other).Error).ToNot(HaveOccurred())
+
+		count, audits := RetrieveArchiveLinkAudits(db(), structs.ArchiveLinkAuditQuery{User: user, Page: 0, PageSize: 2})
+		Expect(count).To(Equal(int64(3)))
+		Expect(audits).To(HaveLen(2))
+		Expect(audits[0].Outcome).To(Equal("not_found"))
+		Expect(audits[1].Outcome).To(Equal("success"))
+
+		count, audits = RetrieveArchiveLinkAudits(db(), structs.ArchiveLinkAuditQuery{RequestID: requestId, Page: 1, PageSize: 2})
+		Expect(count).To(Equal(int64(3)))
+		Expect(audits).To(HaveLen(1))
+		Expect(audits[0].Outcome).To(Equal("forbidden"))
+	})
+	It("Keeps the earliest and latest status dates of a catalog name", func() {
+		name := "catalog-" + getUUID()
+		_, service := CreateServiceTableEntry(db(), name)
+
+		first, _ := time.Parse(time.RFC3339, "2022-06-07T11:00:00Z")
+		last := first.Add(time.Hour)
+		for _, date := range []time.Time{first.Add(time.Minute), last, first, last.Add(-time.Minute)} {
+			Expect(TouchCatalogEntry(db(), CatalogServices, name, date).Error).ToNot(HaveOccurred())
+		}
+
+		service = GetServiceByName(db(), name)
+		Expect(service.FirstSeen.Equal(first)).To(BeTrue())
+		Expect(service.LastSeen.Equal(last)).To(BeTrue())
+	})
+	It("Backfills the catalog from the rollups", func() {
+		name := "catalog-" + getUUID()
+		deployed, _ := time.Parse(time.RFC3339, "2022-06-07T11:00:00Z")
+		first := deployed.Add(-48 * time.Hour)
+		Expect(db().Create(&models.Services{Name: name, FirstSeen: &deployed, LastSeen: &deployed}).Error).ToNot(HaveOccurred())
+
+		rollups := []models.PayloadStatusRollup{
+			{Hour: first, Service: name, Status: "success", Count: 1},
+			{Hour: deployed.Add(-time.Hour), Service: name, Status: "error", Count: 1},
+		}
+		Expect(db().Create(&rollups).Error).ToNot(HaveOccurred())
+
+		_, err := BackfillCatalog(db())
+		Expect(err).ToNot(HaveOccurred())
+
+		service := GetServiceByName(db(), name)
+		Expect(service.FirstSeen.Equal(first)).To(BeTrue())
+		Expect(service.LastSeen.Equal(deployed)).To(BeTrue())
+	})
+})
+
+var _ = Describe("Timeseries buckets", func() {
+	It("Sums hourly rows into weekly buckets starting on monday", func() {
+		sunday, _ := time.Parse(time.RFC3339, "2022-06-05T23:00:00Z")
+		monday, _ := time.Parse(time.RFC3339, "2022-06-06T01:00:00Z")
+		tuesday, _ := time.Parse(time.RFC3339, "2022-06-07T10:00:00Z")
+
+		points := bucketTimeseries([]structs.TimeseriesPoint{
+			{Time: sunday, Service: "puptoo", Count: 1},
+			{Time: monday, Service: "puptoo", Count: 2},
+			{Time: tuesday, Service: "puptoo", Count: 3},
+			{Time: tuesday, Service: "ingress", Count: 4},
+		}, "week")
+
+		Expect(points).To(HaveLen(3))
+		Expect(points[0].Time.Format(time.RFC3339)).To(Equal("2022-05-30T00:00:00Z"))
+		Expect(points[0].Count).To(Equal(int64(1)))
+		Expect(points[1].Time.Format(time.RFC3339)).To(Equal("2022-06-06T00:00:00Z"))
+		Expect(points[1].Count).To(Equal(int64(5)))
+		Expect(points[2].Service).To(Equal("ingress"))
+		Expect(points[2].Count).To(Equal(int64(4)))
+	})
+})
+
+var _ = Describe("Partition bounds", func() {
+	It("Checks partition bounds cover a date", func() {
+		bounds := []string{
+			"DEFAULT",
+			"FOR VALUES FROM ('2022-06-06 00:00:00+00') TO ('2022-06-07 00:00:00+00')",
+			"FOR VALUES FROM ('2022-06-07') TO ('2022-06-08')",
+		}
+
+		covered, _ := time.Parse(time.RFC3339, "2022-06-07T00:00:00Z")
+		uncovered, _ := time.Parse(time.RFC3339, "2022-06-08T00:00:00Z")
+
+		Expect(PartitionCovers(bounds, covered)).To(BeTrue())
+		Expect(PartitionCovers(bounds, uncovered)).To(BeFalse())
+		Expect(PartitionCovers(nil, covered)).To(BeFalse())
+	})
 })
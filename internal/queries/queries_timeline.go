@@ -0,0 +1,99 @@
+package queries
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/structs"
+)
+
+// BuildTimeline lays the statuses of a payload out as spans per service:source,
+// ordered by start, and the gaps where none of them was reporting
+func BuildTimeline(requestID string, payloadData []structs.SinglePayloadData) structs.PayloadTimeline {
+	statuses := make([]structs.SinglePayloadData, len(payloadData))
+	copy(statuses, payloadData)
+	sort.SliceStable(statuses, func(i, j int) bool {
+		return statuses[i].Date.Before(statuses[j].Date)
+	})
+
+	timeline := structs.PayloadTimeline{
+		RequestID: requestID,
+		Spans:     []structs.TimelineSpan{},
+		Gaps:      []structs.TimelineGap{},
+	}
+	if len(statuses) == 0 {
+		return timeline
+	}
+
+	timeline.Start = statuses[0].Date
+	timeline.End = statuses[len(statuses)-1].Date
+	timeline.DurationMs = timeline.End.Sub(timeline.Start).Milliseconds()
+	if isTerminal(statuses[len(statuses)-1].Status) {
+		timeline.Outcome = statuses[len(statuses)-1].Status
+	}
+
+	spanIndex := make(map[string]int)
+	for _, v := range statuses {
+		source := "undefined"
+		if v.Source != "" {
+			source = v.Source
+		}
+		name := fmt.Sprintf("%s:%s", v.Service, source)
+
+		i, ok := spanIndex[name]
+		if !ok {
+			i = len(timeline.Spans)
+			spanIndex[name] = i
+			timeline.Spans = append(timeline.Spans, structs.TimelineSpan{
+				Name:     name,
+				Service:  v.Service,
+				Source:   v.Source,
+				Start:    v.Date,
+				OffsetMs: v.Date.Sub(timeline.Start).Milliseconds(),
+				Statuses: []structs.TimelineStatus{},
+			})
+		}
+
+		span := &timeline.Spans[i]
+		span.End = v.Date
+		span.DurationMs = span.End.Sub(span.Start).Milliseconds()
+		if isTerminal(v.Status) {
+			span.Outcome = v.Status
+		}
+		span.Statuses = append(span.Statuses, structs.TimelineStatus{
+			Status:    v.Status,
+			StatusMsg: v.StatusMsg,
+			Date:      v.Date,
+			OffsetMs:  v.Date.Sub(timeline.Start).Milliseconds(),
+		})
+	}
+
+	// spans are already ordered by start as statuses are walked by date
+	last := timeline.Spans[0]
+	for _, span := range timeline.Spans[1:] {
+		if span.Start.After(last.End) {
+			timeline.Gaps = append(timeline.Gaps, structs.TimelineGap{
+				From:       last.Name,
+				To:         span.Name,
+				Start:      last.End,
+				End:        span.Start,
+				OffsetMs:   last.End.Sub(timeline.Start).Milliseconds(),
+				DurationMs: span.Start.Sub(last.End).Milliseconds(),
+			})
+		}
+		if span.End.After(last.End) {
+			last = span
+		}
+	}
+
+	return timeline
+}
+
+func isTerminal(status string) bool {
+	for _, terminal := range TerminalStatuses {
+		if status == terminal {
+			return true
+		}
+	}
+	return false
+}
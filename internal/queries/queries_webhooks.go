@@ -0,0 +1,97 @@
+package queries
+
+import (
+	"time"
+
+	models "github.com/redhatinsights/payload-tracker-go/internal/models/db"
+	"gorm.io/gorm"
+)
+
+const (
+	WebhookPending   = "pending"
+	WebhookDelivered = "delivered"
+	WebhookFailed    = "failed"
+)
+
+var RetrieveWebhookSubscriptions = func(db *gorm.DB) (subscriptions []models.WebhookSubscription) {
+	db.Order("id").Find(&subscriptions)
+	return subscriptions
+}
+
+var GetWebhookSubscription = func(db *gorm.DB, id uint) (subscription models.WebhookSubscription, err error) {
+	err = db.First(&subscription, id).Error
+	return subscription, err
+}
+
+var CreateWebhookSubscription = func(db *gorm.DB, subscription *models.WebhookSubscription) *gorm.DB {
+	return db.Create(subscription)
+}
+
+var UpdateWebhookSubscription = func(db *gorm.DB, subscription *models.WebhookSubscription) *gorm.DB {
+	return db.Save(subscription)
+}
+
+// DeleteWebhookSubscription deletes the subscription and the deliveries it still had pending,
+// the delivery log of past deliveries is kept
+var DeleteWebhookSubscription = func(db *gorm.DB, id uint) (found bool, err error) {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.WebhookSubscription{}, id)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		found = true
+		return tx.Where("subscription_id = ? AND state = ?", id, WebhookPending).Delete(&models.WebhookDelivery{}).Error
+	})
+	return found, err
+}
+
+// RetrieveWebhookDeliveries returns a page of the deliveries of a subscription, newest first
+var RetrieveWebhookDeliveries = func(db *gorm.DB, subscriptionId uint, state string, page int, pageSize int) (count int64, deliveries []models.WebhookDelivery) {
+	dbQuery := db.Model(&models.WebhookDelivery{}).Where("subscription_id = ?", subscriptionId)
+	if state != "" {
+		dbQuery = dbQuery.Where("state = ?", state)
+	}
+
+	dbQuery.Count(&count)
+	dbQuery.Order("id desc").Limit(pageSize).Offset(page * pageSize).Find(&deliveries)
+
+	return count, deliveries
+}
+
+func EnabledWebhookSubscriptions(db *gorm.DB) (subscriptions []models.WebhookSubscription, err error) {
+	err = db.Where("enabled = ?", true).Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+func InsertWebhookDeliveries(db *gorm.DB, deliveries []models.WebhookDelivery) *gorm.DB {
+	return db.Create(&deliveries)
+}
+
+// DeleteWebhookDeliveriesBefore deletes the delivered and failed deliveries created before before
+func DeleteWebhookDeliveriesBefore(db *gorm.DB, before time.Time) *gorm.DB {
+	return db.Where("state <> ? AND created_at < ?", WebhookPending, before).Delete(&models.WebhookDelivery{})
+}
+
+// DueWebhookDeliveries returns pending deliveries whose next attempt is due, oldest first
+func DueWebhookDeliveries(db *gorm.DB, now time.Time, limit int) (deliveries []models.WebhookDelivery, err error) {
+	err = db.Where("state = ? AND next_attempt_at <= ?", WebhookPending, now).
+		Order("next_attempt_at").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+// ClaimWebhookDelivery pushes the next attempt of a due delivery out to until. Only one of
+// several dispatchers claiming the same delivery succeeds.
+func ClaimWebhookDelivery(db *gorm.DB, id uint, now time.Time, until time.Time) (bool, error) {
+	result := db.Model(&models.WebhookDelivery{}).
+		Where("id = ? AND state = ? AND next_attempt_at <= ?", id, WebhookPending, now).
+		Update("next_attempt_at", until)
+	return result.RowsAffected == 1, result.Error
+}
+
+// UpdateWebhookDelivery records the outcome of a claimed delivery. It only matches while the
+// delivery is still pending with the attempts it was claimed with, so a dispatcher whose
+// claim ran out can't overwrite the outcome recorded by the one that claimed it again.
+func UpdateWebhookDelivery(db *gorm.DB, delivery *models.WebhookDelivery, claimedAttempts int) *gorm.DB {
+	return db.Model(delivery).Where("state = ? AND attempts = ?", WebhookPending, claimedAttempts).
+		Select("state", "attempts", "next_attempt_at", "last_status_code", "last_error", "delivered_at").Updates(delivery)
+}
@@ -0,0 +1,173 @@
+package slo
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/config"
+	"github.com/redhatinsights/payload-tracker-go/internal/endpoints"
+	l "github.com/redhatinsights/payload-tracker-go/internal/logging"
+	models "github.com/redhatinsights/payload-tracker-go/internal/models/db"
+	"github.com/redhatinsights/payload-tracker-go/internal/queries"
+	"github.com/redhatinsights/payload-tracker-go/internal/structs"
+)
+
+// evaluated minutes are kept this much longer than the longest window
+const retention = 24 * time.Hour
+
+// Evaluator counts, per minute, the payloads first seen in it and how many of them met each
+// SLO once their threshold passed, then exports the burn rates. Every minute is evaluated once
+// per replica, evaluating it again stores the same counts.
+type Evaluator struct {
+	db       *gorm.DB
+	slos     endpoints.SLOs
+	interval time.Duration
+	chunk    time.Duration
+
+	evaluated map[string]time.Time
+}
+
+func NewEvaluator(cfg *config.TrackerConfig, db *gorm.DB, slos endpoints.SLOs) *Evaluator {
+	return &Evaluator{
+		db:        db,
+		slos:      slos,
+		interval:  time.Duration(cfg.SLOConfig.EvaluationIntervalSeconds) * time.Second,
+		chunk:     time.Hour,
+		evaluated: make(map[string]time.Time),
+	}
+}
+
+// Run evaluates the SLOs every interval until the context is done
+func (e *Evaluator) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		e.Evaluate(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Evaluate catches up on the minutes that became due for every SLO and updates the gauges
+func (e *Evaluator) Evaluate(ctx context.Context, now time.Time) {
+	db := e.db.WithContext(ctx)
+
+	for _, d := range e.slos.Definitions {
+		if err := e.evaluate(db, d, now); err != nil {
+			l.Log.Errorf("Unable to evaluate SLO %s: %v", d.Name, err)
+		}
+	}
+
+	report, err := e.slos.Report(db, now)
+	if err != nil {
+		l.Log.Errorf("Unable to report SLOs: %v", err)
+		return
+	}
+	for _, status := range report {
+		endpoints.SetSLOGauges(status)
+	}
+
+	oldest := now.Add(-e.slos.MaxWindow() - retention)
+	if err := queries.DeleteSLOEvaluationsBefore(db, oldest).Error; err != nil {
+		l.Log.Errorf("Unable to delete old SLO evaluations: %v", err)
+	}
+}
+
+func (e *Evaluator) evaluate(db *gorm.DB, d structs.SLODefinition, now time.Time) error {
+	end := e.slos.Frontier(d, now)
+	threshold := time.Duration(d.ThresholdSeconds) * time.Second
+
+	start, err := e.start(db, d, end)
+	if err != nil {
+		return err
+	}
+
+	for start.Before(end) {
+		chunkEnd := start.Add(e.chunk)
+		if chunkEnd.After(end) {
+			chunkEnd = end
+		}
+
+		payloads, err := queries.RetrieveSLOPayloads(db, d.Service, d.Status, start, chunkEnd, chunkEnd.Add(threshold))
+		if err != nil {
+			return err
+		}
+
+		evaluations := countMinutes(d, payloads, start, chunkEnd)
+		if err := queries.UpsertSLOEvaluations(db, evaluations).Error; err != nil {
+			return err
+		}
+
+		e.evaluated[d.Name] = chunkEnd
+		start = chunkEnd
+	}
+
+	return nil
+}
+
+// start returns the first minute not evaluated yet, going back no further than the longest window
+func (e *Evaluator) start(db *gorm.DB, d structs.SLODefinition, end time.Time) (time.Time, error) {
+	earliest := end.Add(-e.slos.MaxWindow())
+
+	start, ok := e.evaluated[d.Name]
+	if !ok {
+		last, found, err := queries.LastSLOEvaluation(db, d.Name)
+		if err != nil {
+			return start, err
+		}
+		if found {
+			start = last.UTC().Add(time.Minute)
+		}
+	}
+
+	if start.Before(earliest) {
+		start = earliest
+	}
+	return start, nil
+}
+
+// countMinutes counts the payloads first seen in each minute from start to end and the ones
+// that reached the status within the threshold. Minutes without payloads are stored too, they
+// mark the progress of the evaluation.
+func countMinutes(d structs.SLODefinition, payloads []queries.SLOPayload, start time.Time, end time.Time) []models.SLOEvaluation {
+	threshold := time.Duration(d.ThresholdSeconds) * time.Second
+
+	minutes := make(map[time.Time]*models.SLOEvaluation)
+	evaluations := make([]models.SLOEvaluation, 0, int(end.Sub(start)/time.Minute))
+	for minute := start; minute.Before(end); minute = minute.Add(time.Minute) {
+		evaluations = append(evaluations, models.SLOEvaluation{Slo: d.Name, Minute: minute})
+	}
+	for i := range evaluations {
+		minutes[evaluations[i].Minute] = &evaluations[i]
+	}
+
+	// a payload has a row for every time it reached the status
+	good := make(map[uint]bool)
+	seen := make(map[uint]time.Time)
+	for _, p := range payloads {
+		seen[p.PayloadId] = p.FirstSeen
+		if p.Reached != nil && p.Reached.Sub(p.FirstSeen) <= threshold {
+			good[p.PayloadId] = true
+		}
+	}
+
+	for id, firstSeen := range seen {
+		evaluation, ok := minutes[firstSeen.UTC().Truncate(time.Minute)]
+		if !ok {
+			continue
+		}
+		evaluation.Total++
+		if good[id] {
+			evaluation.Good++
+		}
+	}
+
+	return evaluations
+}
@@ -0,0 +1,128 @@
+package slo
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/config"
+	"github.com/redhatinsights/payload-tracker-go/internal/endpoints"
+	models "github.com/redhatinsights/payload-tracker-go/internal/models/db"
+	"github.com/redhatinsights/payload-tracker-go/internal/queries"
+	"github.com/redhatinsights/payload-tracker-go/internal/utils/test"
+)
+
+var _ = Describe("Evaluator", func() {
+	var (
+		cfg       config.TrackerConfig
+		slos      endpoints.SLOs
+		now       time.Time
+		firstSeen time.Time
+	)
+
+	db := test.WithDatabase()
+	fixtures := test.WithPayloads(db)
+
+	// payload records a payload received by ingress at firstSeen and handled by serviceName from
+	// the given time, which reported success after the given time
+	payload := func(serviceName string, handled time.Duration, success time.Duration) {
+		statuses := []models.PayloadStatuses{
+			{ServiceId: fixtures.ServiceId("ingress"), StatusId: fixtures.StatusId("received"), Date: firstSeen},
+			{ServiceId: fixtures.ServiceId(serviceName), StatusId: fixtures.StatusId("processing"), Date: firstSeen.Add(handled)},
+		}
+		if success > 0 {
+			statuses = append(statuses, models.PayloadStatuses{ServiceId: fixtures.ServiceId(serviceName), StatusId: fixtures.StatusId("success"), Date: firstSeen.Add(success)})
+		}
+		p := fixtures.Create(models.Payloads{CreatedAt: firstSeen}, statuses...)
+
+		latest := models.PayloadLatestStatus{PayloadId: p.Id, Service: "ingress", Status: "received", Date: firstSeen, FirstSeen: firstSeen}
+		Expect(queries.UpsertLatestStatus(db(), latest).Error).ToNot(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		cfg = *config.Get()
+		cfg.SLOConfig.Definitions = `[{"name": "evaluator-puptoo", "service": "puptoo", "threshold_seconds": 600, "objective": 0.9}]`
+		cfg.SLOConfig.Windows = []string{"1h"}
+		cfg.SLOConfig.GraceSeconds = 0
+
+		var err error
+		slos, err = endpoints.ParseSLOs(cfg)
+		Expect(err).ToNot(HaveOccurred())
+
+		// the fixtures are deleted after each spec, no other payloads are first seen this long ago
+		now = time.Date(2001, 2, 3, 12, 0, 0, 0, time.UTC)
+		firstSeen = now.Add(-30 * time.Minute).Add(20 * time.Second)
+	})
+
+	AfterEach(func() {
+		db().Where("slo = ?", slos.Definitions[0].Name).Delete(&models.SLOEvaluation{})
+	})
+
+	It("Should count the payloads that reached the status in time", func() {
+		payload("puptoo", time.Second, 5*time.Minute)
+		payload("puptoo", time.Second, 20*time.Minute)
+		payload("inventory", time.Second, time.Minute)
+		payload("puptoo", time.Second, 0)
+
+		NewEvaluator(&cfg, db(), slos).Evaluate(context.Background(), now)
+
+		name := slos.Definitions[0].Name
+		total, good, err := queries.RetrieveSLOTotals(db(), name, now.Add(-time.Hour), now)
+		Expect(err).ToNot(HaveOccurred())
+		// the inventory payload was never handled by puptoo
+		Expect(total).To(Equal(int64(3)))
+		Expect(good).To(Equal(int64(1)))
+
+		var evaluations []models.SLOEvaluation
+		db().Where("slo = ?", name).Order("minute").Find(&evaluations)
+		// every minute of the window up to the frontier is marked evaluated
+		Expect(evaluations).To(HaveLen(60))
+		Expect(evaluations[len(evaluations)-1].Minute.UTC()).To(Equal(now.Add(-11 * time.Minute)))
+
+		report, err := slos.Report(db(), now)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(report[0].Windows[0].BurnRate).To(BeNumerically("~", 20.0/3))
+	})
+
+	It("Should measure from the first status of the service", func() {
+		// 20 minutes after ingress received it, but only 5 after puptoo picked it up
+		payload("puptoo", 15*time.Minute, 20*time.Minute)
+		payload("puptoo", 15*time.Minute, 26*time.Minute)
+
+		NewEvaluator(&cfg, db(), slos).Evaluate(context.Background(), now)
+
+		total, good, err := queries.RetrieveSLOTotals(db(), slos.Definitions[0].Name, now.Add(-time.Hour), now)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(total).To(Equal(int64(2)))
+		Expect(good).To(Equal(int64(1)))
+
+		var evaluation models.SLOEvaluation
+		Expect(db().Where("slo = ? AND total > 0", slos.Definitions[0].Name).First(&evaluation).Error).ToNot(HaveOccurred())
+		Expect(evaluation.Minute.UTC()).To(Equal(firstSeen.Add(15 * time.Minute).Truncate(time.Minute)))
+	})
+
+	It("Should only evaluate the minutes that became due since the last evaluation", func() {
+		evaluator := NewEvaluator(&cfg, db(), slos)
+		evaluator.Evaluate(context.Background(), now.Add(-30*time.Minute))
+
+		// first seen after the frontier of the previous evaluation, the next one counts it
+		payload("puptoo", time.Second, time.Minute)
+
+		evaluator.Evaluate(context.Background(), now)
+
+		total, _, err := queries.RetrieveSLOTotals(db(), slos.Definitions[0].Name, now.Add(-2*time.Hour), now)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(total).To(Equal(int64(1)))
+
+		// a new evaluator continues where the stored evaluations end
+		Expect(NewEvaluator(&cfg, db(), slos).start(db(), slos.Definitions[0], now.Add(-10*time.Minute))).To(Equal(now.Add(-10 * time.Minute)))
+	})
+
+	It("Should go back no further than the longest window", func() {
+		start, err := NewEvaluator(&cfg, db(), slos).start(db(), slos.Definitions[0], now)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(start).To(Equal(now.Add(-time.Hour)))
+	})
+})
@@ -0,0 +1,15 @@
+package slo
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	l "github.com/redhatinsights/payload-tracker-go/internal/logging"
+)
+
+func TestSLO(t *testing.T) {
+	RegisterFailHandler(Fail)
+	l.InitLogger()
+	RunSpecs(t, "SLO Suite")
+}
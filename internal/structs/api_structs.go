@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/redhatinsights/payload-tracker-go/internal/models"
+	dbmodels "github.com/redhatinsights/payload-tracker-go/internal/models/db"
 )
 
 // Query is a struct for holding query params
@@ -13,23 +14,47 @@ type Query struct {
 	RequestID    string
 	SortBy       string
 	SortDir      string
-	Account      string
-	OrgID        string
-	InventoryID  string
-	SystemID     string
+	Account      Filter
+	OrgID        Filter
+	InventoryID  Filter
+	SystemID     Filter
 	CreatedAtLT  string
 	CreatedAtLTE string
 	CreatedAtGT  string
 	CreatedAtGTE string
 
-	Service   string
-	Source    string
-	Status    string
-	StatusMsg string
-	DateLT    string
-	DateLTE   string
-	DateGT    string
-	DateGTE   string
+	Service           Filter
+	Source            Filter
+	Status            Filter
+	StatusMsg         Filter
+	StatusMsgContains Filter
+	Fingerprint       Filter
+	DateLT            string
+	DateLTE           string
+	DateGT            string
+	DateGTE           string
+	Since             string
+	Search            string
+
+	Terminal string
+}
+
+// Filter matches a column against any of its values, or against none of them when negated
+type Filter struct {
+	Values []string
+	Negate bool
+}
+
+// TimeseriesQuery holds the params for the /stats/timeseries endpoint
+type TimeseriesQuery struct {
+	Bucket  string
+	GroupBy []string
+	Service string
+	Source  string
+	Status  string
+	OrgID   string
+	Start   time.Time
+	End     time.Time
 }
 
 // PayloadsData is the response for the /payloads endpoint
@@ -53,6 +78,19 @@ type PayloadKibanaLink struct {
 	Url string `json:"url"`
 }
 
+type LogLink struct {
+	Name string `json:"name"`
+	Url  string `json:"url"`
+}
+
+// PayloadLogLinks is the response for /payloads/{request_id}/logLinks, the links search from
+// the first to the last status date of the payload
+type PayloadLogLinks struct {
+	From  time.Time `json:"from"`
+	To    time.Time `json:"to"`
+	Links []LogLink `json:"links"`
+}
+
 type ArchiveLinkRole struct {
 	Allowed bool `json:"allowed"`
 }
@@ -83,17 +121,226 @@ type SinglePayloadData struct {
 	CreatedAt   time.Time `json:"created_at,omitempty"`
 	Status      string    `json:"status,omitempty"`
 	StatusMsg   string    `json:"status_msg,omitempty"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
 	Date        time.Time `json:"date,omitempty"`
 }
 
 // StatusRetrieve returns a response for /payloads/statuses
 type StatusRetrieve struct {
-	RequestID string `json:"request_id,omitempty"`
-	Status    string `json:"status,omitempty"`
-	ID        string `json:"id,omitempty"`
-	Service   string `json:"service,omitempty"`
-	Source    string `json:"source,omitempty"`
-	StatusMsg string `json:"status_msg,omitempty"`
-	Date      string `json:"date,omitempty"`
-	CreatedAt string `json:"created_at,omitempty"`
+	RequestID   string  `json:"request_id,omitempty"`
+	Status      string  `json:"status,omitempty"`
+	ID          string  `json:"id,omitempty"`
+	Service     string  `json:"service,omitempty"`
+	Source      string  `json:"source,omitempty"`
+	StatusMsg   string  `json:"status_msg,omitempty"`
+	Fingerprint string  `json:"fingerprint,omitempty"`
+	Date        string  `json:"date,omitempty"`
+	CreatedAt   string  `json:"created_at,omitempty"`
+	Rank        float64 `json:"rank,omitempty"`
+	Snippet     string  `json:"snippet,omitempty"`
+}
+
+// TimeseriesPoint is the count of statuses in one bucket of a /stats/timeseries series
+type TimeseriesPoint struct {
+	Time    time.Time `json:"time"`
+	Service string    `json:"service,omitempty"`
+	Source  string    `json:"source,omitempty"`
+	Status  string    `json:"status,omitempty"`
+	OrgID   string    `json:"org_id,omitempty"`
+	Count   int64     `json:"count"`
+}
+
+// TimeseriesData is the response for the /stats/timeseries endpoint
+type TimeseriesData struct {
+	Bucket  string            `json:"bucket"`
+	Elapsed float64           `json:"elapsed"`
+	Data    []TimeseriesPoint `json:"data"`
+}
+
+// HealthCheckResult is the outcome of checking a single dependency
+type HealthCheckResult struct {
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	Critical  bool      `json:"critical"`
+	LatencyMs float64   `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// HealthReport is the response for the /live, /ready and /health endpoints
+type HealthReport struct {
+	Status string              `json:"status"`
+	Checks []HealthCheckResult `json:"checks"`
+}
+
+// ArchiveLinkAuditQuery holds the filters and page for /admin/archiveLinkAudit
+type ArchiveLinkAuditQuery struct {
+	User      string
+	OrgID     string
+	RequestID string
+	Page      int
+	PageSize  int
+}
+
+// ArchiveLinkAuditData is the response for the /admin/archiveLinkAudit endpoint
+type ArchiveLinkAuditData struct {
+	Count   int64                       `json:"count"`
+	Elapsed float64                     `json:"elapsed"`
+	Data    []dbmodels.ArchiveLinkAudit `json:"data"`
+}
+
+// WebhookSubscriptionRequest is the body for creating or updating a webhook subscription
+type WebhookSubscriptionRequest struct {
+	Name             string `json:"name"`
+	Url              string `json:"url"`
+	Secret           string `json:"secret"`
+	Service          string `json:"service"`
+	Status           string `json:"status"`
+	OrgID            string `json:"org_id"`
+	StatusMsgPattern string `json:"status_msg_pattern"`
+	Enabled          *bool  `json:"enabled"`
+}
+
+type WebhookSubscriptionsData struct {
+	Count int                            `json:"count"`
+	Data  []dbmodels.WebhookSubscription `json:"data"`
+}
+
+type WebhookDeliveriesData struct {
+	Count int64                      `json:"count"`
+	Data  []dbmodels.WebhookDelivery `json:"data"`
+}
+
+// SLODefinition is an objective for the share of payloads that reach a status within a threshold
+// of their first status
+type SLODefinition struct {
+	Name             string  `json:"name"`
+	Service          string  `json:"service,omitempty"`
+	Status           string  `json:"status"`
+	ThresholdSeconds int     `json:"threshold_seconds"`
+	Objective        float64 `json:"objective"`
+}
+
+type SLOWindow struct {
+	Window   string    `json:"window"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Total    int64     `json:"total"`
+	Good     int64     `json:"good"`
+	SLI      float64   `json:"sli"`
+	BurnRate float64   `json:"burn_rate"`
+}
+
+type SLOStatus struct {
+	SLODefinition
+	Windows []SLOWindow `json:"windows"`
+}
+
+type SLOData struct {
+	Data []SLOStatus `json:"data"`
+}
+
+// AnomalyQuery holds the params for the /anomalies endpoint
+type AnomalyQuery struct {
+	Kind     string
+	Service  string
+	Active   *bool
+	Start    time.Time
+	End      time.Time
+	Page     int
+	PageSize int
+}
+
+type AnomaliesData struct {
+	Count int64              `json:"count"`
+	Data  []dbmodels.Anomaly `json:"data"`
+}
+
+// ErrorsQuery holds the params for the /errors endpoint
+type ErrorsQuery struct {
+	Service  string
+	Status   string
+	Start    time.Time
+	End      time.Time
+	Page     int
+	PageSize int
+	Samples  int
+}
+
+// ErrorFingerprint counts the statuses of a service whose messages have the same fingerprint
+type ErrorFingerprint struct {
+	Service          string   `json:"service"`
+	Fingerprint      string   `json:"fingerprint"`
+	Pattern          string   `json:"pattern"`
+	Count            int64    `json:"count"`
+	SampleMsg        string   `json:"sample_msg"`
+	SampleRequestIDs []string `json:"sample_request_ids"`
+}
+
+type ErrorsData struct {
+	Count   int64              `json:"count"`
+	Elapsed float64            `json:"elapsed"`
+	Data    []ErrorFingerprint `json:"data"`
+}
+
+// CatalogQuery holds the params for the /services, /sources and /statuses/catalog endpoints
+type CatalogQuery struct {
+	RecentSince time.Time
+	SeenSince   time.Time
+}
+
+// CatalogEntry is a service, source or status with when it was first and last reported
+type CatalogEntry struct {
+	Name        string     `json:"name"`
+	FirstSeen   *time.Time `json:"first_seen"`
+	LastSeen    *time.Time `json:"last_seen"`
+	RecentCount int64      `json:"recent_count"`
+}
+
+type CatalogData struct {
+	Count   int64          `json:"count"`
+	Elapsed float64        `json:"elapsed"`
+	Data    []CatalogEntry `json:"data"`
+}
+
+// PayloadTimeline returns a response for /payloads/{request_id}/timeline
+type PayloadTimeline struct {
+	RequestID  string         `json:"request_id"`
+	Start      time.Time      `json:"start"`
+	End        time.Time      `json:"end"`
+	DurationMs int64          `json:"duration_ms"`
+	Outcome    string         `json:"outcome,omitempty"`
+	Spans      []TimelineSpan `json:"spans"`
+	Gaps       []TimelineGap  `json:"gaps"`
+}
+
+// TimelineSpan is the time from the first to the last status of a service:source
+type TimelineSpan struct {
+	Name       string           `json:"name"`
+	Service    string           `json:"service"`
+	Source     string           `json:"source,omitempty"`
+	Start      time.Time        `json:"start"`
+	End        time.Time        `json:"end"`
+	OffsetMs   int64            `json:"offset_ms"`
+	DurationMs int64            `json:"duration_ms"`
+	Outcome    string           `json:"outcome,omitempty"`
+	Statuses   []TimelineStatus `json:"statuses"`
+}
+
+// TimelineStatus is a status reported within a timeline span
+type TimelineStatus struct {
+	Status    string    `json:"status"`
+	StatusMsg string    `json:"status_msg,omitempty"`
+	Date      time.Time `json:"date"`
+	OffsetMs  int64     `json:"offset_ms"`
+}
+
+// TimelineGap is a time no service:source of the payload was reporting statuses
+type TimelineGap struct {
+	From       string    `json:"from"`
+	To         string    `json:"to"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	OffsetMs   int64     `json:"offset_ms"`
+	DurationMs int64     `json:"duration_ms"`
 }
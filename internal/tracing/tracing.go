@@ -0,0 +1,62 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/config"
+	l "github.com/redhatinsights/payload-tracker-go/internal/logging"
+)
+
+const tracerName = "github.com/redhatinsights/payload-tracker-go"
+
+func init() {
+	// W3C trace context is extracted from incoming requests and messages even when
+	// exporting is disabled, so that the ids can still be passed along
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+}
+
+// Tracer returns the tracer used for all payload tracker spans
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// InitTracer exports spans to the configured OTLP/HTTP endpoint. The returned function
+// flushes and stops the exporter. When tracing is disabled the no-op provider stays in place.
+func InitTracer(ctx context.Context, cfg *config.TrackerConfig, service string) (func(context.Context) error, error) {
+	if !cfg.TracingConfig.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(cfg.TracingConfig.OTLPEndpoint))
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", service),
+		attribute.String("host.name", cfg.Hostname),
+		attribute.String("deployment.environment", cfg.Environment),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.TracingConfig.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	l.Log.Infof("Exporting traces to %s", cfg.TracingConfig.OTLPEndpoint)
+
+	return provider.Shutdown, nil
+}
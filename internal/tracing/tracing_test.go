@@ -0,0 +1,119 @@
+package tracing
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/go-chi/chi/v5"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/config"
+)
+
+var _ = Describe("Tracing", func() {
+	var recorder *tracetest.SpanRecorder
+
+	BeforeEach(func() {
+		recorder = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	})
+
+	AfterEach(func() {
+		otel.SetTracerProvider(trace.NewNoopTracerProvider())
+	})
+
+	It("continues the trace from the traceparent header and names the span after the route", func() {
+		router := chi.NewRouter()
+		router.Use(Middleware)
+		router.Get("/payloads/{request_id}", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		})
+
+		req := httptest.NewRequest("GET", "/payloads/abc", nil)
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+
+		spans := recorder.Ended()
+		Expect(spans).To(HaveLen(1))
+		Expect(spans[0].Name()).To(Equal("GET /payloads/{request_id}"))
+		Expect(spans[0].SpanKind()).To(Equal(trace.SpanKindServer))
+		Expect(spans[0].Parent().TraceID().String()).To(Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+		Expect(spans[0].Parent().SpanID().String()).To(Equal("00f067aa0ba902b7"))
+	})
+
+	It("adds a child span for each db statement", func() {
+		db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(RegisterCallbacks(db)).To(Succeed())
+
+		ctx, parent := Tracer().Start(context.Background(), "parent")
+		var result int
+		Expect(db.WithContext(ctx).Raw("SELECT 1").Scan(&result).Error).ToNot(HaveOccurred())
+		parent.End()
+
+		spans := recorder.Ended()
+		Expect(spans).To(HaveLen(2))
+		Expect(spans[0].Name()).To(Equal("db.row"))
+		Expect(spans[0].Parent().SpanID()).To(Equal(parent.SpanContext().SpanID()))
+	})
+
+	It("exports spans to the configured OTLP/HTTP endpoint", func() {
+		exports := make(chan *collectortrace.ExportTraceServiceRequest, 1)
+		collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer GinkgoRecover()
+			Expect(r.URL.Path).To(Equal("/v1/traces"))
+
+			body, err := ioutil.ReadAll(r.Body)
+			Expect(err).ToNot(HaveOccurred())
+			export := &collectortrace.ExportTraceServiceRequest{}
+			Expect(proto.Unmarshal(body, export)).To(Succeed())
+			exports <- export
+
+			w.Header().Set("Content-Type", "application/x-protobuf")
+			response, _ := proto.Marshal(&collectortrace.ExportTraceServiceResponse{})
+			w.Write(response)
+		}))
+		defer collector.Close()
+
+		cfg := &config.TrackerConfig{
+			Hostname:      "test-host",
+			Environment:   "test",
+			TracingConfig: config.TracingCfg{Enabled: true, OTLPEndpoint: collector.URL, SampleRatio: 1},
+		}
+		shutdown, err := InitTracer(context.Background(), cfg, "payload-tracker-api")
+		Expect(err).ToNot(HaveOccurred())
+
+		_, span := Tracer().Start(context.Background(), "exported")
+		span.End()
+		// shutting down flushes the batched spans
+		Expect(shutdown(context.Background())).To(Succeed())
+
+		var export *collectortrace.ExportTraceServiceRequest
+		Eventually(exports).Should(Receive(&export))
+		Expect(export.ResourceSpans).To(HaveLen(1))
+
+		resource := map[string]string{}
+		for _, attr := range export.ResourceSpans[0].Resource.Attributes {
+			resource[attr.Key] = attr.Value.GetStringValue()
+		}
+		Expect(resource).To(HaveKeyWithValue("service.name", "payload-tracker-api"))
+		Expect(resource).To(HaveKeyWithValue("host.name", "test-host"))
+
+		spans := export.ResourceSpans[0].ScopeSpans[0].Spans
+		Expect(spans).To(HaveLen(1))
+		Expect(spans[0].Name).To(Equal("exported"))
+		traceID := span.SpanContext().TraceID()
+		Expect(spans[0].TraceId).To(Equal(traceID[:]))
+	})
+})
@@ -0,0 +1,77 @@
+package test
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	models "github.com/redhatinsights/payload-tracker-go/internal/models/db"
+)
+
+// Payloads inserts the payloads of a spec and deletes them, their statuses and the services
+// created for them after the spec
+type Payloads struct {
+	db       func() *gorm.DB
+	ids      []uint
+	services []string
+}
+
+func WithPayloads(db func() *gorm.DB) *Payloads {
+	p := &Payloads{db: db}
+
+	AfterEach(func() {
+		if len(p.ids) > 0 {
+			Expect(p.db().Where("payload_id IN ?", p.ids).Delete(&models.PayloadStatuses{}).Error).ToNot(HaveOccurred())
+			Expect(p.db().Where("payload_id IN ?", p.ids).Delete(&models.PayloadLatestStatus{}).Error).ToNot(HaveOccurred())
+			Expect(p.db().Where("id IN ?", p.ids).Delete(&models.Payloads{}).Error).ToNot(HaveOccurred())
+		}
+		if len(p.services) > 0 {
+			Expect(p.db().Where("name IN ?", p.services).Delete(&models.Services{}).Error).ToNot(HaveOccurred())
+		}
+		p.ids, p.services = nil, nil
+	})
+
+	return p
+}
+
+// StatusId returns the id of the named status, statuses are shared and kept
+func (p *Payloads) StatusId(name string) int32 {
+	status := models.Statuses{Name: name}
+	Expect(p.db().Where("name = ?", name).FirstOrCreate(&status).Error).ToNot(HaveOccurred())
+	return status.Id
+}
+
+// ServiceId returns the id of the named service, it's deleted after the spec when it's created here
+func (p *Payloads) ServiceId(name string) int32 {
+	var service models.Services
+	result := p.db().Where("name = ?", name).Limit(1).Find(&service)
+	Expect(result.Error).ToNot(HaveOccurred())
+	if result.RowsAffected == 0 {
+		service.Name = name
+		Expect(p.db().Create(&service).Error).ToNot(HaveOccurred())
+		p.services = append(p.services, name)
+	}
+	return service.Id
+}
+
+// Create inserts the payload with its statuses, a request id is generated when it has none
+func (p *Payloads) Create(payload models.Payloads, statuses ...models.PayloadStatuses) models.Payloads {
+	if payload.RequestId == "" {
+		payload.RequestId = uuid.New().String()
+	}
+	Expect(p.db().Create(&payload).Error).ToNot(HaveOccurred())
+	p.ids = append(p.ids, payload.Id)
+
+	for i := range statuses {
+		statuses[i].PayloadId = payload.Id
+		tx := p.db()
+		if statuses[i].SourceId == 0 {
+			tx = tx.Omit("source_id")
+		}
+		Expect(tx.Create(&statuses[i]).Error).ToNot(HaveOccurred())
+	}
+
+	return payload
+}
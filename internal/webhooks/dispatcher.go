@@ -0,0 +1,242 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/config"
+	"github.com/redhatinsights/payload-tracker-go/internal/endpoints"
+	l "github.com/redhatinsights/payload-tracker-go/internal/logging"
+	models "github.com/redhatinsights/payload-tracker-go/internal/models/db"
+	"github.com/redhatinsights/payload-tracker-go/internal/queries"
+)
+
+const (
+	// cleanupInterval is how often deliveries past their retention are deleted
+	cleanupInterval = time.Hour
+	// minLease is the shortest a delivery is claimed for, even without a request timeout
+	minLease = 30 * time.Second
+)
+
+// Dispatcher delivers the queued webhook deliveries. Failed deliveries are retried with
+// exponential backoff until the maximum number of attempts is reached. Several dispatchers
+// can share the queue, each delivery is claimed before it is sent.
+type Dispatcher struct {
+	db          *gorm.DB
+	client      *http.Client
+	interval    time.Duration
+	batchSize   int
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	retention   time.Duration
+	lease       time.Duration
+	cleanedAt   time.Time
+}
+
+func NewDispatcher(cfg *config.TrackerConfig, db *gorm.DB) *Dispatcher {
+	timeout := time.Duration(cfg.WebhookConfig.TimeoutMs) * time.Millisecond
+
+	lease := 2 * timeout
+	if lease < minLease {
+		lease = minLease
+	}
+
+	return &Dispatcher{
+		db:          db,
+		client:      &http.Client{Timeout: timeout},
+		interval:    time.Duration(cfg.WebhookConfig.DispatchIntervalMs) * time.Millisecond,
+		batchSize:   cfg.WebhookConfig.BatchSize,
+		maxAttempts: cfg.WebhookConfig.MaxAttempts,
+		backoff:     time.Duration(cfg.WebhookConfig.BackoffMs) * time.Millisecond,
+		maxBackoff:  time.Duration(cfg.WebhookConfig.MaxBackoffSeconds) * time.Second,
+		retention:   time.Duration(cfg.WebhookConfig.RetentionDays) * 24 * time.Hour,
+		lease:       lease,
+	}
+}
+
+// Run dispatches due deliveries every interval until the context is done
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// keep going while full batches are claimed
+			for d.batchSize > 0 && d.Dispatch(ctx, time.Now()) == d.batchSize && ctx.Err() == nil {
+			}
+			if now := time.Now(); d.retention > 0 && now.Sub(d.cleanedAt) >= cleanupInterval {
+				d.Cleanup(ctx, now)
+			}
+		}
+	}
+}
+
+// Cleanup deletes the delivered and failed deliveries older than the retention
+func (d *Dispatcher) Cleanup(ctx context.Context, now time.Time) {
+	d.cleanedAt = now
+
+	result := queries.DeleteWebhookDeliveriesBefore(d.db.WithContext(ctx), now.Add(-d.retention))
+	if result.Error != nil {
+		l.Log.Errorf("Unable to delete old webhook deliveries: %v", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		l.Log.Infof("Deleted %d webhook deliveries older than %s", result.RowsAffected, d.retention)
+	}
+}
+
+// Dispatch sends a batch of due deliveries and returns how many it claimed
+func (d *Dispatcher) Dispatch(ctx context.Context, now time.Time) int {
+	db := d.db.WithContext(ctx)
+
+	due, err := queries.DueWebhookDeliveries(db, now, d.batchSize)
+	if err != nil {
+		l.Log.Errorf("Unable to load due webhook deliveries: %v", err)
+		return 0
+	}
+
+	subscriptions := make(map[uint]*models.WebhookSubscription)
+
+	claimed := 0
+	for i := range due {
+		delivery := &due[i]
+
+		// the lease starts when the delivery is claimed, not when the batch was loaded, as
+		// the deliveries before it may have taken up to their timeout each. It only matters
+		// when a dispatcher dies mid delivery, the outcome replaces it.
+		ok, err := queries.ClaimWebhookDelivery(db, delivery.Id, now, time.Now().Add(d.lease))
+		if err != nil {
+			l.Log.Errorf("Unable to claim webhook delivery %d: %v", delivery.Id, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		claimed++
+
+		subscription, ok := subscriptions[delivery.SubscriptionId]
+		if !ok {
+			s, err := queries.GetWebhookSubscription(db, delivery.SubscriptionId)
+			switch {
+			case err == nil:
+				subscription = &s
+			case !errors.Is(err, gorm.ErrRecordNotFound):
+				// retried once the claim runs out
+				l.Log.Errorf("Unable to load webhook subscription %d: %v", delivery.SubscriptionId, err)
+				continue
+			}
+			subscriptions[delivery.SubscriptionId] = subscription
+		}
+
+		switch {
+		case subscription == nil:
+			d.giveUp(db, delivery, "subscription no longer exists")
+		case !subscription.Enabled:
+			d.giveUp(db, delivery, "subscription is disabled")
+		default:
+			d.deliver(ctx, db, subscription, delivery)
+		}
+	}
+
+	return claimed
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, db *gorm.DB, subscription *models.WebhookSubscription, delivery *models.WebhookDelivery) {
+	start := time.Now()
+	status, err := d.send(ctx, subscription, delivery)
+	elapsed := time.Since(start)
+
+	claimedAttempts := delivery.Attempts
+	delivery.Attempts++
+	delivery.LastStatusCode = status
+	delivery.LastError = ""
+	if err != nil {
+		delivery.LastError = err.Error()
+	}
+
+	switch {
+	case err == nil:
+		delivered := time.Now()
+		delivery.State = queries.WebhookDelivered
+		delivery.DeliveredAt = &delivered
+		endpoints.ObserveWebhookDeliveryAttempt("delivered", elapsed)
+	case delivery.Attempts >= d.maxAttempts:
+		delivery.State = queries.WebhookFailed
+		endpoints.ObserveWebhookDeliveryAttempt("failed", elapsed)
+		l.Log.Warnf("Giving up webhook delivery %d to subscription %d after %d attempts: %v", delivery.Id, subscription.Id, delivery.Attempts, err)
+	default:
+		delivery.NextAttemptAt = time.Now().Add(d.backoffAfter(delivery.Attempts))
+		endpoints.ObserveWebhookDeliveryAttempt("retry", elapsed)
+	}
+
+	d.update(db, delivery, claimedAttempts)
+}
+
+func (d *Dispatcher) giveUp(db *gorm.DB, delivery *models.WebhookDelivery, reason string) {
+	claimedAttempts := delivery.Attempts
+	delivery.State = queries.WebhookFailed
+	delivery.LastError = reason
+	d.update(db, delivery, claimedAttempts)
+}
+
+// update records the outcome of a delivery unless another dispatcher claimed it in the meantime
+func (d *Dispatcher) update(db *gorm.DB, delivery *models.WebhookDelivery, claimedAttempts int) {
+	result := queries.UpdateWebhookDelivery(db, delivery, claimedAttempts)
+	switch {
+	case result.Error != nil:
+		l.Log.Errorf("Unable to update webhook delivery %d: %v", delivery.Id, result.Error)
+	case result.RowsAffected == 0:
+		l.Log.Warnf("Webhook delivery %d was claimed again before its outcome was recorded", delivery.Id)
+	}
+}
+
+// send posts the event to the subscription url, any response but a 2xx is a failure
+func (d *Dispatcher) send(ctx context.Context, subscription *models.WebhookSubscription, delivery *models.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Event)
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Payload-Tracker-Delivery", strconv.FormatUint(uint64(delivery.Id), 10))
+	req.Header.Set("X-Payload-Tracker-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Payload-Tracker-Signature", Sign(subscription.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook responded with %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func (d *Dispatcher) backoffAfter(attempts int) time.Duration {
+	wait := d.backoff
+	for i := 1; i < attempts && wait < d.maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > d.maxBackoff {
+		wait = d.maxBackoff
+	}
+	return wait
+}
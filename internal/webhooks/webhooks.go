@@ -0,0 +1,139 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/config"
+	"github.com/redhatinsights/payload-tracker-go/internal/endpoints"
+	l "github.com/redhatinsights/payload-tracker-go/internal/logging"
+	models "github.com/redhatinsights/payload-tracker-go/internal/models/db"
+	"github.com/redhatinsights/payload-tracker-go/internal/queries"
+)
+
+// Event is the body posted to webhook subscriptions for a payload status
+type Event struct {
+	RequestID   string    `json:"request_id"`
+	Service     string    `json:"service"`
+	Source      string    `json:"source,omitempty"`
+	Status      string    `json:"status"`
+	StatusMsg   string    `json:"status_msg,omitempty"`
+	OrgID       string    `json:"org_id,omitempty"`
+	Account     string    `json:"account,omitempty"`
+	InventoryID string    `json:"inventory_id,omitempty"`
+	SystemID    string    `json:"system_id,omitempty"`
+	Date        time.Time `json:"date"`
+}
+
+// Sign returns the signature sent in the X-Payload-Tracker-Signature header, a hex encoded
+// HMAC-SHA256 of the timestamp header, a dot and the body
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+type subscription struct {
+	models.WebhookSubscription
+	statusMsg *regexp.Regexp
+}
+
+func (s subscription) matches(e Event) bool {
+	return (s.Service == "" || s.Service == e.Service) &&
+		(s.Status == "" || s.Status == e.Status) &&
+		(s.OrgId == "" || s.OrgId == e.OrgID) &&
+		(s.statusMsg == nil || s.statusMsg.MatchString(e.StatusMsg))
+}
+
+// Matcher queues webhook deliveries for the statuses matching the enabled subscriptions.
+// Subscriptions are reloaded from the database once they are older than the refresh interval.
+type Matcher struct {
+	refresh time.Duration
+
+	lock          sync.Mutex
+	loadedAt      time.Time
+	subscriptions []subscription
+}
+
+func NewMatcher(cfg *config.TrackerConfig) *Matcher {
+	return &Matcher{refresh: time.Duration(cfg.WebhookConfig.RefreshSeconds) * time.Second}
+}
+
+// Enqueue queues a delivery of the event for every matching subscription
+func (m *Matcher) Enqueue(db *gorm.DB, event Event, now time.Time) error {
+	var body []byte
+	var deliveries []models.WebhookDelivery
+
+	for _, s := range m.current(db, now) {
+		if !s.matches(event) {
+			continue
+		}
+
+		if body == nil {
+			var err error
+			if body, err = json.Marshal(event); err != nil {
+				return err
+			}
+		}
+
+		deliveries = append(deliveries, models.WebhookDelivery{
+			SubscriptionId: s.Id,
+			RequestId:      event.RequestID,
+			Event:          string(body),
+			State:          queries.WebhookPending,
+			NextAttemptAt:  now,
+		})
+	}
+
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	if err := queries.InsertWebhookDeliveries(db, deliveries).Error; err != nil {
+		return err
+	}
+	endpoints.IncWebhookDeliveriesEnqueued(len(deliveries))
+	return nil
+}
+
+// current returns the enabled subscriptions, the previous ones are kept when reloading fails
+func (m *Matcher) current(db *gorm.DB, now time.Time) []subscription {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if !m.loadedAt.IsZero() && now.Sub(m.loadedAt) < m.refresh {
+		return m.subscriptions
+	}
+
+	loaded, err := queries.EnabledWebhookSubscriptions(db)
+	if err != nil {
+		l.Log.Errorf("Unable to load webhook subscriptions: %v", err)
+		return m.subscriptions
+	}
+
+	subscriptions := make([]subscription, 0, len(loaded))
+	for _, s := range loaded {
+		sub := subscription{WebhookSubscription: s}
+		if s.StatusMsgPattern != "" {
+			if sub.statusMsg, err = regexp.Compile(s.StatusMsgPattern); err != nil {
+				l.Log.Errorf("Skipping webhook subscription %d with invalid status_msg_pattern: %v", s.Id, err)
+				continue
+			}
+		}
+		subscriptions = append(subscriptions, sub)
+	}
+
+	m.loadedAt = now
+	m.subscriptions = subscriptions
+	return subscriptions
+}
@@ -0,0 +1,286 @@
+package webhooks
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhatinsights/payload-tracker-go/internal/config"
+	models "github.com/redhatinsights/payload-tracker-go/internal/models/db"
+	"github.com/redhatinsights/payload-tracker-go/internal/queries"
+	"github.com/redhatinsights/payload-tracker-go/internal/utils/test"
+	"gorm.io/gorm"
+)
+
+var _ = Describe("Sign", func() {
+	It("Should sign the timestamp and body with the secret", func() {
+		Expect(Sign("secret", 1679067370, []byte(`{"status":"error"}`))).To(Equal(
+			"sha256=a4602fd5bc572eb0ebce3d8d87881e8515b086bad30b3df99a1eda7f2fa32988",
+		))
+		Expect(Sign("secret", 1679067370, []byte("a"))).ToNot(Equal(Sign("secret", 1679067371, []byte("a"))))
+		Expect(Sign("secret", 1679067370, []byte("a"))).ToNot(Equal(Sign("other", 1679067370, []byte("a"))))
+	})
+})
+
+var _ = Describe("Webhooks", func() {
+	var (
+		cfg     config.TrackerConfig
+		orgID   string
+		now     time.Time
+		created []uint
+	)
+
+	db := test.WithDatabase()
+
+	subscribe := func(subscription models.WebhookSubscription) models.WebhookSubscription {
+		subscription.Name = "test"
+		subscription.Secret = "secret"
+		subscription.Enabled = true
+		if subscription.Url == "" {
+			subscription.Url = "http://localhost:1/hook"
+		}
+		Expect(queries.CreateWebhookSubscription(db(), &subscription).Error).ToNot(HaveOccurred())
+		created = append(created, subscription.Id)
+		return subscription
+	}
+
+	deliveries := func(subscriptionId uint) []models.WebhookDelivery {
+		_, found := queries.RetrieveWebhookDeliveries(db(), subscriptionId, "", 0, 100)
+		return found
+	}
+
+	event := func(status string, statusMsg string) Event {
+		return Event{RequestID: uuid.New().String(), Service: "puptoo", Status: status, StatusMsg: statusMsg, OrgID: orgID, Date: now}
+	}
+
+	BeforeEach(func() {
+		cfg = *config.Get()
+		cfg.WebhookConfig.MaxAttempts = 2
+		cfg.WebhookConfig.BatchSize = 1000
+		cfg.WebhookConfig.BackoffMs = 1000
+		cfg.WebhookConfig.MaxBackoffSeconds = 60
+		orgID = uuid.New().String()
+		now = time.Now().UTC().Truncate(time.Second)
+		created = nil
+	})
+
+	AfterEach(func() {
+		for _, id := range created {
+			queries.DeleteWebhookSubscription(db(), id)
+		}
+	})
+
+	Describe("Matcher", func() {
+		It("Should queue deliveries for the matching subscriptions only", func() {
+			errors := subscribe(models.WebhookSubscription{OrgId: orgID, Status: "error", StatusMsgPattern: "^timeout"})
+			puptoo := subscribe(models.WebhookSubscription{OrgId: orgID, Service: "puptoo"})
+			other := subscribe(models.WebhookSubscription{OrgId: orgID, Service: "ingress"})
+
+			matcher := NewMatcher(&cfg)
+			Expect(matcher.Enqueue(db(), event("error", "timeout talking to inventory"), now)).To(Succeed())
+			Expect(matcher.Enqueue(db(), event("error", "bad archive"), now)).To(Succeed())
+			Expect(matcher.Enqueue(db(), event("success", ""), now)).To(Succeed())
+
+			Expect(deliveries(errors.Id)).To(HaveLen(1))
+			Expect(deliveries(errors.Id)[0].Event).To(ContainSubstring(`"status_msg":"timeout talking to inventory"`))
+			Expect(deliveries(errors.Id)[0].State).To(Equal(queries.WebhookPending))
+			Expect(deliveries(puptoo.Id)).To(HaveLen(3))
+			Expect(deliveries(other.Id)).To(BeEmpty())
+		})
+
+		It("Should keep using the loaded subscriptions until the refresh interval passed", func() {
+			cfg.WebhookConfig.RefreshSeconds = 60
+			matcher := NewMatcher(&cfg)
+			Expect(matcher.Enqueue(db(), event("error", ""), now)).To(Succeed())
+
+			subscription := subscribe(models.WebhookSubscription{OrgId: orgID})
+			Expect(matcher.Enqueue(db(), event("error", ""), now.Add(time.Second))).To(Succeed())
+			Expect(deliveries(subscription.Id)).To(BeEmpty())
+
+			Expect(matcher.Enqueue(db(), event("error", ""), now.Add(time.Minute))).To(Succeed())
+			Expect(deliveries(subscription.Id)).To(HaveLen(1))
+		})
+	})
+
+	Describe("Dispatcher", func() {
+		var (
+			server   *httptest.Server
+			status   int
+			received []*http.Request
+			bodies   []string
+		)
+
+		BeforeEach(func() {
+			status = http.StatusOK
+			received, bodies = nil, nil
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := ioutil.ReadAll(r.Body)
+				received = append(received, r)
+				bodies = append(bodies, string(body))
+				w.WriteHeader(status)
+			}))
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		queue := func() {
+			Expect(NewMatcher(&cfg).Enqueue(db(), event("error", "boom"), now)).To(Succeed())
+		}
+
+		It("Should post signed events and mark them delivered", func() {
+			subscription := subscribe(models.WebhookSubscription{OrgId: orgID, Url: server.URL})
+			queue()
+
+			NewDispatcher(&cfg, db()).Dispatch(context.Background(), time.Now())
+
+			Expect(received).To(HaveLen(1))
+			timestamp, err := strconv.ParseInt(received[0].Header.Get("X-Payload-Tracker-Timestamp"), 10, 64)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(received[0].Header.Get("X-Payload-Tracker-Signature")).To(Equal(Sign("secret", timestamp, []byte(bodies[0]))))
+
+			delivered := deliveries(subscription.Id)
+			Expect(received[0].Header.Get("X-Payload-Tracker-Delivery")).To(Equal(strconv.Itoa(int(delivered[0].Id))))
+			Expect(delivered[0].State).To(Equal(queries.WebhookDelivered))
+			Expect(delivered[0].Attempts).To(Equal(1))
+			Expect(delivered[0].DeliveredAt).ToNot(BeNil())
+		})
+
+		It("Should retry failed deliveries with backoff and give up after the last attempt", func() {
+			status = http.StatusServiceUnavailable
+			subscription := subscribe(models.WebhookSubscription{OrgId: orgID, Url: server.URL})
+			queue()
+
+			dispatcher := NewDispatcher(&cfg, db())
+			dispatcher.Dispatch(context.Background(), time.Now())
+
+			retried := deliveries(subscription.Id)[0]
+			Expect(retried.State).To(Equal(queries.WebhookPending))
+			Expect(retried.LastStatusCode).To(Equal(http.StatusServiceUnavailable))
+			Expect(retried.NextAttemptAt).To(BeTemporally(">", time.Now()))
+
+			// not due yet
+			dispatcher.Dispatch(context.Background(), time.Now())
+			Expect(received).To(HaveLen(1))
+
+			dispatcher.Dispatch(context.Background(), time.Now().Add(time.Minute))
+			Expect(received).To(HaveLen(2))
+
+			failed := deliveries(subscription.Id)[0]
+			Expect(failed.State).To(Equal(queries.WebhookFailed))
+			Expect(failed.Attempts).To(Equal(2))
+		})
+
+		It("Should give up on deliveries of disabled subscriptions", func() {
+			subscription := subscribe(models.WebhookSubscription{OrgId: orgID, Url: server.URL})
+			queue()
+
+			subscription.Enabled = false
+			Expect(queries.UpdateWebhookSubscription(db(), &subscription).Error).ToNot(HaveOccurred())
+
+			NewDispatcher(&cfg, db()).Dispatch(context.Background(), time.Now())
+
+			Expect(received).To(BeEmpty())
+			Expect(deliveries(subscription.Id)[0].State).To(Equal(queries.WebhookFailed))
+		})
+
+		It("Should send each delivery once with several dispatchers and a slow receiver", func() {
+			var (
+				lock sync.Mutex
+				sent = map[string]int{}
+			)
+			slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(100 * time.Millisecond)
+				lock.Lock()
+				sent[r.Header.Get("X-Payload-Tracker-Delivery")]++
+				lock.Unlock()
+			}))
+			defer slow.Close()
+
+			// the sends of a batch take longer than twice the timeout together
+			cfg.WebhookConfig.TimeoutMs = 150
+			subscription := subscribe(models.WebhookSubscription{OrgId: orgID, Url: slow.URL})
+			for i := 0; i < 8; i++ {
+				queue()
+			}
+
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				Expect(NewDispatcher(&cfg, db()).Dispatch(context.Background(), time.Now())).To(BeNumerically(">", 0))
+			}()
+
+			// by now the deliveries the first dispatcher claimed last were claimed over 300ms
+			// after it started and are still being sent
+			time.Sleep(350 * time.Millisecond)
+			other := NewDispatcher(&cfg, db())
+			for waiting := true; waiting; {
+				select {
+				case <-done:
+					waiting = false
+				case <-time.After(50 * time.Millisecond):
+					other.Dispatch(context.Background(), time.Now())
+				}
+			}
+
+			var delivered []models.WebhookDelivery
+			for _, delivery := range deliveries(subscription.Id) {
+				if strings.Contains(delivery.Event, orgID) {
+					delivered = append(delivered, delivery)
+				}
+			}
+			Expect(delivered).To(HaveLen(8))
+			for _, delivery := range delivered {
+				Expect(delivery.State).To(Equal(queries.WebhookDelivered))
+				Expect(delivery.Attempts).To(Equal(1))
+				Expect(sent[strconv.Itoa(int(delivery.Id))]).To(Equal(1))
+			}
+
+			// an outcome recorded under a claim that ran out is dropped
+			stale := delivered[0]
+			stale.Attempts, stale.State = 1, queries.WebhookFailed
+			Expect(queries.UpdateWebhookDelivery(db(), &stale, 0).RowsAffected).To(BeZero())
+			Expect(db().First(&stale, stale.Id).Error).ToNot(HaveOccurred())
+			Expect(stale.State).To(Equal(queries.WebhookDelivered))
+		})
+
+		It("Should delete finished deliveries after the retention and keep pending ones", func() {
+			cfg.WebhookConfig.RetentionDays = 7
+			subscription := subscribe(models.WebhookSubscription{OrgId: orgID, Url: server.URL})
+			queue()
+
+			dispatcher := NewDispatcher(&cfg, db())
+			dispatcher.Dispatch(context.Background(), time.Now())
+			delivered := deliveries(subscription.Id)[0]
+			Expect(delivered.State).To(Equal(queries.WebhookDelivered))
+			queue()
+			pending := deliveries(subscription.Id)[0]
+			Expect(pending.State).To(Equal(queries.WebhookPending))
+
+			dispatcher.Cleanup(context.Background(), now.Add(24*time.Hour))
+			Expect(db().First(&models.WebhookDelivery{}, delivered.Id).Error).ToNot(HaveOccurred())
+
+			dispatcher.Cleanup(context.Background(), now.Add(8*24*time.Hour))
+			Expect(db().First(&models.WebhookDelivery{}, delivered.Id).Error).To(MatchError(gorm.ErrRecordNotFound))
+			Expect(db().First(&models.WebhookDelivery{}, pending.Id).Error).ToNot(HaveOccurred())
+		})
+	})
+
+	It("Should double the backoff up to the maximum", func() {
+		d := NewDispatcher(&cfg, db())
+		Expect(d.backoffAfter(1)).To(Equal(time.Second))
+		Expect(d.backoffAfter(3)).To(Equal(4 * time.Second))
+		Expect(d.backoffAfter(20)).To(Equal(time.Minute))
+	})
+})